@@ -0,0 +1,189 @@
+package git
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// adminEndpoint is an HTTP server reporting the status of the repositories
+// configured with its address.
+type adminEndpoint struct {
+	addr string
+}
+
+// RepoStatus is the status of a repository, as reported by the admin
+// endpoint.
+type RepoStatus struct {
+	Name       string     `json:"name,omitempty"`
+	URL        string     `json:"url"`
+	Branch     string     `json:"branch"`
+	Path       string     `json:"path"`
+	Interval   float64    `json:"interval_seconds"`
+	Commit     string     `json:"commit"`
+	LastPull   *time.Time `json:"last_pull,omitempty"`
+	NextPull   *time.Time `json:"next_pull,omitempty"`
+	Healthy    bool       `json:"healthy"`
+	Paused     bool       `json:"paused"`
+	LastResult *PullEvent `json:"last_result,omitempty"`
+}
+
+// startAdmin starts the admin endpoint at addr, or adds a reference to
+// it if it is already running.
+func startAdmin(addr string) error {
+	return listeners.acquire("admin "+addr, func() (io.Closer, error) {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		a := &adminEndpoint{addr: addr}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/", a.status)
+		mux.HandleFunc("/health", a.health)
+		mux.HandleFunc("/pull", a.pull)
+		srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		go func() { srv.Serve(ln) }()
+		return srv, nil
+	})
+}
+
+// stopAdmin removes a reference to the admin endpoint at addr and stops
+// it once it is no longer used.
+func stopAdmin(addr string) error { return listeners.release("admin " + addr) }
+
+// repos returns the running repositories served by the endpoint.
+func (a *adminEndpoint) repos() []*Repo {
+	var rs []*Repo
+	for _, r := range registry.all() {
+		if r.Admin == a.addr {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// authorized reports whether req carries the bearer token of each of rs
+// requiring one, and otherwise answers 401.
+func authorized(w http.ResponseWriter, req *http.Request, rs []*Repo) bool {
+	token, bearer := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	for _, r := range rs {
+		if r.AdminToken != "" && (!bearer || subtle.ConstantTimeCompare([]byte(token), []byte(r.AdminToken)) != 1) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return false
+		}
+	}
+	return true
+}
+
+// loopback reports whether the address addr only accepts connections from
+// the local host.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// status reports the status of every repository.
+func (a *adminEndpoint) status(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	rs := a.repos()
+	if !authorized(w, req, rs) {
+		return
+	}
+	writeStatus(w, http.StatusOK, rs)
+}
+
+// health reports 503 if any repository is unhealthy.
+func (a *adminEndpoint) health(w http.ResponseWriter, req *http.Request) {
+	code := http.StatusOK
+	for _, r := range a.repos() {
+		if !r.Healthy() {
+			code = http.StatusServiceUnavailable
+		}
+	}
+	w.WriteHeader(code)
+	w.Write([]byte(http.StatusText(code)))
+}
+
+// pull pulls the repository named by the repo query parameter, or all
+// repositories if it is not set, and reports their status.
+func (a *adminEndpoint) pull(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	all := a.repos()
+	if !authorized(w, req, all) {
+		return
+	}
+	var rs []*Repo
+	for _, r := range all {
+		if name := req.URL.Query().Get("repo"); name == "" || r.matches(name) {
+			rs = append(rs, r)
+		}
+	}
+	if len(rs) == 0 {
+		http.Error(w, "no such repo", http.StatusNotFound)
+		return
+	}
+
+	code := http.StatusOK
+	for _, r := range rs {
+		if err := r.pullFrom(r.lifetime(), sourceAdmin); err != nil {
+			code = http.StatusBadGateway
+		}
+	}
+	writeStatus(w, code, rs)
+}
+
+// writeStatus writes the status of rs as JSON.
+func writeStatus(w http.ResponseWriter, code int, rs []*Repo) {
+	statuses := make([]RepoStatus, len(rs))
+	for i, r := range rs {
+		statuses[i] = r.status()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// status returns the status of the repository. It does not block on a
+// running pull.
+func (r *Repo) status() RepoStatus {
+	s := RepoStatus{
+		Name:     r.Name,
+		URL:      redact(r.URL),
+		Branch:   r.Branch,
+		Path:     r.Path,
+		Interval: r.Interval.Seconds(),
+		Commit:   r.Commit(),
+		Healthy:  r.Healthy(),
+		Paused:   r.Paused(),
+	}
+	if t := r.LastPull(); !t.IsZero() {
+		s.LastPull = &t
+	}
+	if t := r.NextPull(); !t.IsZero() {
+		s.NextPull = &t
+	}
+	if h := r.History(); len(h) > 0 {
+		s.LastResult = &h[len(h)-1]
+	}
+	return s
+}
@@ -0,0 +1,105 @@
+package git
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminStatus(t *testing.T) {
+	healthy := &Repo{URL: "https://github.com/user/healthy", Admin: "test:1"}
+	healthy.setLastPull(time.Now())
+	stale := &Repo{URL: "https://github.com/user/stale", Admin: "test:1", MaxAge: time.Minute}
+	other := &Repo{URL: "https://github.com/user/other", Admin: "test:2"}
+	for _, r := range []*Repo{healthy, stale, other} {
+		registry.add(r)
+		defer registry.remove(r)
+	}
+	a := &adminEndpoint{addr: "test:1"}
+
+	rec := httptest.NewRecorder()
+	a.status(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	var statuses []RepoStatus
+	if err := json.NewDecoder(rec.Body).Decode(&statuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 repos, found %v", len(statuses))
+	}
+	if statuses[0].URL != healthy.URL || statuses[0].LastPull == nil || !statuses[0].Healthy {
+		t.Errorf("Unexpected status %+v", statuses[0])
+	}
+
+	rec = httptest.NewRecorder()
+	a.health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected health to fail with a stale repo, found %v", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	a.pull(rec, httptest.NewRequest(http.MethodGet, "/pull", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected GET /pull to be rejected, found %v", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	a.pull(rec, httptest.NewRequest(http.MethodPost, "/pull?repo="+other.URL, nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected repo of another endpoint not to be found, found %v", rec.Code)
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	repo := &Repo{URL: "https://github.com/user/zones", Admin: "test:3", AdminToken: "secret"}
+	registry.add(repo)
+	defer registry.remove(repo)
+	a := &adminEndpoint{addr: "test:3"}
+
+	for _, header := range []string{"", "Bearer wrong", "secret"} {
+		for _, req := range []*http.Request{httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRequest(http.MethodPost, "/pull", nil)} {
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+			rec := httptest.NewRecorder()
+			if req.Method == http.MethodGet {
+				a.status(rec, req)
+			} else {
+				a.pull(rec, req)
+			}
+			if rec.Code != http.StatusUnauthorized {
+				t.Errorf("Expected %v %v with %q to be unauthorized, found %v", req.Method, req.URL.Path, header, rec.Code)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	a.status(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the status with the token, found %v", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	a.health(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected health without the token, found %v", rec.Code)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	for addr, expected := range map[string]bool{
+		"localhost:8054": true,
+		"127.0.0.1:8054": true,
+		"[::1]:8054":     true,
+		":8054":          false,
+		"0.0.0.0:8054":   false,
+		"192.0.2.1:8054": false,
+		"localhost":      false,
+	} {
+		if loopback(addr) != expected {
+			t.Errorf("Expected loopback(%q) to be %v", addr, expected)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package git
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// backendArchive is the backend downloading archives of the commits of
+// repositories over HTTPS, as served by GitHub, GitLab and Gitea.
+const backendArchive = "archive"
+
+// archiveMeta is the directory of a downloaded checkout, of the archive
+// and raw backends, recording the URL and the commit it was downloaded from.
+const archiveMeta = ".archive"
+
+// archiveClient is the HTTP client of the archive and raw backends.
+var archiveClient = http.DefaultClient
+
+// archiveBackend checks repositories out by downloading the archive of the
+// commit their branch points to, so no git binary is needed.
+type archiveBackend struct{}
+
+// Clone implements Backend.
+func (b archiveBackend) Clone(ctx context.Context, r *Repo, dir string) error {
+	return b.Pull(ctx, r, dir)
+}
+
+// Pull implements Backend. The archive is only downloaded if the branch
+// points to another commit than the checked out one.
+func (archiveBackend) Pull(ctx context.Context, r *Repo, dir string) error {
+	commit, err := archiveRef(ctx, r)
+	if err != nil {
+		return err
+	}
+	if head, _ := os.ReadFile(filepath.Join(dir, archiveMeta, "commit")); string(head) == commit {
+		return nil
+	}
+	return archiveCheckout(ctx, r, dir, commit)
+}
+
+// Head implements Backend.
+func (archiveBackend) Head(ctx context.Context, r *Repo, dir string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, archiveMeta, "commit"))
+	return string(b), err
+}
+
+// Reset implements Backend.
+func (archiveBackend) Reset(ctx context.Context, r *Repo, dir, commit string) error {
+	return archiveCheckout(ctx, r, dir, commit)
+}
+
+// Origin implements Backend.
+func (archiveBackend) Origin(ctx context.Context, r *Repo, dir string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, archiveMeta, "origin"))
+	return string(b), err
+}
+
+// validArchive checks the archive backend can pull r.
+func validArchive(r *Repo) error {
+	u, err := url.Parse(r.URL)
+	if err != nil || u.Scheme != "https" {
+		return fmt.Errorf("the %s backend only supports HTTPS URLs: %s", r.Backend, redact(r.URL))
+	}
+	if r.Branch == latestTag {
+		return fmt.Errorf("the %s backend does not support %s", r.Backend, latestTag)
+	}
+	return nil
+}
+
+// archiveGet requests the path of the repository r, with the credentials
+// of its URL.
+func archiveGet(ctx context.Context, r *Repo, path string) (*http.Response, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, err
+	}
+	path, u.RawQuery, _ = strings.Cut(path, "?")
+	u.Path = strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git") + path
+	return httpGet(ctx, r, u)
+}
+
+// httpGet requests u with the credentials of the URL of r.
+func httpGet(ctx context.Context, r *Repo, u *url.URL) (*http.Response, error) {
+	u.User = nil
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if user, password := r.credentials(); user != "" || password != "" {
+		req.SetBasicAuth(user, password)
+	}
+	r.setHeaders(req)
+	resp, err := r.httpClient(archiveClient).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %v: %v", u, resp.Status)
+	}
+	r.countBody(resp)
+	r.limitBody(ctx, resp)
+	return resp, nil
+}
+
+// archiveRef returns the commit the branch, or tag, of r points to, read
+// from the references the git server advertises.
+func archiveRef(ctx context.Context, r *Repo) (string, error) {
+	resp, err := archiveGet(ctx, r, ".git/info/refs?service=git-upload-pack")
+	if err != nil {
+		return "", fmt.Errorf("cannot list references of %v: %s", r, err)
+	}
+	defer resp.Body.Close()
+
+	refs, err := parseRefs(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot list references of %v: %s", r, err)
+	}
+	// annotated tags are peeled to their commit
+	for _, ref := range []string{"refs/heads/" + r.Branch, "refs/tags/" + r.Branch + "^{}", "refs/tags/" + r.Branch} {
+		if commit, ok := refs[ref]; ok {
+			return commit, nil
+		}
+	}
+	return "", fmt.Errorf("no branch or tag %v in %v", r.Branch, r)
+}
+
+// parseRefs parses the pkt-line encoded reference advertisement of a git
+// server, returning the commits by reference name.
+func parseRefs(rd io.Reader) (map[string]string, error) {
+	refs := map[string]string{}
+	br := bufio.NewReader(rd)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(br, size[:]); err == io.EOF {
+			return refs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseUint(string(size[:]), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pkt-line length %q", size)
+		}
+		if n < 4 {
+			continue // flush packet
+		}
+		line := make([]byte, n-4)
+		if _, err := io.ReadFull(br, line); err != nil {
+			return nil, err
+		}
+		s := strings.TrimSuffix(string(line), "\n")
+		if i := strings.IndexByte(s, 0); i >= 0 {
+			s = s[:i] // capabilities
+		}
+		if hash, name, ok := strings.Cut(s, " "); ok && !strings.HasPrefix(hash, "#") {
+			refs[name] = hash
+		}
+	}
+}
+
+// archiveURL returns the path of the archive of commit, relative to the
+// repository URL: GitLab's for hosts named so, GitHub's and Gitea's otherwise.
+func archiveURL(r *Repo, commit string) string {
+	if u, err := url.Parse(r.URL); err == nil && strings.Contains(u.Hostname(), "gitlab") {
+		return "/-/archive/" + commit + "/" + repoName(r.URL) + "-" + commit + ".tar.gz"
+	}
+	return "/archive/" + commit + ".tar.gz"
+}
+
+// archiveCheckout downloads the archive of commit and makes dir a copy of
+// it, replacing each file atomically.
+func archiveCheckout(ctx context.Context, r *Repo, dir, commit string) error {
+	return downloadCheckout(r, dir, commit, func(tmp string) error {
+		resp, err := archiveGet(ctx, r, archiveURL(r, commit))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		return extractArchive(resp.Body, tmp, commit)
+	})
+}
+
+// downloadCheckout makes dir a copy of the files of commit download writes
+// to the temporary directory tmp, replacing each file atomically.
+func downloadCheckout(r *Repo, dir, commit string, download func(tmp string) error) error {
+	span := r.startSpan(r.Backend)
+	err := func() error {
+		tmp, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+".tmp")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmp)
+
+		if err := download(tmp); err != nil {
+			return err
+		}
+		meta := filepath.Join(tmp, archiveMeta)
+		if err := os.MkdirAll(meta, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(meta, "origin"), []byte(r.URL), 0600); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(meta, "commit"), []byte(commit), 0644); err != nil {
+			return err
+		}
+		return syncDir(tmp, dir)
+	}()
+	finishSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("cannot check %v of %v out: %s", commit, r, err)
+	}
+	return nil
+}
+
+// extractArchive extracts the gzipped tar archive of commit read from rd
+// into dir, without its top directory. The commit is checked against the
+// one git archive records in the archive.
+func extractArchive(rd io.Reader, dir, commit string) error {
+	gz, err := gzip.NewReader(rd)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	verified := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			if c := hdr.PAXRecords["comment"]; c != commit {
+				return fmt.Errorf("archive is of commit %q, not %v", c, commit)
+			}
+			verified = true
+			continue
+		}
+
+		// strip the top directory, named after the repository and commit
+		_, name, _ := strings.Cut(hdr.Name, "/")
+		if name == "" {
+			continue
+		}
+		if !validMappingSource(name) {
+			return fmt.Errorf("invalid path in archive: %v", hdr.Name)
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeSymlink:
+			err = os.Symlink(hdr.Linkname, target)
+		case tar.TypeReg:
+			err = writeFile(tr, target, os.FileMode(hdr.Mode).Perm())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if !verified {
+		return fmt.Errorf("archive does not record its commit")
+	}
+	return nil
+}
+
+// writeFile writes the content of rd to the new file path, with permissions perm.
+func writeFile(rd io.Reader, path string, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rd); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
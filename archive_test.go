@@ -0,0 +1,100 @@
+package git
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// pktLine encodes s as a pkt-line.
+func pktLine(s string) string { return fmt.Sprintf("%04x%s", len(s)+4, s) }
+
+// gitArchive returns a gzipped tar archive as git archive writes it for
+// commit, with files in the zones-COMMIT top directory.
+func gitArchive(t *testing.T, commit, recorded string, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	hdrs := []*tar.Header{
+		{Typeflag: tar.TypeXGlobalHeader, Name: "pax_global_header", PAXRecords: map[string]string{"comment": recorded}, Format: tar.FormatPAX},
+		{Typeflag: tar.TypeDir, Name: "zones-" + commit + "/", Mode: 0755},
+	}
+	for _, h := range hdrs {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "zones-" + commit + "/" + name, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestArchivePull(t *testing.T) {
+	commit := strings.Repeat("a", 40)
+	recorded := commit
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/user/zones.git/info/refs":
+			if req.URL.Query().Get("service") != "git-upload-pack" {
+				http.NotFound(w, req)
+				return
+			}
+			fmt.Fprint(w, pktLine("# service=git-upload-pack\n")+"0000"+
+				pktLine(commit+" HEAD\x00multi_ack\n")+pktLine(commit+" refs/heads/master\n")+"0000")
+		case "/user/zones/archive/" + commit + ".tar.gz":
+			w.Write(gitArchive(t, commit, recorded, map[string]string{"db.example.org": "v1"}))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+	archiveClient = srv.Client()
+	defer func() { archiveClient = http.DefaultClient }()
+
+	repo := &Repo{URL: srv.URL + "/user/zones.git", Path: filepath.Join(t.TempDir(), "zones"), Branch: "master", Backend: backendArchive}
+	if err := validArchive(repo); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if repo.lastCommit != commit {
+		t.Errorf("Expected commit %v, found %v", commit, repo.lastCommit)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v1" {
+		t.Errorf("Expected extracted file content v1, found %q", b)
+	}
+
+	// an existing checkout is recognized
+	reloaded := &Repo{URL: repo.URL, Path: repo.Path, Branch: "master", Backend: backendArchive}
+	if err := reloaded.Prepare(); err != nil || !reloaded.pulled {
+		t.Errorf("Expected existing checkout to be reused, found %v", err)
+	}
+
+	// the archive must be of the advertised commit
+	commit, recorded = strings.Repeat("b", 40), strings.Repeat("c", 40)
+	if err := repo.pull(context.Background()); err == nil {
+		t.Error("Expected archive of another commit to fail")
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v1" {
+		t.Errorf("Expected checkout to be left untouched, found %q", b)
+	}
+}
@@ -0,0 +1,105 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Assembly is a zone file assembled from fragments of the repository.
+type Assembly struct {
+	Zone    string   // Origin of the zone
+	File    string   // Zone file written, relative to the path of the repository
+	Sources []string // Globs of the fragments in the repository, in order
+}
+
+// assemble writes the zone files of Assemblies, failing if one is not a
+// valid zone.
+func (r *Repo) assemble() error {
+	for _, a := range r.Assemblies {
+		if err := r.assembleZone(a); err != nil {
+			return fmt.Errorf("cannot assemble %v: %s", a.File, err)
+		}
+	}
+	return nil
+}
+
+// assembleZone writes the zone file of a, the concatenation of the
+// fragments matching its sources. Each fragment starts at the origin of
+// the zone, and their $INCLUDE directives are flattened with Includes.
+func (r *Repo) assembleZone(a Assembly) error {
+	dir := r.workDir()
+	origin := dns.Fqdn(a.Zone)
+
+	var buf bytes.Buffer
+	seen := map[string]bool{}
+	for _, source := range a.Sources {
+		matches, err := filepath.Glob(filepath.Join(dir, source))
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 && !hasMeta(source) {
+			return fmt.Errorf("no fragment %v", source)
+		}
+		sort.Strings(matches)
+		for _, path := range matches {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			buf.WriteString("$ORIGIN " + origin + "\n")
+			if r.Includes {
+				if _, err := flatten(&buf, b, evalPath(path), evalPath(dir), origin, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			buf.Write(b)
+			if len(b) > 0 && b[len(b)-1] != '\n' {
+				buf.WriteByte('\n')
+			}
+		}
+	}
+	if err := validZone(buf.Bytes(), origin); err != nil {
+		return err
+	}
+
+	dst := filepath.Join(r.Path, a.File)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return replaceFile(&buf, dst, 0644)
+}
+
+// validZone checks the zone file b of origin parses and has an SOA record
+// at its origin.
+func validZone(b []byte, origin string) error {
+	zp := dns.NewZoneParser(bytes.NewReader(b), origin, "")
+	soa := false
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if rr.Header().Rrtype == dns.TypeSOA && dns.CanonicalName(rr.Header().Name) == dns.CanonicalName(origin) {
+			soa = true
+		}
+	}
+	if err := zp.Err(); err != nil {
+		return err
+	}
+	if !soa {
+		return fmt.Errorf("no SOA record for %v", origin)
+	}
+	return nil
+}
+
+// hasMeta reports whether the glob has special characters.
+func hasMeta(glob string) bool {
+	return strings.ContainsAny(glob, `*?[\`)
+}
@@ -0,0 +1,59 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAssemble(t *testing.T) {
+	dir := t.TempDir()
+	repo := &Repo{
+		Path:    filepath.Join(dir, "zones"),
+		Subpath: "dns",
+		Assemblies: []Assembly{
+			{Zone: "example.org", File: "db.example.org", Sources: []string{"fragments/base.zone", "fragments/services/*.zone", "fragments/*.zone"}},
+		},
+	}
+	repo.lastCommit = "c1"
+	writeFiles(t, repo.workDir(), map[string]string{
+		"dns/db.example.net":           "example.net",
+		"fragments/base.zone":          "@ 3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 3600\n@ 3600 IN NS ns1",
+		"fragments/services/mail.zone": "$ORIGIN mail.example.org.\n@ 300 IN A 192.0.2.25\n",
+		"fragments/services/www.zone":  "www 300 IN A 192.0.2.80\n",
+		"fragments/services/notes.txt": "not a fragment",
+		"fragments/zz.zone":            "ns1 300 IN A 192.0.2.53\n",
+	})
+	if err := repo.publish(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "$ORIGIN example.org.\n@ 3600 IN SOA ns1 hostmaster 1 7200 3600 1209600 3600\n@ 3600 IN NS ns1\n" +
+		"$ORIGIN example.org.\n$ORIGIN mail.example.org.\n@ 300 IN A 192.0.2.25\n" +
+		"$ORIGIN example.org.\nwww 300 IN A 192.0.2.80\n" +
+		"$ORIGIN example.org.\nns1 300 IN A 192.0.2.53\n"
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != expected {
+		t.Errorf("Expected the zone %q, found %q", expected, b)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.net")); string(b) != "example.net" {
+		t.Errorf("Expected the subpath to be published, found %q", b)
+	}
+
+	// an invalid zone fails the pull and the assembled one is kept
+	writeFiles(t, repo.workDir(), map[string]string{"fragments/zz.zone": "ns1 IN A not-an-address\n"})
+	repo.lastCommit = "c2"
+	if err := repo.publish(); err == nil || !strings.Contains(err.Error(), "db.example.org") {
+		t.Errorf("Expected an invalid zone to fail, found %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != expected {
+		t.Errorf("Expected the previous zone to be kept, found %q", b)
+	}
+
+	// and so does a zone without SOA or a missing fragment
+	for _, sources := range [][]string{{"fragments/services/*.zone"}, {"fragments/missing.zone"}} {
+		repo.Assemblies[0].Sources = sources
+		if err := repo.assemble(); err == nil {
+			t.Errorf("Expected assembling %v to fail", sources)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package git
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// auditMu serializes writes to audit files.
+var auditMu sync.Mutex
+
+// audit appends e to the audit log of r, if one is configured.
+func (r *Repo) audit(e PullEvent) {
+	if r.AuditLog == "" {
+		return
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Errorf("audit log: %s", err)
+		return
+	}
+
+	if r.AuditLog == "syslog" {
+		err = writeSyslog(b, e.Error != "")
+	} else {
+		err = appendLine(r.AuditLog, b)
+	}
+	if err != nil {
+		log.Errorf("audit log: %s", err)
+	}
+}
+
+// appendLine appends b and a newline to the file at path. The file is
+// opened for every write so it can be rotated externally.
+func appendLine(path string, b []byte) error {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
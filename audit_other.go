@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package git
+
+import "errors"
+
+// writeSyslog is not supported on this platform.
+func writeSyslog(b []byte, failed bool) error {
+	return errors.New("syslog is not supported on this platform")
+}
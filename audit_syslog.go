@@ -0,0 +1,34 @@
+//go:build !windows && !plan9
+
+package git
+
+import (
+	"log/syslog"
+	"sync"
+)
+
+var (
+	// syslogWriter is the connection to the local syslog daemon, opened by
+	// the first write and shared by every repository.
+	syslogWriter *syslog.Writer
+	syslogMu     sync.Mutex
+)
+
+// writeSyslog sends b to the local syslog daemon. The connection is kept
+// open, log/syslog connecting again if the daemon restarted.
+func writeSyslog(b []byte, failed bool) error {
+	syslogMu.Lock()
+	defer syslogMu.Unlock()
+
+	if syslogWriter == nil {
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "coredns-git")
+		if err != nil {
+			return err
+		}
+		syslogWriter = w
+	}
+	if failed {
+		return syslogWriter.Err(string(b))
+	}
+	return syslogWriter.Info(string(b))
+}
@@ -0,0 +1,33 @@
+package git
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	repo := &Repo{URL: "https://github.com/user/repo", AuditLog: path}
+
+	repo.audit(PullEvent{Repo: repo.URL, Trigger: sourceStartup, NewCommit: "a"})
+	repo.audit(PullEvent{Repo: repo.URL, Trigger: sourceInterval, OldCommit: "a", Error: "failed"})
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 audit lines, found %v", len(lines))
+	}
+	var e PullEvent
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Trigger != sourceInterval || e.Error != "failed" {
+		t.Errorf("Unexpected audit event %+v", e)
+	}
+}
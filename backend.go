@@ -0,0 +1,144 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Backend clones and pulls repositories. Repositories select it with the
+// backend option, by the name it is registered with. Its methods are
+// called one at a time for a repository, with ctx done once the pull must
+// be abandoned. The Branch of the repository may be {latest}, for its most
+// recent tag.
+type Backend interface {
+	// Clone clones r into the empty directory dir.
+	Clone(ctx context.Context, r *Repo, dir string) error
+
+	// Pull updates the checkout of r at dir to the most recent commit of its branch.
+	Pull(ctx context.Context, r *Repo, dir string) error
+
+	// Head returns the hash of the commit checked out at dir.
+	Head(ctx context.Context, r *Repo, dir string) (string, error)
+
+	// Reset checks commit out at dir, discarding any local change.
+	Reset(ctx context.Context, r *Repo, dir, commit string) error
+
+	// Origin returns the URL the checkout at dir was cloned from.
+	Origin(ctx context.Context, r *Repo, dir string) (string, error)
+}
+
+// backendExec is the default backend, running the git command.
+const backendExec = "exec"
+
+var (
+	backends = map[string]Backend{
+		backendExec:    execBackend{},
+		backendGoGit:   goGitBackend{},
+		backendHg:      hgBackend{},
+		backendArchive: archiveBackend{},
+		backendRaw:     rawBackend{},
+	}
+	backendsMu sync.RWMutex
+)
+
+// RegisterBackend makes b available to the backend option as name. It is
+// meant to be called from the init function of the package providing b.
+// It panics if name is already registered.
+func RegisterBackend(name string, b Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if _, ok := backends[name]; ok {
+		panic(fmt.Sprintf("git: backend %v registered twice", name))
+	}
+	backends[name] = b
+}
+
+// lookupBackend returns the backend registered as name.
+func lookupBackend(name string) (Backend, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	b, ok := backends[name]
+	return b, ok
+}
+
+// backend returns the backend of the repository.
+func (r *Repo) backend() Backend {
+	if b, ok := lookupBackend(r.Backend); ok {
+		return b
+	}
+	return execBackend{}
+}
+
+// execBackend pulls repositories with the git command.
+type execBackend struct{}
+
+// Clone implements Backend.
+func (execBackend) Clone(ctx context.Context, r *Repo, dir string) error {
+	remote := r.remoteURL()
+	args := r.CloneArgs
+	if r.Depth > 0 {
+		args = append([]string{"--depth", strconv.Itoa(r.Depth)}, args...)
+	}
+	// git reports the bytes it fetched with their progress
+	params := append([]string{"clone", "--progress", "-b", r.Branch}, append(args, remote, dir)...)
+	if r.Branch == latestTag {
+		params = append([]string{"clone", "--progress"}, append(args, remote, dir)...)
+	}
+	if r.Subpath != "" {
+		params = append([]string{"clone", "--sparse"}, params[1:]...)
+	}
+
+	if err := r.gitCmd(ctx, params, ""); err != nil {
+		return err
+	}
+	// a checkout cloned from a mirror is still one of URL
+	if remote != r.URL {
+		if err := r.gitCmd(ctx, []string{"remote", "set-url", "origin", r.URL}, dir); err != nil {
+			return err
+		}
+	}
+	return r.sparseCheckout(ctx)
+}
+
+// Pull implements Backend.
+func (execBackend) Pull(ctx context.Context, r *Repo, dir string) error {
+	// if latest tag config is set
+	if r.Branch == latestTag {
+		if err := r.checkoutLatestTag(ctx); err != nil {
+			log.Errorf("Error retrieving latest tag: %s", redact(err.Error()))
+			return err
+		}
+		return nil
+	}
+
+	params := append([]string{"pull", "--progress"}, append(r.PullArgs, r.fetchRemote(), r.Branch)...)
+	return r.withHistory(ctx, params, dir)
+}
+
+// fetchRemote returns the remote the checkout is pulled from: origin, or
+// the mirror being pulled from.
+func (r *Repo) fetchRemote() string {
+	if r.remoteURL() != r.URL {
+		return r.remoteURL()
+	}
+	return "origin"
+}
+
+// Head implements Backend.
+func (execBackend) Head(ctx context.Context, r *Repo, dir string) (string, error) {
+	return r.mostRecentCommit(ctx)
+}
+
+// Reset implements Backend.
+func (execBackend) Reset(ctx context.Context, r *Repo, dir, commit string) error {
+	return r.withHistory(ctx, []string{"reset", "--hard", commit}, dir)
+}
+
+// Origin implements Backend.
+func (execBackend) Origin(ctx context.Context, r *Repo, dir string) (string, error) {
+	return runCmdOutput(ctx, "git", []string{"config", "--get", "remote.origin.url"}, dir)
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/caddy"
+)
+
+// countingBackend is a backend counting its calls, on a fake checkout.
+type countingBackend struct{ clones, pulls int }
+
+func (b *countingBackend) Clone(ctx context.Context, r *Repo, dir string) error {
+	b.clones++
+	return nil
+}
+
+func (b *countingBackend) Pull(ctx context.Context, r *Repo, dir string) error {
+	b.pulls++
+	return nil
+}
+
+func (b *countingBackend) Head(ctx context.Context, r *Repo, dir string) (string, error) {
+	return "commit" + string(rune('0'+b.pulls)), nil
+}
+
+func (b *countingBackend) Reset(ctx context.Context, r *Repo, dir, commit string) error { return nil }
+
+func (b *countingBackend) Origin(ctx context.Context, r *Repo, dir string) (string, error) {
+	return r.URL, nil
+}
+
+func TestRegisterBackend(t *testing.T) {
+	b := &countingBackend{}
+	RegisterBackend("counting", b)
+
+	c := caddy.NewTestController("dns", `git https://github.com/user/repo `+filepath.Join(t.TempDir(), "zones")+` {
+		backend counting
+	}`)
+	git, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	repo := git.Repo(0)
+
+	for i := 0; i < 2; i++ {
+		if err := repo.pull(context.Background()); err != nil {
+			t.Fatalf("Expected no error, found %v", err)
+		}
+	}
+	if b.clones != 1 || b.pulls != 1 {
+		t.Errorf("Expected 1 clone and 1 pull, found %v and %v", b.clones, b.pulls)
+	}
+	if repo.lastCommit != "commit1" {
+		t.Errorf("Expected commit1, found %v", repo.lastCommit)
+	}
+
+	c = caddy.NewTestController("dns", `git https://github.com/user/repo /tmp/git1 {
+		backend svn
+	}`)
+	if _, err := parse(c); err == nil {
+		t.Error("Expected unknown backend to fail")
+	}
+}
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+)
+
+// Backup is a directory of zone files snapshotted into the repository.
+type Backup struct {
+	Dir      string        // Directory snapshotted, e.g. of zones transferred by secondaries
+	Target   string        // Directory of the repository it is snapshotted to
+	Interval time.Duration // Interval between snapshots
+}
+
+// backup snapshots the directory of b into the checkout, then commits and
+// pushes the snapshot if it changed.
+func (r *Repo) backup(ctx context.Context, b Backup) error {
+	r.Lock()
+	defer r.Unlock()
+	if !r.pulled {
+		return nil
+	}
+	ctx = r.commandContext(ctx)
+
+	unlock, err := r.lockCheckout(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot lock the checkout of %v: %s", r, err)
+	}
+	defer unlock()
+
+	dir := r.workDir()
+	if err := syncDir(b.Dir, filepath.Join(dir, b.Target)); err != nil {
+		return fmt.Errorf("cannot snapshot %v into %v: %s", b.Dir, r, err)
+	}
+	target := filepath.ToSlash(b.Target)
+	if err := r.gitCmd(ctx, []string{"add", "--all", "--", target}, dir); err != nil {
+		return err
+	}
+	status, err := r.gitOutput(ctx, []string{"status", "--porcelain", "--", target}, dir)
+	if err != nil {
+		return fmt.Errorf("cannot list the changes of the snapshot of %v: %s", b.Dir, err)
+	}
+	if status == "" {
+		return nil
+	}
+	msg, err := r.commitMessage(messageBackup, commitData{Dir: b.Dir, Changes: status})
+	if err != nil {
+		return err
+	}
+	if err := r.gitCmd(ctx, []string{"commit", "--quiet", "-m", msg, "--", target}, dir); err != nil {
+		return err
+	}
+	log.Infof("committed backup of %v in %v", b.Dir, r)
+	return r.committed(ctx, dir)
+}
+
+// startBackup starts a background service snapshotting the directory of
+// b into the repository every b.Interval.
+func startBackup(repo *Repo, b Backup) {
+	service := &repoService{
+		repo,
+		time.NewTicker(b.Interval),
+		make(chan struct{}),
+		make(chan struct{}),
+	}
+	go func(s *repoService) {
+		defer close(s.done)
+		defer s.ticker.Stop()
+		for {
+			select {
+			case <-s.ticker.C:
+				if err := repo.backup(repo.lifetime(), b); err != nil {
+					log.Warning(redactError(err))
+				}
+			case <-s.halt:
+				return
+			}
+		}
+	}(service)
+
+	// add to services to make it stoppable
+	Services.add(service)
+}
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	work := newOrigin(t, map[string]string{"README": "zones"})
+	origin := filepath.Join(dir, "origin.git")
+	runGit(t, dir, "clone", "-q", "--bare", work, origin)
+
+	secondary := filepath.Join(dir, "secondary")
+	writeFiles(t, secondary, map[string]string{"db.example.org": "v1", "db.example.net": "v1"})
+	b := Backup{Dir: secondary, Target: "backup"}
+	repo := &Repo{URL: origin, Path: filepath.Join(dir, "zones"), Branch: "master", Push: true, Backups: []Backup{b}}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	show := func(rev string) string {
+		out, _ := exec.Command("git", "--git-dir", origin, "show", rev).Output()
+		return string(out)
+	}
+	if err := repo.backup(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if show("master:backup/db.example.org") != "v1" || show("master:backup/db.example.net") != "v1" {
+		t.Errorf("Expected the snapshot to be pushed")
+	}
+	commit := repo.lastCommit
+
+	// nothing changed
+	if err := repo.backup(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if repo.lastCommit != commit {
+		t.Errorf("Expected no commit without changes")
+	}
+
+	writeFiles(t, secondary, map[string]string{"db.example.org": "v2"})
+	if err := os.Remove(filepath.Join(secondary, "db.example.net")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.backup(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if show("master:backup/db.example.org") != "v2" || show("master:backup/db.example.net") != "" {
+		t.Errorf("Expected the new snapshot to be pushed")
+	}
+	if msg := show("master"); !strings.Contains(msg, "Backup of "+secondary) {
+		t.Errorf("Expected a backup commit, found %q", msg)
+	}
+}
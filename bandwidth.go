@@ -0,0 +1,236 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// parseRate parses a number of bytes per second, a size as of parseSize
+// optionally followed by B and /s, e.g. 5MB/s.
+func parseRate(s string) (int64, error) {
+	size := strings.TrimSuffix(s, "/s")
+	if n := len(size); n > 1 && size[n-1]&^0x20 == 'B' {
+		size = size[:n-1]
+	}
+	rate, err := parseSize(size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth: %s", s)
+	}
+	return rate, nil
+}
+
+// limiter limits the rate bytes are transferred at, shared by all the
+// transfers of a repository.
+type limiter struct {
+	rate int64 // bytes per second
+
+	mu   sync.Mutex
+	next time.Time // time the bytes transferred so far are allowed at
+}
+
+// wait waits until n more bytes may be transferred, or ctx is done.
+func (l *limiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.rate))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitedReader reads from r at the rate of l.
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *limiter
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	// don't wait for more than a second at once
+	if int64(len(p)) > lr.l.rate {
+		p = p[:lr.l.rate]
+	}
+	n, err := lr.r.Read(p)
+	if werr := lr.l.wait(lr.ctx, n); werr != nil && err == nil {
+		err = werr
+	}
+	return n, err
+}
+
+// limitedBody is the body of a response read at the rate of a limiter.
+type limitedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// limit returns the limiter of the transfers of r, or nil if Bandwidth
+// is not limited.
+func (r *Repo) limit() *limiter {
+	if r.Bandwidth == 0 {
+		return nil
+	}
+	if r.limiter == nil {
+		r.limiter = &limiter{rate: r.Bandwidth}
+	}
+	return r.limiter
+}
+
+// limitBody makes the body of resp read at most at Bandwidth bytes per
+// second.
+func (r *Repo) limitBody(ctx context.Context, resp *http.Response) {
+	if l := r.limit(); l != nil {
+		resp.Body = limitedBody{&limitedReader{ctx, resp.Body, l}, resp.Body}
+	}
+}
+
+// limitedTransport is an http.RoundTripper reading the bodies of the
+// responses of its transport at the rate of a limiter.
+type limitedTransport struct {
+	transport http.RoundTripper
+	l         *limiter
+}
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = limitedBody{&limitedReader{req.Context(), resp.Body, t.l}, resp.Body}
+	return resp, nil
+}
+
+// throttleProxy is an HTTP proxy relaying what remotes send at the rate
+// of a limiter: HTTPS through CONNECT tunnels, HTTP by forwarding the
+// requests. Listening on the loopback interface, it only relays to the
+// hosts of the remotes, not to be an open proxy of the host.
+type throttleProxy struct {
+	l       *limiter
+	hosts   map[string]bool
+	forward *httputil.ReverseProxy
+}
+
+func newThrottleProxy(l *limiter, hosts map[string]bool) *throttleProxy {
+	return &throttleProxy{
+		l:     l,
+		hosts: hosts,
+		forward: &httputil.ReverseProxy{
+			// the URL of requests to a proxy is absolute already
+			Rewrite:   func(*httputil.ProxyRequest) {},
+			Transport: limitedTransport{http.DefaultTransport, l},
+		},
+	}
+}
+
+func (p *throttleProxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	host := req.URL.Hostname()
+	if req.Method == http.MethodConnect {
+		host, _, _ = net.SplitHostPort(req.Host)
+	}
+	if !p.hosts[strings.ToLower(host)] {
+		http.Error(w, "host not allowed", http.StatusForbidden)
+		return
+	}
+	if req.Method != http.MethodConnect {
+		p.forward.ServeHTTP(w, req)
+		return
+	}
+
+	upstream, err := (&net.Dialer{}).DialContext(req.Context(), "tcp", req.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "cannot tunnel", http.StatusInternalServerError)
+		return
+	}
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+
+	go func(r *bufio.Reader) {
+		io.Copy(upstream, r)
+		if tcp, ok := upstream.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+	}(buf.Reader)
+	io.Copy(conn, &limitedReader{context.Background(), upstream, p.l})
+}
+
+// startThrottle starts the proxy git connects to HTTP(S) remotes through
+// to be limited to Bandwidth, unless it is running already. It is stopped
+// with r.
+func (r *Repo) startThrottle() error {
+	l := r.limit()
+	if l == nil || r.throttle != "" || r.Backend != "" && r.Backend != backendExec {
+		return nil
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("cannot start the proxy limiting the bandwidth of %v: %s", r, err)
+	}
+	hosts := map[string]bool{}
+	for _, u := range append([]string{r.URL}, r.Mirrors...) {
+		_, host := remoteOf(u)
+		hosts[host] = true
+	}
+	srv := &http.Server{Handler: newThrottleProxy(l, hosts), ReadHeaderTimeout: forgeTimeout}
+	go srv.Serve(ln)
+	go func(ctx context.Context) {
+		<-ctx.Done()
+		srv.Close()
+	}(r.lifetime())
+	r.throttle = "http://" + ln.Addr().String()
+	return nil
+}
+
+// validBandwidth checks the bandwidth of the remotes of r can be limited:
+// git is only limited when connecting to HTTP(S) remotes.
+func validBandwidth(r *Repo) error {
+	for _, u := range append([]string{r.URL}, r.Mirrors...) {
+		switch scheme, _ := remoteOf(u); scheme {
+		case "http", "https", "file":
+		default:
+			return fmt.Errorf("max_bandwidth only limits HTTP(S) remotes: %s", redact(u))
+		}
+	}
+	return nil
+}
+
+// throttleConfig returns the configuration of git connecting to HTTP(S)
+// remotes through the proxy limiting them to Bandwidth.
+func (r *Repo) throttleConfig() []string {
+	if r.throttle == "" {
+		return nil
+	}
+	return []string{"-c", "http.proxy=" + r.throttle}
+}
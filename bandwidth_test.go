@@ -0,0 +1,117 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5MB/s", 5 << 20},
+		{"512K/s", 512 << 10},
+		{"1G", 1 << 30},
+		{"1000", 1000},
+		{"B/s", 0},
+		{"fast", 0},
+		{"-1MB/s", 0},
+	}
+	for _, test := range tests {
+		rate, err := parseRate(test.input)
+		if test.expected == 0 {
+			if err == nil {
+				t.Errorf("Expected error for %q, found %d", test.input, rate)
+			}
+			continue
+		}
+		if rate != test.expected {
+			t.Errorf("Expected %d for %q, found %d (%v)", test.expected, test.input, rate, err)
+		}
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	l := &limiter{rate: 100 << 10}
+	start := time.Now()
+	n, err := io.Copy(io.Discard, &limitedReader{context.Background(), bytes.NewReader(make([]byte, 50<<10)), l})
+	if err != nil || n != 50<<10 {
+		t.Fatalf("Expected to read 50K, found %d (%v)", n, err)
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("Expected 50K to be read in about 500ms at 100K/s, took %v", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := io.Copy(io.Discard, &limitedReader{ctx, bytes.NewReader(make([]byte, 50<<10)), l}); err == nil {
+		t.Error("Expected reading to stop once the context is done")
+	}
+}
+
+func TestThrottlePull(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	src, bare := newOrigin(t, map[string]string{"db.example.org": "v1"}), filepath.Join(dir, "zones.git")
+	runGit(t, dir, "clone", "-q", "--bare", src, bare)
+	runGit(t, bare, "update-server-info")
+
+	// git talks the dumb HTTP protocol to a file server
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	repo := &Repo{URL: srv.URL + "/zones.git", Path: filepath.Join(dir, "zones"), Branch: "master", Bandwidth: 1 << 20}
+	defer repo.stop()
+	if err := repo.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected pull through the proxy to succeed, found %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v1" {
+		t.Errorf("Expected file content v1, found %q", b)
+	}
+	if repo.throttle == "" {
+		t.Fatal("Expected the proxy to be started")
+	}
+
+	// the proxy only relays to the hosts of the remotes
+	proxy, _ := url.Parse(repo.throttle)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxy)}}
+	resp, err := client.Get("http://other.example.org/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected requests to other hosts to be forbidden, found %v", resp.Status)
+	}
+
+	// HTTPS is tunneled
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "tunneled")
+	}))
+	defer tlsSrv.Close()
+	transport := tlsSrv.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxy)
+	resp, err = (&http.Client{Transport: transport}).Get(tlsSrv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if b, _ := io.ReadAll(resp.Body); string(b) != "tunneled" {
+		t.Errorf("Expected response through the tunnel, found %q", b)
+	}
+}
@@ -0,0 +1,96 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// bundleURL returns the HTTPS URL of the bundle of branch under the object
+// store prefix: s3://BUCKET/PREFIX and gs://BUCKET/PREFIX are the public
+// endpoints of the bucket, and HTTP(S) prefixes are used as is.
+func bundleURL(prefix, branch string) (string, error) {
+	u, err := url.Parse(prefix)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "s3":
+		u.Scheme, u.Host = "https", u.Host+".s3.amazonaws.com"
+	case "gs":
+		u.Scheme, u.Host, u.Path = "https", "storage.googleapis.com", "/"+u.Host+u.Path
+	case "http", "https":
+	default:
+		return "", fmt.Errorf("unsupported bundle mirror: %s", redact(prefix))
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + branch + ".bundle"
+	return u.String(), nil
+}
+
+// pullBundle checks Branch out from its bundle under the Bundles prefix,
+// as created by `git bundle create BRANCH.bundle BRANCH`. A clone from the
+// bundle keeps URL as its origin, so the repository is pulled from it
+// again once reachable.
+func (r *Repo) pullBundle(ctx context.Context) error {
+	u, err := bundleURL(r.Bundles, r.Branch)
+	if err != nil {
+		return err
+	}
+	dir := r.workDir()
+	bundle, err := os.CreateTemp(filepath.Dir(dir), "."+filepath.Base(dir)+".bundle")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(bundle.Name())
+	if err := r.RunAs.chown(bundle.Name()); err != nil {
+		return err
+	}
+
+	span := r.startSpan("bundle")
+	err = func() error {
+		defer bundle.Close()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := archiveClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("GET %v: %v", redact(u), resp.Status)
+		}
+		r.countBody(resp)
+		r.limitBody(ctx, resp)
+		_, err = bundle.ReadFrom(resp.Body)
+		return err
+	}()
+	finishSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("cannot download bundle of %v: %s", r, err)
+	}
+
+	if !r.pulled {
+		params := []string{"clone", "-b", r.Branch, bundle.Name(), dir}
+		if r.Subpath != "" {
+			params = []string{"clone", "--sparse", "-b", r.Branch, bundle.Name(), dir}
+		}
+		if err := r.gitCmd(ctx, params, ""); err != nil {
+			return err
+		}
+		r.pulled = true
+		if err := r.gitCmd(ctx, []string{"remote", "set-url", "origin", r.URL}, dir); err != nil {
+			return err
+		}
+		return r.sparseCheckout(ctx)
+	}
+	if err := r.gitCmd(ctx, []string{"fetch", bundle.Name(), r.Branch}, dir); err != nil {
+		return err
+	}
+	return r.gitCmd(ctx, []string{"merge", "--ff-only", "FETCH_HEAD"}, dir)
+}
@@ -0,0 +1,77 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundleURL(t *testing.T) {
+	tests := []struct {
+		prefix   string
+		expected string
+	}{
+		{"s3://bucket/zones", "https://bucket.s3.amazonaws.com/zones/master.bundle"},
+		{"gs://bucket/zones/", "https://storage.googleapis.com/bucket/zones/master.bundle"},
+		{"https://cdn.example.org/zones", "https://cdn.example.org/zones/master.bundle"},
+		{"ftp://example.org/zones", ""},
+	}
+	for i, test := range tests {
+		u, err := bundleURL(test.prefix, "master")
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("Test %d: expected error, found %v", i, u)
+			}
+			continue
+		}
+		if u != test.expected {
+			t.Errorf("Test %d: expected %v, found %v (%v)", i, test.expected, u, err)
+		}
+	}
+}
+
+func TestBundlePull(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	src := newOrigin(t, map[string]string{"db.example.org": "v1"})
+	git := func(dir string, args ...string) { runGit(t, dir, args...) }
+	git(src, "bundle", "create", filepath.Join(dir, "master.bundle"), "master")
+
+	srv := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer srv.Close()
+
+	repo := &Repo{URL: filepath.Join(dir, "missing.git"), Path: filepath.Join(dir, "zones"), Branch: "master", Bundles: srv.URL}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected fallback to the bundle, found %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v1" {
+		t.Errorf("Expected content v1, found %q", b)
+	}
+	if origin, _ := repo.originURL(); origin != repo.URL {
+		t.Errorf("Expected origin %v, found %v", repo.URL, origin)
+	}
+
+	// a newer bundle is fetched into the checkout
+	writeFiles(t, src, map[string]string{"db.example.org": "v2"})
+	git(src, "commit", "-q", "-am", "v2")
+	git(src, "bundle", "create", filepath.Join(dir, "master.bundle"), "master")
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v2" {
+		t.Errorf("Expected content v2, found %q", b)
+	}
+
+	// without the remote, a missing bundle fails the pull
+	repo.Bundles = srv.URL + "/missing"
+	if err := repo.pull(context.Background()); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected missing bundle to fail, found %v", err)
+	}
+}
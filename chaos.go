@@ -0,0 +1,35 @@
+package git
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// chaosName is the CHAOS class name answered with the commit of each repository.
+const chaosName = "version.git.bind."
+
+// chaosTXT returns the TXT records answering a CHAOS query for chaosName,
+// one per repository with chaos enabled.
+func (h Handler) chaosTXT(q dns.Question) []dns.RR {
+	var rrs []dns.RR
+	for _, r := range h.Repos {
+		if !r.Chaos {
+			continue
+		}
+		rrs = append(rrs, &dns.TXT{
+			Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeTXT, Class: dns.ClassCHAOS, Ttl: 0},
+			Txt: []string{fmt.Sprintf("%s %s %s", r, r.Commit(), formatTime(r.LastPull()))},
+		})
+	}
+	return rrs
+}
+
+// formatTime formats t as RFC 3339, or "-" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.UTC().Format(time.RFC3339)
+}
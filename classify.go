@@ -0,0 +1,122 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Classes of the errors of failed pulls.
+const (
+	classTransient = "transient" // network failure or timeout, likely to succeed when retried
+	classAuth      = "auth"      // credentials missing or rejected by the remote
+	classRef       = "ref"       // branch, tag or commit not found in the repository
+	classPermanent = "permanent" // repository not found or checkout rejected
+	classUnknown   = "unknown"   // none of the above
+)
+
+// maxBackoff is the longest periodic pulls are skipped for after pulls
+// failing with an error which retrying does not fix.
+const maxBackoff = time.Hour
+
+// errorPatterns are the messages of git, hg, go-git and ssh identifying
+// each class of errors, matched in lower case in this order.
+var errorPatterns = []struct {
+	class    string
+	patterns []string
+}{
+	{classAuth, []string{
+		"authentication failed",
+		"authentication required",
+		"authorization failed",
+		"permission denied (publickey",
+		"could not read username",
+		"could not read password",
+		"host key verification failed",
+		"returned error: 401",
+		"returned error: 403",
+		"http error 401",
+		"http error 403",
+	}},
+	{classRef, []string{
+		"remote branch",
+		"couldn't find remote ref",
+		"did not match any",
+		"unknown revision",
+		"reference not found",
+		"no tags found",
+		"invalid reference",
+	}},
+	{classPermanent, []string{
+		"does not exist",
+		"repository not found",
+		"not a git repository",
+		"not found",
+		"returned error: 404",
+		"outside of base_dir",
+	}},
+	{classTransient, []string{
+		"timed out",
+		"timeout",
+		"connection refused",
+		"connection reset",
+		"could not resolve host",
+		"temporary failure in name resolution",
+		"network is unreachable",
+		"no route to host",
+		"early eof",
+		"rpc failed",
+		"the remote end hung up",
+		"returned error: 5",
+		"http error 5",
+		"signal: killed",
+		"cannot lock",
+	}},
+}
+
+// errorClass returns the class of err, the error of a failed pull.
+func errorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, errQuota):
+		return classPermanent
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return classTransient
+	}
+	msg := strings.ToLower(err.Error())
+	for _, p := range errorPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(msg, pattern) {
+				return p.class
+			}
+		}
+	}
+	return classUnknown
+}
+
+// persistent reports whether errors of class fail again when retried,
+// until the repository, its credentials or the configuration are fixed.
+func persistent(class string) bool {
+	return class == classAuth || class == classRef || class == classPermanent
+}
+
+// backOff makes the periodic pulls of r skip the next pulls after a pull
+// failed with an error of class, Interval longer after every persistent
+// failure in a row, up to maxBackoff. A transient failure, or a successful
+// pull, lets the next periodic pull run.
+func (r *Repo) backOff(class string, now time.Time) {
+	if !persistent(class) || r.Interval <= 0 {
+		r.backoff = time.Time{}
+		return
+	}
+	delay := r.Interval
+	for i := 1; i < r.failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	r.backoff = now.Add(delay)
+}
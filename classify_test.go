@@ -0,0 +1,60 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err   error
+		class string
+	}{
+		{nil, ""},
+		{errors.New("git fetch: exit status 128: fatal: unable to access 'https://example.org/zones.git/': Could not resolve host: example.org"), classTransient},
+		{errors.New("git fetch: exit status 128: fatal: unable to access 'https://example.org/zones.git/': The requested URL returned error: 502"), classTransient},
+		{fmt.Errorf("cannot pull: %w", context.DeadlineExceeded), classTransient},
+		{errors.New("git clone: exit status 128: fatal: Authentication failed for 'https://example.org/zones.git/'"), classAuth},
+		{errors.New("git clone: exit status 128: git@example.org: Permission denied (publickey)."), classAuth},
+		{errors.New("git clone: exit status 128: fatal: Remote branch main not found in upstream origin"), classRef},
+		{errors.New("couldn't find remote ref refs/heads/main"), classRef},
+		{errors.New("git clone: exit status 128: fatal: repository '/tmp/missing.git' does not exist"), classPermanent},
+		{fmt.Errorf("checkout of zones is %w", errQuota), classPermanent},
+		{errors.New("something else"), classUnknown},
+	}
+	for _, test := range tests {
+		if class := errorClass(test.err); class != test.class {
+			t.Errorf("Expected class %q for %v, got %q", test.class, test.err, class)
+		}
+	}
+}
+
+func TestBackOff(t *testing.T) {
+	now := time.Now()
+	repo := &Repo{Interval: 10 * time.Minute}
+	tests := []struct {
+		class string
+		delay time.Duration
+	}{
+		{classTransient, 0},
+		{classAuth, 10 * time.Minute},
+		{classAuth, 20 * time.Minute},
+		{classRef, 40 * time.Minute},
+		{classPermanent, maxBackoff},
+		{classUnknown, 0},
+	}
+	for i, test := range tests {
+		repo.failures = i
+		repo.backOff(test.class, now)
+		var delay time.Duration
+		if !repo.backoff.IsZero() {
+			delay = repo.backoff.Sub(now)
+		}
+		if delay != test.delay {
+			t.Errorf("Test %d: expected a backoff of %v after a %s failure, got %v", i, test.delay, test.class, delay)
+		}
+	}
+}
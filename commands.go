@@ -2,11 +2,21 @@ package git
 
 import (
 	"bytes"
+	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 )
 
+// waitDelay is how long to wait for the output of a killed command to be
+// closed, as processes it started may keep it open.
+const waitDelay = 5 * time.Second
+
 type gitCmd struct {
 	command string
 	args    []string
@@ -20,32 +30,190 @@ func (g *gitCmd) Exec(dir string) error {
 	g.Lock()
 	g.dir = dir
 	g.Unlock()
-	return runCmd(g.command, g.args, dir)
+	return runCmd(context.Background(), g.command, g.args, dir)
 }
 
-// runCmd is a helper function to run commands.
-// It runs command with args from directory at dir.
-// The executed process outputs to os.Stderr
-func runCmd(command string, args []string, dir string) error {
-	cmd := exec.Command(command, args...)
-	cmd.Stdout = os.Stderr
-	cmd.Stderr = os.Stderr
+// Runner runs the external commands of the plugin, git and the commands
+// of other backends such as hg.
+type Runner interface {
+	// Run runs command with args from directory dir, with the environment
+	// of this process and env, writing its standard output and error to
+	// stdout and stderr. The command must be stopped, and Run return, once
+	// ctx is done. It must run as RunAs(ctx) if set. env holds secrets,
+	// such as passwords, kept out of the command line any local user can
+	// read.
+	Run(ctx context.Context, dir string, env []string, stdout, stderr io.Writer, command string, args ...string) error
+}
+
+// CommandRunner runs every external command. It can be replaced, before
+// any repository is set up, to record or stub the commands in tests or
+// embedding programs.
+var CommandRunner Runner = ExecRunner{}
+
+// ExecRunner is the default Runner, running commands as processes.
+type ExecRunner struct{}
+
+// Run implements Runner. Processes the command started are killed with it.
+func (ExecRunner) Run(ctx context.Context, dir string, env []string, stdout, stderr io.Writer, command string, args ...string) error {
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.WaitDelay = waitDelay
+	killGroup(cmd)
+	if owner := RunAs(ctx); owner != nil {
+		setCredential(cmd, owner)
+	}
 	cmd.Dir = dir
-	if err := cmd.Start(); err != nil {
-		return err
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
 	}
-	return cmd.Wait()
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
+
+// runCmd is a helper function to run commands.
+// It runs command with args from directory at dir, killing it
+// if ctx is done before it completes.
+// The process output is logged at debug level and included
+// in the returned error if the command fails.
+func runCmd(ctx context.Context, command string, args []string, dir string) error {
+	_, err := runCmdCombined(ctx, command, args, nil, dir)
+	return err
+}
+
+// runCmdCombined is like runCmd, but runs command with the environment
+// env too, and also returns the output of the command, stdout and stderr
+// combined.
+func runCmdCombined(ctx context.Context, command string, args, env []string, dir string) ([]byte, error) {
+	var output bytes.Buffer
+	err := CommandRunner.Run(ctx, dir, env, &output, &output, command, args...)
+	logOutput(command, args, output.Bytes())
+	if err != nil {
+		if out := lastLine(output.Bytes()); out != "" {
+			return output.Bytes(), fmt.Errorf("%s %s: %v: %s", command, redact(strings.Join(args, " ")), err, redact(out))
+		}
+		return output.Bytes(), fmt.Errorf("%s %s: %v", command, redact(strings.Join(args, " ")), err)
+	}
+	return output.Bytes(), nil
 }
 
 // runCmdOutput is a helper function to run commands and return output.
-// It runs command with args from directory at dir.
+// It runs command with args from directory at dir, killing it
+// if ctx is done before it completes.
 // If successful, returns output and nil error
-func runCmdOutput(command string, args []string, dir string) (string, error) {
-	cmd := exec.Command(command, args...)
-	cmd.Dir = dir
-	var err error
-	if output, err := cmd.Output(); err == nil {
-		return string(bytes.TrimSpace(output)), nil
+func runCmdOutput(ctx context.Context, command string, args []string, dir string) (string, error) {
+	return runCmdOutputEnv(ctx, command, args, nil, dir)
+}
+
+// runCmdOutputEnv is like runCmdOutput, but runs command with the
+// environment env too.
+func runCmdOutputEnv(ctx context.Context, command string, args, env []string, dir string) (string, error) {
+	var output, stderr bytes.Buffer
+	err := CommandRunner.Run(ctx, dir, env, &output, &stderr, command, args...)
+	logOutput(command, args, stderr.Bytes())
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(output.Bytes())), nil
+}
+
+// logOutput logs the output of a command at debug level, with the final
+// state of progress only.
+func logOutput(command string, args []string, output []byte) {
+	output = bytes.TrimSpace(finalProgress(output))
+	if len(output) == 0 {
+		return
+	}
+	log.Debugf("%s %s:\n%s", command, redact(strings.Join(args, " ")), redact(string(output)))
+}
+
+// finalProgress returns output without the progress git overwrites with
+// carriage returns on the same line.
+func finalProgress(output []byte) []byte {
+	lines := bytes.Split(output, []byte("\n"))
+	for i, line := range lines {
+		if j := bytes.LastIndexByte(bytes.TrimRight(line, "\r"), '\r'); j >= 0 {
+			lines[i] = line[j+1:]
+		}
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// lastLine returns the last non empty line of output.
+func lastLine(output []byte) string {
+	lines := strings.Split(string(bytes.TrimSpace(finalProgress(output))), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+var (
+	// httpUserinfo matches the credentials of HTTP(S) URLs, where a user
+	// name on its own is commonly an access token.
+	httpUserinfo = regexp.MustCompile(`(?i)(https?://)[^/@\s]+@`)
+
+	// password matches the password of any other URL.
+	password = regexp.MustCompile(`(://[^/:@\s]*):[^/@\s]*@`)
+
+	// bareUserinfo matches the credentials of URLs without a scheme, such
+	// as user:token@github.com/user/repo.
+	bareUserinfo = regexp.MustCompile(`(^|[\s'"])([^\s'"/:@]+):[^\s'"/@]+@`)
+
+	// secretParam matches tokens and signatures in URL queries, such as
+	// those of presigned object store URLs.
+	secretParam = regexp.MustCompile(`(?i)([?&](?:access_token|private_token|token|sig|signature|x-amz-signature|x-amz-credential|x-goog-signature|x-goog-credential)=)[^&\s'"]+`)
+
+	// authHeader matches the credentials of Authorization headers.
+	authHeader = regexp.MustCompile(`(?i)(authorization:\s*(?:(?:bearer|basic|token)\s+)?)[^\s'"]+`)
+
+	// extraHeader matches the extra headers of git, whose values may be
+	// tokens of proxies.
+	extraHeader = regexp.MustCompile(`(?i)extraheader=[^:\s'"]+:\s*[^\s'"]+`)
+
+	// configPassword matches the passwords of configuration options, such
+	// as the auth.NAME.password options of hg.
+	configPassword = regexp.MustCompile(`(?i)(\.password=)[^\s'"]+`)
+
+	// keyPath matches the paths of SSH private keys, following a -i
+	// option or an IdentityFile option.
+	keyPath = regexp.MustCompile(`((?:^|\s)-i\s+|IdentityFile[=\s]\s*)[^\s'"]+`)
+)
+
+// redact removes credentials from s: those embedded in URLs, tokens of
+// URL queries, Authorization and extra headers and configuration options,
+// and the paths of SSH keys.
+func redact(s string) string {
+	s = httpUserinfo.ReplaceAllString(s, "${1}REDACTED@")
+	s = password.ReplaceAllString(s, "${1}:REDACTED@")
+	s = bareUserinfo.ReplaceAllString(s, "${1}${2}:REDACTED@")
+	s = secretParam.ReplaceAllString(s, "${1}REDACTED")
+	s = authHeader.ReplaceAllString(s, "${1}REDACTED")
+	s = extraHeader.ReplaceAllStringFunc(s, func(h string) string {
+		name, _, _ := strings.Cut(h, ":")
+		if strings.HasSuffix(strings.ToLower(name), "=authorization") {
+			// redacted as an Authorization header already
+			return h
+		}
+		return name + ": REDACTED"
+	})
+	s = configPassword.ReplaceAllString(s, "${1}REDACTED")
+	return keyPath.ReplaceAllString(s, "${1}REDACTED")
+}
+
+// redactedError is an error whose message is redacted.
+type redactedError struct {
+	err error
+	msg string
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactError returns err with its message redacted, so credentials never
+// reach logs or the errors returned up the plugin chain.
+func redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if msg := redact(err.Error()); msg != err.Error() {
+		return &redactedError{err, msg}
 	}
-	return "", err
+	return err
 }
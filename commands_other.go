@@ -0,0 +1,16 @@
+//go:build windows || plan9
+
+package git
+
+import "os/exec"
+
+// killGroup does nothing: process groups are not supported, only the
+// command itself is killed on cancelation.
+func killGroup(cmd *exec.Cmd) {}
+
+// setCredential does nothing: commands can't run as another user.
+func setCredential(cmd *exec.Cmd, owner *Owner) {}
+
+// runAsSupported reports whether commands can run as another user, and
+// files be chowned.
+const runAsSupported = false
@@ -0,0 +1,30 @@
+//go:build !windows && !plan9
+
+package git
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killGroup runs cmd in its own process group and makes its cancelation
+// kill the whole group, so processes git starts, such as ssh or
+// git-remote-https, don't outlive it holding locks on the checkout.
+func killGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+}
+
+// setCredential makes cmd run as owner.
+func setCredential(cmd *exec.Cmd, owner *Owner) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(owner.UID), Gid: uint32(owner.GID)}
+}
+
+// runAsSupported reports whether commands can run as another user, and
+// files be chowned.
+const runAsSupported = true
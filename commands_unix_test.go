@@ -0,0 +1,23 @@
+//go:build !windows && !plan9
+
+package git
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRunCmdKillsGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	// the background sleep keeps the output open if only sh is killed
+	start := time.Now()
+	if err := runCmd(ctx, "sh", []string{"-c", "sleep 10 & sleep 10"}, ""); err == nil {
+		t.Error("Expected killed command to fail")
+	}
+	if d := time.Since(start); d >= waitDelay {
+		t.Errorf("Expected command and its children to be killed, waited %v", d)
+	}
+}
@@ -0,0 +1,100 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// Formats of the keys signing commits, as of the gpg.format setting of git.
+const (
+	signOpenPGP = "openpgp"
+	signSSH     = "ssh"
+	signX509    = "x509"
+)
+
+// Kinds of the commits the plugin creates.
+const (
+	messageLocal  = "local"
+	messageUpdate = "update"
+	messageBackup = "backup"
+)
+
+// Message is the template of the messages of a kind of commits created by
+// the plugin.
+type Message struct {
+	Kind     string // Kind of commits: local, update or backup
+	Template string // Go template of their message, executed with a commitData
+}
+
+// commitTemplates are the default templates of the messages of every kind
+// of commits.
+var commitTemplates = map[string]string{
+	messageLocal:  "Commit local changes of {{.Repo}} on {{.Host}}\n\n{{.Changes}}\n",
+	messageUpdate: "Dynamic update of {{.Zone}}\n\nKey: {{.Key}}\nClient: {{.Client}}\n\n{{.Changes}}\n",
+	messageBackup: "Backup of {{.Dir}} on {{.Host}}\n\n{{.Changes}}\n",
+}
+
+// commitData is what the templates of commit messages are executed with.
+// Fields which don't apply to a kind of commits are empty.
+type commitData struct {
+	Repo    string // Name of the repository
+	Branch  string // Branch of the repository
+	Host    string // Host name of the server
+	Zone    string // Zone dynamically updated
+	Key     string // TSIG key the update is signed with
+	Client  string // Address of the client of the update
+	Dir     string // Directory backed up
+	Changes string // Files, or records, changed, one per line
+}
+
+// parseMessage parses the template of commit messages s, where \n is a
+// line break.
+func parseMessage(s string) (*template.Template, error) {
+	return template.New("message").Option("missingkey=error").Parse(strings.ReplaceAll(s, `\n`, "\n"))
+}
+
+// commitMessage returns the message of a commit of kind, with the fields
+// of data not specific to the commit filled in.
+func (r *Repo) commitMessage(kind string, data commitData) (string, error) {
+	text := commitTemplates[kind]
+	for _, m := range r.Messages {
+		if m.Kind == kind {
+			text = m.Template
+		}
+	}
+	tmpl, err := parseMessage(text)
+	if err != nil {
+		return "", err
+	}
+	data.Repo, data.Branch = r.String(), r.Branch
+	data.Host, _ = os.Hostname()
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("cannot write the commit message of %v: %s", r, err)
+	}
+	return buf.String(), nil
+}
+
+// commitConfig returns the git configuration of repositories committing
+// or stashing their local changes: they are committed, and merged with
+// the ones pulled, as Author, CoreDNS on this host by default, signed with
+// SigningKey if set.
+func (r *Repo) commitConfig() []string {
+	author := r.Author
+	if author == nil {
+		host, _ := os.Hostname()
+		if host == "" {
+			host = "localhost"
+		}
+		author = &mail.Address{Name: "CoreDNS", Address: "coredns@" + host}
+	}
+	config := []string{"-c", "user.name=" + author.Name, "-c", "user.email=" + author.Address, "-c", "pull.rebase=false"}
+	if r.SigningKey != "" {
+		config = append(config, "-c", "commit.gpgSign=true", "-c", "gpg.format="+r.SignFormat, "-c", "user.signingKey="+r.SigningKey)
+	}
+	return config
+}
@@ -0,0 +1,47 @@
+package git
+
+import (
+	"net/mail"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCommitMessage(t *testing.T) {
+	host, _ := os.Hostname()
+	repo := &Repo{Name: "zones", Branch: "main"}
+	msg, err := repo.commitMessage(messageBackup, commitData{Dir: "/var/lib/secondary", Changes: "M db.example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Backup of /var/lib/secondary on " + host + "\n\nM db.example.org\n"; msg != expected {
+		t.Errorf("Expected message %q, found %q", expected, msg)
+	}
+
+	repo.Messages = []Message{{Kind: messageUpdate, Template: `dns({{.Zone}}): update from {{.Host}}\n\nBranch: {{.Branch}}`}}
+	msg, err = repo.commitMessage(messageUpdate, commitData{Zone: "example.org."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "dns(example.org.): update from " + host + "\n\nBranch: main"; msg != expected {
+		t.Errorf("Expected message %q, found %q", expected, msg)
+	}
+
+	repo.Messages = []Message{{Kind: messageLocal, Template: "{{.Missing}}"}}
+	if _, err := repo.commitMessage(messageLocal, commitData{}); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
+
+func TestCommitConfig(t *testing.T) {
+	repo := &Repo{Author: &mail.Address{Name: "DNS Team", Address: "dns@corp"}, SigningKey: "key::ssh-ed25519 AAAA", SignFormat: signSSH}
+	config := strings.Join(repo.commitConfig(), " ")
+	for _, expected := range []string{"user.name=DNS Team", "user.email=dns@corp", "commit.gpgSign=true", "gpg.format=ssh", "user.signingKey=key::ssh-ed25519 AAAA"} {
+		if !strings.Contains(config, "-c "+expected) {
+			t.Errorf("Expected %q in %q", expected, config)
+		}
+	}
+	if config := strings.Join((&Repo{}).commitConfig(), " "); !strings.Contains(config, "user.name=CoreDNS") || strings.Contains(config, "gpgSign") {
+		t.Errorf("Unexpected default configuration %q", config)
+	}
+}
@@ -0,0 +1,161 @@
+package git
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// controlTimeout bounds how long a control connection may stay idle.
+const controlTimeout = time.Minute
+
+// control runs the control command in line on the repositories of rs
+// matching its optional repository argument, and returns its output.
+func control(line string, rs []*Repo, source string) (string, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 || len(fields) > 2 {
+		return "", errors.New("usage: status|pull|pause|resume|rollback [REPO]")
+	}
+	if len(fields) == 2 {
+		var matched []*Repo
+		for _, r := range rs {
+			if r.matches(fields[1]) {
+				matched = append(matched, r)
+			}
+		}
+		if len(matched) == 0 {
+			return "", fmt.Errorf("no such repo: %s", fields[1])
+		}
+		rs = matched
+	}
+
+	var do func(r *Repo) error
+	switch fields[0] {
+	case "status":
+		statuses := make([]RepoStatus, len(rs))
+		for i, r := range rs {
+			statuses[i] = r.status()
+		}
+		b, err := json.Marshal(statuses)
+		return string(b), err
+	case "pull":
+		do = func(r *Repo) error { return r.pullFrom(r.lifetime(), source) }
+	case "pause":
+		do = func(r *Repo) error { r.Pause(); return nil }
+	case "resume":
+		do = func(r *Repo) error { r.Resume(); return nil }
+	case "rollback":
+		do = (*Repo).Rollback
+	default:
+		return "", fmt.Errorf("unknown command: %s", fields[0])
+	}
+
+	var errs []error
+	for _, r := range rs {
+		if err := do(r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+	return "ok", nil
+}
+
+// startControl starts the control socket at path, or adds a reference to
+// it if it is already running.
+func startControl(path string) error {
+	return listeners.acquire("control "+path, func() (io.Closer, error) {
+		// remove a socket left behind by an unclean shutdown
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+		// create the socket in a private directory and move it into place
+		// once only accessible by the owner, so no other user can connect
+		// in between
+		dir, err := os.MkdirTemp(filepath.Dir(path), ".control")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		tmp := filepath.Join(dir, "socket")
+		ln, err := net.Listen("unix", tmp)
+		if err != nil {
+			return nil, err
+		}
+		ln.(*net.UnixListener).SetUnlinkOnClose(false)
+		if err = os.Chmod(tmp, 0600); err == nil {
+			err = os.Rename(tmp, path)
+		}
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+		go serveControl(ln, path)
+		return controlSocket{ln, path}, nil
+	})
+}
+
+// controlSocket is the listener of a control socket, removing the socket
+// when closed.
+type controlSocket struct {
+	net.Listener
+	path string
+}
+
+func (s controlSocket) Close() error {
+	err := s.Listener.Close()
+	os.Remove(s.path)
+	return err
+}
+
+// stopControl removes a reference to the control socket at path and
+// stops it once it is no longer used.
+func stopControl(path string) error { return listeners.release("control " + path) }
+
+// serveControl accepts connections on the control socket at path until ln is closed.
+func serveControl(ln net.Listener, path string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Errorf("control socket %s: %s", path, err)
+			}
+			return
+		}
+		go handleControl(conn, path)
+	}
+}
+
+// handleControl runs the commands sent on conn, one per line, and writes
+// the output of each as a single line prefixed with "ok" or "error".
+func handleControl(conn net.Conn, path string) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for conn.SetDeadline(time.Now().Add(controlTimeout)) == nil && scanner.Scan() {
+		var rs []*Repo
+		for _, r := range registry.all() {
+			if r.Control == path {
+				rs = append(rs, r)
+			}
+		}
+
+		out, err := control(scanner.Text(), rs, sourceControl)
+		if err != nil {
+			out = "error " + strings.ReplaceAll(redact(err.Error()), "\n", "; ")
+		} else if out != "ok" {
+			out = "ok " + out
+		}
+		if _, err := fmt.Fprintln(conn, out); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package git
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestControl(t *testing.T) {
+	a := &Repo{URL: "https://github.com/user/a"}
+	b := &Repo{URL: "https://github.com/user/b"}
+	rs := []*Repo{a, b}
+
+	if _, err := control("pause "+a.URL, rs, sourceControl); err != nil {
+		t.Fatal(err)
+	}
+	if !a.Paused() || b.Paused() {
+		t.Errorf("Expected only %v to be paused", a.URL)
+	}
+	if _, err := control("resume", rs, sourceControl); err != nil {
+		t.Fatal(err)
+	}
+	if a.Paused() {
+		t.Errorf("Expected %v to be resumed", a.URL)
+	}
+
+	out, err := control("status", rs, sourceControl)
+	if err != nil || !strings.Contains(out, b.URL) {
+		t.Errorf("Expected status of %v, found %q, %v", b.URL, out, err)
+	}
+
+	if _, err := control("rollback "+a.URL, rs, sourceControl); err == nil {
+		t.Errorf("Expected rollback without previous commit to fail")
+	}
+	for _, line := range []string{"", "explode", "pull https://github.com/user/c", "pull a b"} {
+		if _, err := control(line, rs, sourceControl); err == nil {
+			t.Errorf("Expected %q to fail", line)
+		}
+	}
+}
+
+func TestStartControl(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "git.sock")
+	if err := startControl(path); err != nil {
+		t.Skipf("cannot listen on a Unix socket: %v", err)
+	}
+	fi, err := os.Lstat(path)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		t.Fatalf("Expected a socket at %v, found %v", path, err)
+	}
+	if perm := fi.Mode().Perm(); runtime.GOOS != "windows" && perm != 0600 {
+		t.Errorf("Expected the socket to be only accessible by its owner, found %v", perm)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected only the socket in %v, found %v", dir, entries)
+	}
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Write([]byte("status\n"))
+	if line, _ := bufio.NewReader(conn).ReadString('\n'); line != "ok []\n" {
+		t.Errorf("Expected an empty status, found %q", line)
+	}
+	conn.Close()
+
+	stopControl(path)
+	if _, err := os.Lstat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the socket to be removed, found %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
+)
+
+// controlZone returns the control zone qname belongs to, or "" if it does
+// not belong to the control zone of any repository.
+func (h Handler) controlZone(qname string) string {
+	var zones []string
+	for _, r := range h.Repos {
+		if r.ControlZone != "" {
+			zones = append(zones, r.ControlZone)
+		}
+	}
+	return plugin.Zones(zones).Matches(qname)
+}
+
+// controlCommands returns the control commands of r, a message of the
+// control zone zone: a TXT query for [REPO.]COMMAND.ZONE runs COMMAND
+// [REPO], one for ZONE runs status, and every TXT record added by a
+// dynamic update of ZONE runs the command it holds.
+func controlCommands(r *dns.Msg, zone string) []string {
+	if r.Opcode == dns.OpcodeUpdate {
+		var lines []string
+		for _, rr := range r.Ns {
+			if txt, ok := rr.(*dns.TXT); ok && txt.Hdr.Class == dns.ClassINET {
+				lines = append(lines, strings.Join(txt.Txt, " "))
+			}
+		}
+		return lines
+	}
+	q := r.Question[0]
+	if q.Qtype != dns.TypeTXT {
+		return nil
+	}
+	labels := dns.SplitDomainName(q.Name)
+	labels = labels[:len(labels)-dns.CountLabel(zone)]
+	if len(labels) == 0 {
+		return []string{"status"}
+	}
+	cmd := labels[len(labels)-1]
+	if len(labels) == 1 {
+		return []string{cmd}
+	}
+	return []string{cmd + " " + strings.Join(labels[:len(labels)-1], ".")}
+}
+
+// serveControlZone answers r, a message of the control zone zone, running
+// its commands on the repositories of zone allowing the TSIG key it is
+// signed with. Queries are answered with a TXT record holding the output
+// of their command, starting with "ok" or "error" as on the control
+// socket, dynamic updates with SERVFAIL if a command failed. Messages
+// which are not signed by an allowed key are refused.
+func (h Handler) serveControlZone(w dns.ResponseWriter, r *dns.Msg, zone string) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	var rs []*Repo
+	if key, ok := h.tsigKey(w, r); ok {
+		for _, repo := range h.Repos {
+			if repo.ControlZone == zone && (len(repo.ControlKeys) == 0 || contains(repo.ControlKeys, key)) {
+				rs = append(rs, repo)
+			}
+		}
+	}
+	if len(rs) == 0 {
+		m.Rcode = dns.RcodeRefused
+	} else {
+		for _, line := range controlCommands(r, zone) {
+			out, err := control(line, rs, sourceDNS)
+			if err != nil {
+				out = "error " + strings.ReplaceAll(redact(err.Error()), "\n", "; ")
+				if r.Opcode == dns.OpcodeUpdate {
+					m.Rcode = dns.RcodeServerFailure
+				}
+			} else if out != "ok" {
+				out = "ok " + out
+			}
+			if r.Opcode != dns.OpcodeUpdate {
+				m.Answer = append(m.Answer, &dns.TXT{
+					Hdr: dns.RR_Header{Name: r.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 0},
+					Txt: splitTXT(out),
+				})
+			}
+		}
+	}
+	if t := r.IsTsig(); t != nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, t.Fudge, time.Now().Unix())
+	}
+
+	if err := w.WriteMsg(m); err != nil {
+		return dns.RcodeServerFailure, fmt.Errorf("writing control response: %s", err)
+	}
+	return m.Rcode, nil
+}
+
+// splitTXT splits s into the strings of a TXT record, at most 255 bytes
+// long each.
+func splitTXT(s string) []string {
+	var txt []string
+	for len(s) > 255 {
+		txt = append(txt, s[:255])
+		s = s[255:]
+	}
+	return append(txt, s)
+}
@@ -0,0 +1,72 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestServeControlZone(t *testing.T) {
+	repo := &Repo{Name: "zones", URL: "https://github.com/user/zones", ControlZone: "ctl.example.", ControlKeys: []string{"ops."}}
+	other := &Repo{Name: "other", URL: "https://github.com/user/other", ControlZone: "ctl.example."}
+	h := Handler{Repos: Git{repo, other}, Next: test.ErrorHandler(), Config: tsigConfig("ops.", "dev.")}
+
+	serve := func(m *dns.Msg, key string) *dns.Msg {
+		if key != "" {
+			m = sign(t, m, key, testSecret)
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		h.ServeDNS(context.TODO(), rec, m)
+		return rec.Msg
+	}
+	query := func(qname, key string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(qname, dns.TypeTXT)
+		return serve(m, key)
+	}
+	update := func(key string, lines ...string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetUpdate("ctl.example.")
+		for _, line := range lines {
+			m.Insert([]dns.RR{&dns.TXT{Hdr: dns.RR_Header{Name: "ctl.example.", Rrtype: dns.TypeTXT, Class: dns.ClassINET}, Txt: []string{line}}})
+		}
+		return serve(m, key)
+	}
+
+	if m := query("ctl.example.", ""); m.Rcode != dns.RcodeRefused {
+		t.Errorf("Expected an unsigned query to be refused, found %v", dns.RcodeToString[m.Rcode])
+	}
+	// only other allows any key
+	m := query("ctl.example.", "dev.")
+	if len(m.Answer) != 1 || !strings.HasPrefix(m.Answer[0].(*dns.TXT).Txt[0], "ok [") || strings.Contains(strings.Join(m.Answer[0].(*dns.TXT).Txt, ""), `"name":"zones"`) {
+		t.Errorf("Expected the status of other only, found %v", m.Answer)
+	}
+
+	if m := query("zones.pause.ctl.example.", "ops."); len(m.Answer) != 1 || m.Answer[0].(*dns.TXT).Txt[0] != "ok" {
+		t.Errorf("Expected the pause to succeed, found %v", m.Answer)
+	}
+	if !repo.Paused() || other.Paused() {
+		t.Errorf("Expected only zones to be paused")
+	}
+	if m := update("ops.", "resume zones"); m.Rcode != dns.RcodeSuccess || repo.Paused() {
+		t.Errorf("Expected the update to resume zones, found %v", dns.RcodeToString[m.Rcode])
+	}
+	if m := update("ops.", "restart"); m.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected an unknown command to fail, found %v", dns.RcodeToString[m.Rcode])
+	}
+	if m := query("zones.restart.ctl.example.", "ops."); len(m.Answer) != 1 || m.Answer[0].(*dns.TXT).Txt[0] != "error unknown command: restart" {
+		t.Errorf("Expected an error, found %v", m.Answer)
+	}
+}
+
+func TestSplitTXT(t *testing.T) {
+	txt := splitTXT(strings.Repeat("a", 600))
+	if len(txt) != 3 || len(txt[0]) != 255 || len(txt[2]) != 90 {
+		t.Errorf("Expected 3 strings of at most 255 bytes, found %v", txt)
+	}
+}
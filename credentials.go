@@ -0,0 +1,121 @@
+package git
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// splitPassword returns the HTTP(S) URL rawURL without its password, and
+// the password, so it is passed to git for every command instead of being
+// stored in the configuration of the checkout. Other URLs are returned as
+// is.
+func splitPassword(rawURL string) (string, string) {
+	scheme, _ := remoteOf(rawURL)
+	if scheme != "http" && scheme != "https" {
+		return rawURL, ""
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL, ""
+	}
+	password, ok := u.User.Password()
+	if !ok {
+		return rawURL, ""
+	}
+	u.User = url.User(u.User.Username())
+	return u.String(), password
+}
+
+// credentials returns the user name and password of URL, its password
+// being kept in Password if it was split from it.
+func (r *Repo) credentials() (user, password string) {
+	u, err := url.Parse(r.URL)
+	if err != nil || u.User == nil {
+		return "", r.Password
+	}
+	password, ok := u.User.Password()
+	if !ok {
+		password = r.Password
+	}
+	return u.User.Username(), password
+}
+
+// credentialConfig returns the configuration of git sending the
+// credentials of URL with the requests to URL only, with an Authorization
+// header, or nil if its password is not kept apart. It is passed to git
+// through its environment, see gitEnv.
+func (r *Repo) credentialConfig() [][2]string {
+	if r.Password == "" {
+		return nil
+	}
+	user, password := r.credentials()
+	auth := base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+	return [][2]string{{"http." + r.URL + ".extraHeader", "Authorization: Basic " + auth}}
+}
+
+// hgrcPath is the default search path of the configuration of hg, which
+// HGRCPATH replaces: the configuration of the host, then of the user.
+var hgrcPath = strings.Join([]string{
+	"/etc/mercurial/hgrc", "/etc/mercurial/hgrc.d", "~/.hgrc", "~/.config/hg/hgrc",
+}, string(filepath.ListSeparator))
+
+// hgCredentialConfig writes the configuration of hg authenticating to URL
+// with its credentials to a private directory. It returns the environment
+// of hg reading it after the configuration of the host and of the user,
+// and a function removing it once hg is done. The environment is empty if
+// the password of URL is not kept apart.
+func (r *Repo) hgCredentialConfig() ([]string, func(), error) {
+	if r.Password == "" {
+		return nil, func() {}, nil
+	}
+	user, password := r.credentials()
+	prefix := r.URL
+	if u, err := url.Parse(r.URL); err == nil {
+		u.User, u.RawQuery = nil, ""
+		prefix = u.String()
+	}
+	if strings.ContainsAny(user+password+prefix, "\r\n") {
+		return nil, nil, fmt.Errorf("the credentials of %v have line breaks hg cannot read", r)
+	}
+
+	dir, err := os.MkdirTemp("", "coredns-git-hg")
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot write the hg credentials of %v: %s", r, err)
+	}
+	remove := func() { os.RemoveAll(dir) }
+	hgrc := filepath.Join(dir, "hgrc")
+	config := fmt.Sprintf("[auth]\ncoredns.prefix = %s\ncoredns.username = %s\ncoredns.password = %s\n", prefix, user, password)
+	if err := os.WriteFile(hgrc, []byte(config), 0600); err != nil {
+		remove()
+		return nil, nil, fmt.Errorf("cannot write the hg credentials of %v: %s", r, err)
+	}
+	for _, path := range []string{dir, hgrc} {
+		if err := r.RunAs.chown(path); err != nil {
+			remove()
+			return nil, nil, fmt.Errorf("cannot write the hg credentials of %v: %s", r, err)
+		}
+	}
+
+	search := os.Getenv("HGRCPATH")
+	if search == "" {
+		search = hgrcPath
+	}
+	return []string{"HGRCPATH=" + search + string(filepath.ListSeparator) + hgrc}, remove, nil
+}
+
+// goGitAuth returns the authentication of go-git to URL, or nil if its
+// password is not kept apart.
+func (r *Repo) goGitAuth() transport.AuthMethod {
+	if r.Password == "" {
+		return nil
+	}
+	user, password := r.credentials()
+	return &githttp.BasicAuth{Username: user, Password: password}
+}
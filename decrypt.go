@@ -0,0 +1,67 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Tools files of the repository are encrypted with, decrypted with
+// Decrypt.
+const (
+	encryptedSops = "sops"
+	encryptedAge  = "age"
+)
+
+// ageSuffix is the suffix of age encrypted files, removed once decrypted.
+const ageSuffix = ".age"
+
+// encryptedWith returns the tool the file at path was encrypted with, or
+// "" if it is not encrypted.
+func encryptedWith(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case bytes.HasPrefix(b, []byte("age-encryption.org/")), bytes.HasPrefix(b, []byte("-----BEGIN AGE ENCRYPTED FILE-----")):
+		return encryptedAge, nil
+	case bytes.Contains(b, []byte("ENC[AES256_GCM,")) && bytes.Contains(b, []byte("sops")):
+		return encryptedSops, nil
+	}
+	return "", nil
+}
+
+// decryptFile atomically replaces dst with the file at src decrypted with
+// tool, with permissions perm without the ones of others. The sops and
+// age commands are run with DecryptKey as age identity, sops finding
+// other keys, e.g. of a KMS, itself.
+func (r *Repo) decryptFile(ctx context.Context, tool, src, dst string, perm os.FileMode) error {
+	var command string
+	var args, env []string
+	switch {
+	case tool == encryptedAge && r.DecryptKey == "":
+		return fmt.Errorf("no decrypt key for age encrypted file %v", src)
+	case tool == encryptedAge:
+		command, args = "age", []string{"--decrypt", "-i", r.DecryptKey, src}
+	default:
+		command, args = "sops", []string{"--decrypt", src}
+		if r.DecryptKey != "" {
+			env = []string{"SOPS_AGE_KEY_FILE=" + r.DecryptKey}
+		}
+	}
+
+	// the output is secret, only errors are logged
+	var output, stderr bytes.Buffer
+	err := CommandRunner.Run(ctx, "", env, &output, &stderr, command, args...)
+	logOutput(command, args, stderr.Bytes())
+	if err != nil {
+		if out := lastLine(stderr.Bytes()); out != "" {
+			return fmt.Errorf("%s %s: %v: %s", command, redact(strings.Join(args, " ")), err, redact(out))
+		}
+		return fmt.Errorf("%s %s: %v", command, redact(strings.Join(args, " ")), err)
+	}
+	return replaceFile(&output, dst, perm&^0007)
+}
@@ -0,0 +1,68 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// decryptRunner decrypts files by recording the commands it is asked to
+// run, with their environment, and writing the name of the file as decrypted content.
+type decryptRunner struct{ commands []string }
+
+func (r *decryptRunner) Run(ctx context.Context, dir string, env []string, stdout, stderr io.Writer, command string, args ...string) error {
+	r.commands = append(r.commands, strings.Join(append(append(append([]string{}, env...), command), args...), " "))
+	fmt.Fprintf(stdout, "decrypted %s", filepath.Base(args[len(args)-1]))
+	return nil
+}
+
+func TestPublishDecrypt(t *testing.T) {
+	runner := &decryptRunner{}
+	CommandRunner = runner
+	defer func() { CommandRunner = ExecRunner{} }()
+
+	dir := t.TempDir()
+	repo := &Repo{Path: filepath.Join(dir, "zones"), Subpath: "dns", Decrypt: true, DecryptKey: "/etc/coredns/age.key"}
+	repo.lastCommit = "c1"
+	writeFiles(t, repo.workDir(), map[string]string{
+		"dns/db.example.org":  "plain",
+		"dns/tsig.yaml":       "key: ENC[AES256_GCM,data:abc,type:str]\nsops:\n    version: 3.8.1\n",
+		"dns/db.internal.age": "age-encryption.org/v1\n-> X25519 abc\n",
+	})
+	if err := repo.publish(); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"db.example.org": "plain",
+		"tsig.yaml":      "decrypted tsig.yaml",
+		"db.internal":    "decrypted db.internal.age",
+	} {
+		if b, _ := os.ReadFile(filepath.Join(repo.Path, name)); string(b) != content {
+			t.Errorf("Expected %v to hold %q, found %q", name, content, b)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(repo.Path, "tsig.yaml")); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm()&0007 != 0 {
+		t.Errorf("Expected decrypted files not to be readable by others, found %v", fi.Mode())
+	}
+
+	work := repo.workDir()
+	expected := []string{
+		"age --decrypt -i /etc/coredns/age.key " + filepath.Join(work, "dns", "db.internal.age"),
+		"SOPS_AGE_KEY_FILE=/etc/coredns/age.key sops --decrypt " + filepath.Join(work, "dns", "tsig.yaml"),
+	}
+	if fmt.Sprint(runner.commands) != fmt.Sprint(expected) {
+		t.Errorf("Expected commands %q, found %q", expected, runner.commands)
+	}
+
+	// age files can't be decrypted without a key
+	repo.DecryptKey, repo.lastCommit = "", "c2"
+	if err := repo.publish(); err == nil || !strings.Contains(err.Error(), "no decrypt key") {
+		t.Errorf("Expected an error without key, found %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// deployment is the part of a GitHub deployment the plugin reads.
+type deployment struct {
+	ID int64 `json:"id"`
+}
+
+// reportDeployment records the commit checked out and published by a
+// successful pull as deployed to Environment on GitHub: it creates the
+// deployment of the commit to Environment, unless a resolver did already,
+// then adds a success status naming this resolver, which GitHub shows as
+// the active deployment of Environment. Failing to do so is only logged.
+func (r *Repo) reportDeployment(ctx context.Context, err error) {
+	if r.Environment == "" || err != nil || r.lastCommit == "" || r.lastCommit == r.deployed {
+		return
+	}
+	if err := r.deploy(ctx, r.lastCommit); err != nil {
+		log.Warningf("cannot report the deployment of %s of %v to %v: %s", r.lastCommit, r, r.Environment, err)
+		return
+	}
+	r.deployed = r.lastCommit
+}
+
+// deploy adds a status of this resolver to the deployment of commit to
+// Environment, created if missing.
+func (r *Repo) deploy(ctx context.Context, commit string) error {
+	project := r.forgeProject()
+	var found []deployment
+	q := url.Values{"sha": {commit}, "environment": {r.Environment}}
+	if err := r.forgeRequest(ctx, http.MethodGet, "/repos/"+project+"/deployments?"+q.Encode(), nil, &found); err != nil {
+		return err
+	}
+	var d deployment
+	if len(found) > 0 {
+		d = found[0]
+	} else {
+		in := map[string]interface{}{
+			"ref":               commit,
+			"environment":       r.Environment,
+			"auto_merge":        false,
+			"required_contexts": []string{},
+			"description":       "Deployed by CoreDNS",
+		}
+		if err := r.forgeRequest(ctx, http.MethodPost, "/repos/"+project+"/deployments", in, &d); err != nil {
+			return err
+		}
+	}
+	host, _ := os.Hostname()
+	in := map[string]interface{}{
+		"state":       "success",
+		"environment": r.Environment,
+		"description": "Active on " + host,
+	}
+	return r.forgeRequest(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/deployments/%d/statuses", project, d.ID), in, nil)
+}
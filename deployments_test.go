@@ -0,0 +1,62 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestReportDeployment(t *testing.T) {
+	var calls []string
+	deployments := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var in map[string]interface{}
+		json.NewDecoder(req.Body).Decode(&in)
+		calls = append(calls, req.Method+" "+req.URL.Path)
+		switch req.URL.Path {
+		case "/repos/user/zones/deployments":
+			if req.Method == http.MethodGet {
+				if id, ok := deployments[req.URL.Query().Get("sha")]; ok && req.URL.Query().Get("environment") == "staging" {
+					json.NewEncoder(w).Encode([]deployment{{ID: int64(id)}})
+					return
+				}
+				w.Write([]byte("[]"))
+				return
+			}
+			deployments[in["ref"].(string)] = len(deployments) + 1
+			json.NewEncoder(w).Encode(deployment{ID: int64(len(deployments))})
+		default:
+			if in["state"] != "success" || in["environment"] != "staging" {
+				t.Errorf("Unexpected deployment status %v", in)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	repo := &Repo{URL: "https://github.com/user/zones", ForgeAPI: srv.URL, Environment: "staging"}
+	repo.lastCommit = "c1"
+	repo.reportDeployment(context.Background(), nil)
+	// reported once
+	repo.reportDeployment(context.Background(), nil)
+	// not deployed
+	repo.lastCommit = "c2"
+	repo.reportDeployment(context.Background(), errors.New("cannot publish"))
+	// created by another resolver
+	deployments["c2"] = 7
+	repo.reportDeployment(context.Background(), nil)
+
+	expected := []string{
+		"GET /repos/user/zones/deployments",
+		"POST /repos/user/zones/deployments",
+		"POST /repos/user/zones/deployments/1/statuses",
+		"GET /repos/user/zones/deployments",
+		"POST /repos/user/zones/deployments/7/statuses",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected calls %v, found %v", expected, calls)
+	}
+}
@@ -0,0 +1,130 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Policies for the local changes of the checkout before a pull.
+const (
+	driftFail   = "fail"
+	driftReset  = "reset"
+	driftStash  = "stash"
+	driftCommit = "commit"
+)
+
+// driftPolicy returns the policy for the local changes of the checkout,
+// commit for repositories pushing them by default, or none.
+func (r *Repo) driftPolicy() string {
+	if r.OnDrift == "" && r.Push {
+		return driftCommit
+	}
+	return r.OnDrift
+}
+
+// reconcileDrift applies the drift policy to the local changes of the
+// checkout at dir, before it is pulled: the pull fails, or they are
+// discarded, stashed or committed to be pushed.
+func (r *Repo) reconcileDrift(ctx context.Context, dir string) error {
+	policy := r.driftPolicy()
+	if policy == "" || !r.pulled {
+		return nil
+	}
+	if policy == driftCommit {
+		return r.commitLocal(ctx, dir)
+	}
+	files, err := r.drift(ctx)
+	if err != nil || len(files) == 0 {
+		return err
+	}
+	switch policy {
+	case driftFail:
+		return fmt.Errorf("checkout of %v drifted from %v, %d files changed locally", r, r.lastCommit, len(files))
+	case driftReset:
+		if err := r.gitCmd(ctx, []string{"reset", "--hard", "--quiet"}, dir); err != nil {
+			return err
+		}
+		if err := r.gitCmd(ctx, []string{"clean", "-d", "--force", "--quiet"}, dir); err != nil {
+			return err
+		}
+		log.Warningf("discarded the local changes of %v:\n%s", r, strings.Join(files, "\n"))
+	case driftStash:
+		msg := fmt.Sprintf("Local changes of %v drifted from %v", r, r.lastCommit)
+		if err := r.gitCmd(ctx, []string{"stash", "push", "--include-untracked", "--quiet", "--message", msg}, dir); err != nil {
+			return err
+		}
+		log.Warningf("stashed the local changes of %v:\n%s", r, strings.Join(files, "\n"))
+	}
+	return nil
+}
+
+// drift returns the local changes of the checkout, modified and untracked
+// files, as listed by git status.
+func (r *Repo) drift(ctx context.Context) ([]string, error) {
+	out, err := r.gitOutput(ctx, []string{"status", "--porcelain"}, r.workDir())
+	if err != nil {
+		return nil, fmt.Errorf("cannot list the local changes of %v: %s", r, err)
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// checkDrift reports the local changes of the checkout drifting from the
+// commit checked out, through the drifted files metric and the logs. It
+// only logs them when they change, starting with the first check.
+func (r *Repo) checkDrift(ctx context.Context) error {
+	r.Lock()
+	defer r.Unlock()
+	if !r.pulled {
+		return nil
+	}
+
+	files, err := r.drift(r.commandContext(ctx))
+	if err != nil {
+		return err
+	}
+	driftedFiles.WithLabelValues(r.String()).Set(float64(len(files)))
+	report := strings.Join(files, "\n")
+	if report == r.drifted {
+		return nil
+	}
+	r.drifted = report
+	if len(files) == 0 {
+		log.Infof("checkout of %v no longer drifts from %v", r, r.lastCommit)
+		return nil
+	}
+	log.Warningf("checkout of %v drifted from %v, %d files changed locally:\n%s", r, r.lastCommit, len(files), report)
+	return nil
+}
+
+// startDriftCheck starts a background service checking the drift of the
+// checkout of repo every DriftCheck.
+func startDriftCheck(repo *Repo) {
+	service := &repoService{
+		repo,
+		time.NewTicker(repo.DriftCheck),
+		make(chan struct{}),
+		make(chan struct{}),
+	}
+	go func(s *repoService) {
+		defer close(s.done)
+		defer s.ticker.Stop()
+		for {
+			select {
+			case <-s.ticker.C:
+				if err := repo.checkDrift(repo.lifetime()); err != nil {
+					log.Warning(redactError(err))
+				}
+			case <-s.halt:
+				return
+			}
+		}
+	}(service)
+
+	// add to services to make it stoppable
+	Services.add(service)
+}
@@ -0,0 +1,83 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckDrift(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	origin := newOrigin(t, map[string]string{"db.example.org": "v1"})
+
+	repo := &Repo{URL: origin, Path: filepath.Join(dir, "zones"), Branch: "master"}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.checkDrift(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if repo.drifted != "" {
+		t.Errorf("Expected no drift, found %q", repo.drifted)
+	}
+
+	writeFiles(t, repo.Path, map[string]string{"db.example.org": "edited", "db.example.net": "new"})
+	if err := repo.checkDrift(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(repo.drifted, "M db.example.org") || !strings.Contains(repo.drifted, "?? db.example.net") {
+		t.Errorf("Expected the local changes to be reported, found %q", repo.drifted)
+	}
+
+	os.Remove(filepath.Join(repo.Path, "db.example.net"))
+	runGit(t, repo.Path, "checkout", "db.example.org")
+	if err := repo.checkDrift(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if repo.drifted != "" {
+		t.Errorf("Expected the drift to be gone, found %q", repo.drifted)
+	}
+}
+
+func TestReconcileDrift(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	origin := newOrigin(t, map[string]string{"db.example.org": "v1"})
+
+	for _, policy := range []string{driftFail, driftReset, driftStash} {
+		repo := &Repo{URL: origin, Path: filepath.Join(dir, policy), Branch: "master", OnDrift: policy}
+		if err := repo.pull(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		writeFiles(t, repo.Path, map[string]string{"db.example.org": "edited", "db.example.net": "new"})
+
+		err := repo.pull(context.Background())
+		if policy == driftFail {
+			if err == nil {
+				t.Errorf("Expected the pull of a drifted checkout to fail with %v", policy)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Expected no error with %v, found %v", policy, err)
+		}
+		if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v1" {
+			t.Errorf("Expected the local changes to be gone with %v, found %q", policy, b)
+		}
+		if _, err := os.Stat(filepath.Join(repo.Path, "db.example.net")); !os.IsNotExist(err) {
+			t.Errorf("Expected the untracked file to be gone with %v", policy)
+		}
+		out, _ := exec.Command("git", "-C", repo.Path, "stash", "list").Output()
+		if stashed := len(out) > 0; stashed != (policy == driftStash) {
+			t.Errorf("Expected stash list with %v, found %q", policy, out)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// dryRun checks what a pull would change without touching the checkout,
+// and logs it. The repository is fetched if it was cloned already, or
+// only listed remotely if not.
+func (r *Repo) dryRun(ctx context.Context) error {
+	if !r.pulled {
+		ref := "refs/heads/" + r.Branch
+		if r.Branch == latestTag {
+			ref = "refs/tags/*"
+		}
+		out, err := r.gitOutput(ctx, []string{"ls-remote", r.URL, ref}, "")
+		if err != nil {
+			return fmt.Errorf("dry run: cannot list %v: %s", r, err)
+		}
+		if out == "" {
+			return fmt.Errorf("dry run: %v has no %v", r, ref)
+		}
+		log.Infof("dry run: would clone %v (%v) into %v", r, strings.Fields(out)[0], r.workDir())
+		r.setLastPull(time.Now())
+		return nil
+	}
+
+	head, err := r.mostRecentCommit(ctx)
+	if err != nil {
+		return err
+	}
+	r.lastCommit = head
+
+	var target string
+	if r.Branch == latestTag {
+		tag, err := r.fetchLatestTag(ctx)
+		if err != nil {
+			return err
+		}
+		if tag == "" {
+			return fmt.Errorf("no tags found for repo: %v", r)
+		}
+		target = "tags/" + tag
+	} else {
+		if err := r.gitCmd(ctx, []string{"fetch", "--progress", "origin", r.Branch}, r.workDir()); err != nil {
+			return err
+		}
+		target = "FETCH_HEAD"
+	}
+
+	next, err := runCmdOutput(ctx, "git", []string{"rev-parse", target + "^{commit}"}, r.workDir())
+	if err != nil {
+		return err
+	}
+	r.setLastPull(time.Now())
+
+	if next == head {
+		log.Infof("dry run: %v is up to date at %v", r, head)
+		return nil
+	}
+	stat, err := runCmdOutput(ctx, "git", []string{"diff", "--shortstat", head, next}, r.workDir())
+	if err != nil {
+		return err
+	}
+	log.Infof("dry run: would update %v from %v to %v: %v", r, head, next, stat)
+	return nil
+}
@@ -0,0 +1,225 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// serviceAccount is the directory of the credentials of the service
+// account of pods.
+const serviceAccount = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// microTime is the format of the times of Kubernetes Leases.
+const microTime = "2006-01-02T15:04:05.000000Z07:00"
+
+// kubeClient calls the Kubernetes API.
+type kubeClient struct {
+	base      string    // URL of the API server
+	tokenFile string    // file of the bearer token, rotated by the kubelet
+	token     string    // bearer token
+	tokenTime time.Time // modification time of tokenFile token was read at
+	client    *http.Client
+}
+
+// newKubeClient returns a client of the API server of the cluster the
+// process runs in, with the credentials of its service account.
+var newKubeClient = func() (*kubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running in a Kubernetes cluster")
+	}
+	if _, err := os.Stat(serviceAccount + "/token"); err != nil {
+		return nil, err
+	}
+	ca, err := os.ReadFile(serviceAccount + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(ca)
+	return &kubeClient{
+		base:      "https://" + net.JoinHostPort(host, port),
+		tokenFile: serviceAccount + "/token",
+		client: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+// bearer returns the bearer token of c, read again from its file once the
+// kubelet rotated it.
+func (c *kubeClient) bearer() string {
+	if c.tokenFile == "" {
+		return c.token
+	}
+	if fi, err := os.Stat(c.tokenFile); err == nil && !fi.ModTime().Equal(c.tokenTime) {
+		if b, err := os.ReadFile(c.tokenFile); err == nil {
+			c.token, c.tokenTime = strings.TrimSpace(string(b)), fi.ModTime()
+		}
+	}
+	return c.token
+}
+
+// kubeAPI returns the client of the Kubernetes API of Election, made once
+// for r and reused by every pull. Its idle connections are closed once r
+// is stopped.
+func (r *Repo) kubeAPI() (*kubeClient, error) {
+	if r.kube != nil {
+		return r.kube, nil
+	}
+	c, err := newKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-r.lifetime().Done()
+		c.client.CloseIdleConnections()
+	}()
+	r.kube = c
+	return c, nil
+}
+
+// lease is the subset of a coordination.k8s.io/v1 Lease used for leader
+// election.
+type lease struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Metadata   json.RawMessage `json:"metadata"`
+	Spec       struct {
+		HolderIdentity       string `json:"holderIdentity,omitempty"`
+		LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+		AcquireTime          string `json:"acquireTime,omitempty"`
+		RenewTime            string `json:"renewTime,omitempty"`
+		LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+	} `json:"spec"`
+}
+
+// expired reports whether the holder of l no longer holds it.
+func (l *lease) expired() bool {
+	if l.Spec.HolderIdentity == "" {
+		return true
+	}
+	renewed, err := time.Parse(microTime, l.Spec.RenewTime)
+	if err != nil {
+		return true
+	}
+	return time.Since(renewed) > time.Duration(l.Spec.LeaseDurationSeconds)*time.Second
+}
+
+// do sends the request and decodes the lease it returns, reporting the
+// status code of the response.
+func (c *kubeClient) do(ctx context.Context, method, path string, in *lease) (*lease, int, error) {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return nil, 0, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, &body)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.bearer())
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, resp.StatusCode, nil
+	}
+	out := &lease{}
+	return out, resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// electionPath returns the namespace and name of the Lease of Election,
+// and the API path of its namespace, in the namespace of the pod if
+// Election has none.
+func (r *Repo) electionPath() (string, string) {
+	namespace, name, ok := strings.Cut(r.Election, "/")
+	if !ok {
+		name = namespace
+		b, _ := os.ReadFile(serviceAccount + "/namespace")
+		if namespace = strings.TrimSpace(string(b)); namespace == "" {
+			namespace = "default"
+		}
+	}
+	return "/apis/coordination.k8s.io/v1/namespaces/" + namespace + "/leases", name
+}
+
+// acquireElection takes or renews the Kubernetes Lease of Election, for
+// Lease, and reports whether this process holds it. The Lease is updated
+// with its resourceVersion, so of replicas racing for an expired Lease
+// only one takes it.
+func (r *Repo) acquireElection(ctx context.Context) (bool, error) {
+	c, err := r.kubeAPI()
+	if err != nil {
+		return false, err
+	}
+	path, name := r.electionPath()
+	now := time.Now().Format(microTime)
+
+	l, status, err := c.do(ctx, http.MethodGet, path+"/"+name, nil)
+	switch {
+	case err != nil:
+		return false, err
+	case status == http.StatusNotFound:
+		l = &lease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease", Metadata: json.RawMessage(fmt.Sprintf(`{"name":%q}`, name))}
+		l.Spec.HolderIdentity, l.Spec.AcquireTime = leaseHolder, now
+	case status != http.StatusOK:
+		return false, fmt.Errorf("GET lease %v: status %d", name, status)
+	case l.Spec.HolderIdentity == leaseHolder:
+	case !l.expired():
+		return false, nil
+	default:
+		l.Spec.HolderIdentity, l.Spec.AcquireTime = leaseHolder, now
+		l.Spec.LeaseTransitions++
+	}
+	l.Spec.RenewTime = now
+	l.Spec.LeaseDurationSeconds = int(r.Lease.Seconds())
+
+	method, target := http.MethodPut, path+"/"+name
+	if status == http.StatusNotFound {
+		method, target = http.MethodPost, path
+	}
+	_, status, err = c.do(ctx, method, target, l)
+	switch {
+	case err != nil:
+		return false, err
+	case status == http.StatusConflict:
+		// another replica updated it first
+		return false, nil
+	case status/100 != 2:
+		return false, fmt.Errorf("%s lease %v: status %d", method, name, status)
+	}
+	return true, nil
+}
+
+// releaseElection gives the Kubernetes Lease of Election up if this
+// process holds it, so another replica takes it over without waiting for
+// it to expire.
+func (r *Repo) releaseElection(ctx context.Context) error {
+	c, err := r.kubeAPI()
+	if err != nil {
+		return err
+	}
+	path, name := r.electionPath()
+	l, status, err := c.do(ctx, http.MethodGet, path+"/"+name, nil)
+	if err != nil || status != http.StatusOK || l.Spec.HolderIdentity != leaseHolder {
+		return err
+	}
+	l.Spec.HolderIdentity, l.Spec.LeaseDurationSeconds = "", 1
+	_, _, err = c.do(ctx, http.MethodPut, path+"/"+name, l)
+	return err
+}
@@ -0,0 +1,117 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestElection(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		stored  *lease
+		version int
+	)
+	const path = "/apis/coordination.k8s.io/v1/namespaces/dns/leases"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if req.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case req.Method == http.MethodGet && req.URL.Path == path+"/zones":
+			if stored == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(stored)
+		case req.Method == http.MethodPost && req.URL.Path == path && stored == nil,
+			req.Method == http.MethodPut && req.URL.Path == path+"/zones" && stored != nil:
+			l := &lease{}
+			json.NewDecoder(req.Body).Decode(l)
+			var meta struct{ ResourceVersion int }
+			json.Unmarshal(l.Metadata, &meta)
+			if meta.ResourceVersion != version {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			version++
+			l.Metadata, _ = json.Marshal(map[string]any{"name": "zones", "resourceVersion": version, "labels": map[string]string{}})
+			stored = l
+			json.NewEncoder(w).Encode(l)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	inCluster, clients := newKubeClient, 0
+	newKubeClient = func() (*kubeClient, error) {
+		clients++
+		return &kubeClient{base: srv.URL, token: "token", client: srv.Client()}, nil
+	}
+	defer func() { newKubeClient = inCluster }()
+
+	repo := &Repo{Election: "dns/zones", Lease: time.Minute}
+	if held, err := repo.acquireLease(context.Background()); err != nil || !held {
+		t.Fatalf("Expected lease to be created, found %v, %v", held, err)
+	}
+	if held, err := repo.acquireLease(context.Background()); err != nil || !held {
+		t.Fatalf("Expected lease to be renewed, found %v, %v", held, err)
+	}
+
+	// held by another replica until it expires
+	mu.Lock()
+	stored.Spec.HolderIdentity = "other"
+	mu.Unlock()
+	if held, err := repo.acquireLease(context.Background()); err != nil || held {
+		t.Errorf("Expected lease of another replica not to be acquired, found %v, %v", held, err)
+	}
+	mu.Lock()
+	stored.Spec.RenewTime = time.Now().Add(-2 * time.Minute).Format(microTime)
+	mu.Unlock()
+	if held, err := repo.acquireLease(context.Background()); err != nil || !held {
+		t.Errorf("Expected expired lease to be taken over, found %v, %v", held, err)
+	}
+	if stored.Spec.LeaseTransitions != 1 {
+		t.Errorf("Expected 1 lease transition, found %d", stored.Spec.LeaseTransitions)
+	}
+
+	repo.releaseLease()
+	if stored.Spec.HolderIdentity != "" {
+		t.Errorf("Expected lease to be released, found holder %v", stored.Spec.HolderIdentity)
+	}
+	if clients != 1 {
+		t.Errorf("Expected a single client of the API, found %d", clients)
+	}
+}
+
+func TestKubeClientBearer(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := &kubeClient{tokenFile: file}
+	if token := c.bearer(); token != "first" {
+		t.Errorf("Expected token first, found %q", token)
+	}
+
+	// rotated by the kubelet
+	if err := os.WriteFile(file, []byte("second\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if token := c.bearer(); token != "second" {
+		t.Errorf("Expected rotated token second, found %q", token)
+	}
+}
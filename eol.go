@@ -0,0 +1,31 @@
+package git
+
+import (
+	"bytes"
+	"os"
+)
+
+// binaryPeek is how many bytes of a file are looked at for a NUL byte to
+// tell binary files from text files, as git does.
+const binaryPeek = 8000
+
+// normalizeEOL atomically replaces the CRLF line endings of the file at
+// path with LF, keeping its permissions. Binary files are left as is.
+func normalizeEOL(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	peek := b
+	if len(peek) > binaryPeek {
+		peek = peek[:binaryPeek]
+	}
+	if bytes.IndexByte(peek, 0) >= 0 || !bytes.Contains(b, []byte("\r\n")) {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return replaceFile(bytes.NewReader(bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))), path, fi.Mode().Perm())
+}
@@ -0,0 +1,36 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPublishEOL(t *testing.T) {
+	dir := t.TempDir()
+	repo := &Repo{Path: filepath.Join(dir, "zones"), Subpath: "dns", EOL: true}
+	repo.lastCommit = "c1"
+	writeFiles(t, repo.workDir(), map[string]string{
+		"dns/db.example.org": "$ORIGIN example.org.\r\n@ IN A 192.0.2.1\r\n",
+		"dns/db.example.net": "$ORIGIN example.net.\n@ IN A 192.0.2.2\n",
+		"dns/key.bin":        "\x00\x01\r\n\x02",
+	})
+	if err := repo.publish(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"db.example.org": "$ORIGIN example.org.\n@ IN A 192.0.2.1\n",
+		"db.example.net": "$ORIGIN example.net.\n@ IN A 192.0.2.2\n",
+		"key.bin":        "\x00\x01\r\n\x02",
+	}
+	for name, content := range expected {
+		b, err := os.ReadFile(filepath.Join(repo.Path, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("%s: expected %q, got %q", name, content, b)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Sources that trigger a pull.
+const (
+	sourceStartup  = "startup"
+	sourceInterval = "interval"
+	sourceManual   = "manual"
+	sourceAdmin    = "admin"
+	sourceControl  = "control"
+	sourceSignal   = "signal"
+	sourceWatch    = "watch"
+	sourceNotify   = "notify"
+	sourceDNS      = "dns"
+	sourcePlugin   = "plugin"
+)
+
+// PullEvent describes the outcome of a single Pull.
+type PullEvent struct {
+	Time       time.Time `json:"time"`
+	Repo       string    `json:"repo"`
+	Branch     string    `json:"branch"`
+	Trigger    string    `json:"trigger"`
+	OldCommit  string    `json:"old_commit,omitempty"`
+	NewCommit  string    `json:"new_commit,omitempty"`
+	Duration   float64   `json:"duration_seconds"`
+	Error      string    `json:"error,omitempty"`
+	ErrorClass string    `json:"error_class,omitempty"`
+}
+
+// changed reports whether the pull moved the checkout to another commit.
+func (e PullEvent) changed() bool { return e.Error == "" && e.OldCommit != e.NewCommit }
+
+// logPull logs e in the log format configured for r.
+func (r *Repo) logPull(e PullEvent) {
+	if r.LogFormat == "json" {
+		b, err := json.Marshal(e)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if e.Error != "" {
+			log.Error(string(b))
+			return
+		}
+		log.Info(string(b))
+		return
+	}
+
+	switch {
+	case e.Error != "":
+		log.Errorf("pull failed (%s): %v: %s", e.ErrorClass, e.Repo, e.Error)
+	case e.changed():
+		log.Infof("pulled: %v", e.Repo)
+	default:
+		log.Info("No new changes")
+	}
+}
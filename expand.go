@@ -0,0 +1,49 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	// envPlaceholder matches {$NAME} environment variable placeholders.
+	envPlaceholder = regexp.MustCompile(`\{\$([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+	// filePlaceholder matches {file:PATH} secret file placeholders.
+	filePlaceholder = regexp.MustCompile(`\{file:([^}]+)\}`)
+)
+
+// expand replaces the {$NAME} placeholders in s with the value of the
+// environment variable NAME, and the {file:PATH} placeholders with the
+// content of the file at PATH, without surrounding white space. It fails
+// if a variable is not set or a file can't be read. Files are not read
+// with ValidateOnly, their placeholders are kept.
+//
+// The Corefile parser already replaces the environment variables of a
+// Corefile, unset ones with an empty string: only the values read from
+// elsewhere, such as manifests, fail on unset variables.
+func expand(s string) (string, error) {
+	var err error
+	s = envPlaceholder.ReplaceAllStringFunc(s, func(m string) string {
+		name := envPlaceholder.FindStringSubmatch(m)[1]
+		v, ok := os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v
+	})
+	if ValidateOnly {
+		return s, err
+	}
+	s = filePlaceholder.ReplaceAllStringFunc(s, func(m string) string {
+		path := filePlaceholder.FindStringSubmatch(m)[1]
+		b, ferr := os.ReadFile(path)
+		if ferr != nil && err == nil {
+			err = fmt.Errorf("cannot read secret file: %s", ferr)
+		}
+		return strings.TrimSpace(string(b))
+	})
+	return s, err
+}
@@ -0,0 +1,99 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// defaultMaxFailures is the number of pulls failing in a row before
+// FailureHook is called by default.
+const defaultMaxFailures = 3
+
+// hookClient is the HTTP client calling FailureHook.
+var hookClient = http.DefaultClient
+
+// FailureEvent is posted as JSON to FailureHook once pulls failed
+// MaxFailures times in a row: the last failed pull, the resolver and the
+// number of failures.
+type FailureEvent struct {
+	PullEvent
+	Host     string `json:"host"`
+	Failures int    `json:"failures"`
+}
+
+// countFailure counts the pulls failing in a row, e being the last one,
+// and calls FailureHook once they reach MaxFailures, or right away if e
+// failed with an error which retrying does not fix, such as rejected
+// credentials. The hook is called once for failures in a row: a
+// successful pull resets the count, so it is called again if pulls fail
+// again.
+func (r *Repo) countFailure(e PullEvent) {
+	if e.Error == "" {
+		r.failures, r.alerted = 0, false
+		return
+	}
+	r.failures++
+	if r.FailureHook == "" || r.alerted || r.failures < r.MaxFailures && !persistent(e.ErrorClass) {
+		return
+	}
+	r.alerted = true
+	host, _ := os.Hostname()
+	if err := r.callFailureHook(FailureEvent{PullEvent: e, Host: host, Failures: r.failures}); err != nil {
+		log.Warningf("cannot call the failure hook of %v: %s", r, err)
+		return
+	}
+	log.Infof("called the failure hook of %v after %d failed pulls", r, r.failures)
+}
+
+// breakCircuit opens the circuit breaker of r once Breaker pulls failed in
+// a row: r is unhealthy and its periodic pulls stop, but for a trial pull
+// every MaxAge, until a pull succeeds and closes it.
+func (r *Repo) breakCircuit(now time.Time) {
+	if r.Breaker == 0 {
+		return
+	}
+	open := r.failures >= r.Breaker
+	if was := r.tripped.Swap(open); open && !was {
+		log.Warningf("circuit breaker of %v open after %d failed pulls, trying again every %v", r, r.failures, r.MaxAge)
+	} else if !open && was {
+		log.Infof("circuit breaker of %v closed", r)
+	}
+	if open && r.backoff.Before(now.Add(r.MaxAge)) {
+		r.backoff = now.Add(r.MaxAge)
+	}
+}
+
+// callFailureHook posts e to FailureHook. Its errors leave out the URL of
+// the hook, which often holds a token.
+func (r *Repo) callFailureHook(e FailureEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(r.lifetime(), forgeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.FailureHook, bytes.NewReader(b))
+	if err != nil {
+		return errors.New("invalid URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hookClient.Do(req)
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
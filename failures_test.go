@@ -0,0 +1,81 @@
+package git
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCountFailure(t *testing.T) {
+	var posted []FailureEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var e FailureEvent
+		if err := json.NewDecoder(req.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		posted = append(posted, e)
+	}))
+	defer srv.Close()
+
+	repo := &Repo{URL: "https://github.com/user/zones", FailureHook: srv.URL + "/hook?token=secret", MaxFailures: 2}
+	for _, failed := range []bool{true, false, true, true, true, false, true, true} {
+		e := PullEvent{Repo: repo.String()}
+		if failed {
+			e.Error = "cannot pull"
+		}
+		repo.countFailure(e)
+	}
+	if len(posted) != 2 {
+		t.Fatalf("Expected the hook to be called twice, found %v", posted)
+	}
+	if e := posted[0]; e.Failures != 2 || e.Error != "cannot pull" || e.Repo != "https://github.com/user/zones" {
+		t.Errorf("Unexpected failure event %+v", e)
+	}
+}
+
+func TestCountFailurePersistent(t *testing.T) {
+	var posted []FailureEvent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var e FailureEvent
+		if err := json.NewDecoder(req.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		posted = append(posted, e)
+	}))
+	defer srv.Close()
+
+	repo := &Repo{URL: "https://github.com/user/zones", FailureHook: srv.URL, MaxFailures: 3}
+	for _, class := range []string{classTransient, classAuth, classAuth, classAuth} {
+		repo.countFailure(PullEvent{Repo: repo.String(), Error: "cannot pull", ErrorClass: class})
+	}
+	if len(posted) != 1 {
+		t.Fatalf("Expected the hook to be called once, found %v", posted)
+	}
+	if e := posted[0]; e.Failures != 2 || e.ErrorClass != classAuth {
+		t.Errorf("Unexpected failure event %+v", e)
+	}
+}
+
+func TestBreakCircuit(t *testing.T) {
+	now := time.Now()
+	repo := &Repo{URL: "https://github.com/user/zones", MaxAge: time.Hour, Breaker: 2}
+	repo.setLastPull(now)
+	for i, failed := range []bool{true, true, true, false} {
+		e := PullEvent{Repo: repo.String()}
+		if failed {
+			e.Error = "cannot pull"
+		}
+		repo.countFailure(e)
+		repo.backOff(e.ErrorClass, now)
+		repo.breakCircuit(now)
+		open := i == 1 || i == 2
+		if repo.Healthy() == open {
+			t.Errorf("Expected healthy %v after pull %d", !open, i)
+		}
+		if open != repo.backoff.Equal(now.Add(time.Hour)) {
+			t.Errorf("Expected periodic pulls backed off %v after pull %d, found %v", open, i, repo.backoff)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package git
+
+import "strconv"
+
+// fetchConfig returns the configuration of git fetching FetchJobs
+// submodules, or remotes, in parallel, with the submodules of the
+// checkout being cloned and pulled with --recurse-submodules in CloneArgs
+// and PullArgs.
+func (r *Repo) fetchConfig() []string {
+	if r.FetchJobs == 0 {
+		return nil
+	}
+	jobs := strconv.Itoa(r.FetchJobs)
+	return []string{"-c", "fetch.parallel=" + jobs, "-c", "submodule.fetchJobs=" + jobs}
+}
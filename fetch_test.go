@@ -0,0 +1,17 @@
+package git
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFetchConfig(t *testing.T) {
+	repo := &Repo{URL: "https://github.com/user/zones", FetchJobs: 8, PullArgs: []string{"--recurse-submodules"}}
+	expected := []string{"-c", "fetch.parallel=8", "-c", "submodule.fetchJobs=8", "pull"}
+	if params := repo.gitParams([]string{"pull"}); !reflect.DeepEqual(params, expected) {
+		t.Errorf("Expected parameters %q, found %q", expected, params)
+	}
+	if config := (&Repo{URL: repo.URL}).fetchConfig(); config != nil {
+		t.Errorf("Expected no configuration by default, found %q", config)
+	}
+}
@@ -0,0 +1,56 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// lockPoll is how often a lock held by another process is tried again.
+const lockPoll = 100 * time.Millisecond
+
+// errLocked is returned by tryLock when another process holds the lock.
+var errLocked = errors.New("locked")
+
+// lockFile returns the lock file of the checkout, next to it.
+func (r *Repo) lockFile() string {
+	dir := r.workDir()
+	return filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+".lock")
+}
+
+// lockCheckout takes the lock of the checkout, so that another process,
+// such as a sidecar or a second CoreDNS, running git on it doesn't corrupt
+// it. It waits until the lock is released or ctx is done, and returns the
+// function releasing it.
+func (r *Repo) lockCheckout(ctx context.Context) (func(), error) {
+	f, err := os.OpenFile(r.lockFile(), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	waiting := false
+	for {
+		err := tryLock(f)
+		if err == nil {
+			return func() {
+				unlock(f)
+				f.Close()
+			}, nil
+		}
+		if !errors.Is(err, errLocked) {
+			f.Close()
+			return nil, err
+		}
+		if !waiting {
+			log.Infof("Waiting for another process to release %v", r.lockFile())
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(lockPoll):
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package git
+
+import "os"
+
+// tryLock does nothing: advisory locks are not supported, the checkout
+// is not protected from other processes.
+func tryLock(f *os.File) error { return nil }
+
+// unlock does nothing.
+func unlock(f *os.File) error { return nil }
@@ -0,0 +1,35 @@
+//go:build !windows && !plan9
+
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockCheckout(t *testing.T) {
+	repo := &Repo{Path: filepath.Join(t.TempDir(), "zones")}
+	unlock, err := repo.lockCheckout(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flock locks are per open file, so a second lock of the same process
+	// waits like one of another process
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockPoll)
+	defer cancel()
+	if _, err := repo.lockCheckout(ctx); err == nil {
+		t.Fatal("Expected locked checkout not to be locked again")
+	}
+
+	unlock()
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	unlock, err = repo.lockCheckout(ctx)
+	if err != nil {
+		t.Fatalf("Expected released lock to be taken, found %v", err)
+	}
+	unlock()
+}
@@ -0,0 +1,107 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// followPoll is how often followers check whether their checkout changed.
+var followPoll = time.Second
+
+// follow reads the commit of the checkout another process updates,
+// without modifying it. Git checkouts are read in process, without running
+// git.
+func (r *Repo) follow(ctx context.Context, b Backend, dir string) (string, error) {
+	// updates made while reading are read again
+	state := r.headState()
+	if !r.pulled {
+		if _, err := os.Stat(dir); err != nil {
+			return "", fmt.Errorf("checkout of %v is not cloned yet by the process updating it: %s", r, err)
+		}
+		// it may have been cloned since the start
+		if err := r.Prepare(); err != nil {
+			return "", err
+		}
+		if !r.pulled {
+			return "", fmt.Errorf("checkout of %v is not cloned yet by the process updating it", r)
+		}
+	}
+	if _, ok := b.(execBackend); ok {
+		b = goGitBackend{}
+	}
+	commit, err := b.Head(ctx, r, dir)
+	if err == nil {
+		r.followed.Store(state)
+	}
+	return commit, err
+}
+
+// headFiles returns the files of the checkout updates change.
+func (r *Repo) headFiles() []string {
+	dir := r.workDir()
+	switch r.Backend {
+	case backendHg:
+		return []string{filepath.Join(dir, ".hg", "dirstate")}
+	case backendArchive, backendRaw:
+		return []string{filepath.Join(dir, archiveMeta, "commit")}
+	}
+	git := filepath.Join(dir, ".git")
+	files := []string{filepath.Join(git, "HEAD"), filepath.Join(git, "index"), filepath.Join(git, "packed-refs")}
+	// the branch checked out, updated after the index
+	if b, err := os.ReadFile(files[0]); err == nil {
+		if ref, ok := strings.CutPrefix(strings.TrimSpace(string(b)), "ref: "); ok {
+			files = append(files, filepath.Join(git, filepath.FromSlash(ref)))
+		}
+	}
+	return files
+}
+
+// headState returns the modification times and sizes of the headFiles.
+func (r *Repo) headState() string {
+	var state string
+	for _, f := range r.headFiles() {
+		if fi, err := os.Stat(f); err == nil {
+			state += fmt.Sprintf("%d:%d;", fi.ModTime().UnixNano(), fi.Size())
+		} else {
+			state += "-;"
+		}
+	}
+	return state
+}
+
+// startWatch starts a background service reading the checkout of the
+// follower repo as soon as another process updates it, once it changed
+// since the last read.
+func startWatch(repo *Repo) {
+	service := &repoService{
+		repo,
+		time.NewTicker(followPoll),
+		make(chan struct{}),
+		make(chan struct{}),
+	}
+	// the checkout as the service starts, before the first read
+	repo.followed.CompareAndSwap(nil, repo.headState())
+	go func(s *repoService) {
+		defer close(s.done)
+		defer s.ticker.Stop()
+		for {
+			select {
+			case <-s.ticker.C:
+				// a pull skipped as too close to the last one is retried
+				if repo.headState() == repo.followed.Load() {
+					continue
+				}
+				repo.pullFrom(repo.lifetime(), sourceWatch)
+			case <-s.halt:
+				return
+			}
+		}
+	}(service)
+
+	// add to services to make it stoppable
+	Services.add(service)
+}
@@ -0,0 +1,64 @@
+package git
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFollow(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	src, checkout := newOrigin(t, map[string]string{"db.example.org": "v1"}), filepath.Join(dir, "zones")
+	runGit(t, dir, "clone", "-q", src, checkout)
+
+	head := func(dir string) string {
+		out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	repo := &Repo{URL: src, Path: checkout, Branch: "master", Follow: true, HistorySize: 10}
+	if err := repo.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.PullContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if repo.Commit() != head(src) {
+		t.Errorf("Expected commit %v, found %v", head(src), repo.Commit())
+	}
+
+	poll := followPoll
+	followPoll = 10 * time.Millisecond
+	defer func() { followPoll = poll }()
+	repo.Interval = -1
+	repo.lastPull = time.Now().Add(-time.Minute)
+	Start(repo)
+	defer Services.stopRepo(repo)
+
+	// updated by another process
+	writeFiles(t, src, map[string]string{"db.example.org": "v2"})
+	runGit(t, src, "commit", "-q", "-am", "v2")
+	runGit(t, checkout, "pull", "-q", "origin", "master")
+	deadline := time.Now().Add(10 * time.Second)
+	for repo.Commit() != head(src) && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if repo.Commit() != head(src) {
+		t.Errorf("Expected followed commit %v, found %v", head(src), repo.Commit())
+	}
+	if h := repo.History(); h[len(h)-1].Trigger != sourceWatch {
+		t.Errorf("Expected last pull triggered by watch, found %v", h[len(h)-1].Trigger)
+	}
+	if err := repo.Rollback(); err == nil {
+		t.Error("Expected followed checkout not to be rolled back")
+	}
+}
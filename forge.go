@@ -0,0 +1,113 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Forges hosting repositories, whose APIs the plugin calls.
+const (
+	forgeGitHub = "github"
+	forgeGitLab = "gitlab"
+)
+
+// forgeTimeout is the max duration of a request to the API of a forge.
+const forgeTimeout = 10 * time.Second
+
+// forgeClient is the HTTP client of the APIs of forges.
+var forgeClient = http.DefaultClient
+
+// forge returns the forge hosting r, Forge if set, otherwise GitLab for
+// hosts named so and GitHub for others, and the base URL of its API.
+func (r *Repo) forge() (forge, api string) {
+	_, host := remoteOf(r.URL)
+	forge = r.Forge
+	if forge == "" {
+		forge = forgeGitHub
+		if strings.Contains(host, "gitlab") {
+			forge = forgeGitLab
+		}
+	}
+	switch {
+	case r.ForgeAPI != "":
+		api = r.ForgeAPI
+	case forge == forgeGitLab:
+		api = "https://" + hostURL(host) + "/api/v4"
+	case host == "github.com":
+		api = "https://api.github.com"
+	default:
+		api = "https://" + hostURL(host) + "/api/v3"
+	}
+	return forge, strings.TrimSuffix(api, "/")
+}
+
+// forgeToken returns the token authenticating to the API of the forge of
+// r: ForgeToken, or the credentials of its URL, the password or else the
+// user name, which usually is an access token.
+func (r *Repo) forgeToken() string {
+	if r.ForgeToken != "" {
+		return r.ForgeToken
+	}
+	user, password := r.credentials()
+	if password != "" {
+		return password
+	}
+	return user
+}
+
+// forgeProject returns the project of r in the paths of the API of its
+// forge: owner/name for GitHub, its escaped path for GitLab.
+func (r *Repo) forgeProject() string {
+	if forge, _ := r.forge(); forge == forgeGitLab {
+		return url.PathEscape(projectOf(r.URL))
+	}
+	return projectOf(r.URL)
+}
+
+// forgeRequest calls the API of the forge of r with method on path,
+// relative to its base URL, sending in and decoding the response into out
+// as JSON, unless they are nil.
+func (r *Repo) forgeRequest(ctx context.Context, method, path string, in, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, forgeTimeout)
+	defer cancel()
+	_, api := r.forge()
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, api+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token := r.forgeToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := forgeClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", method, api+path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -1,14 +1,20 @@
 package git
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"net/mail"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/coredns/caddy"
+	gogit "github.com/go-git/go-git/v5"
+
+	ot "github.com/opentracing/opentracing-go"
 )
 
 const (
@@ -33,159 +39,636 @@ func (g Git) Repo(i int) *Repo {
 // Repo is the structure that holds required information
 // of a git repository.
 type Repo struct {
-	URL        string        // Repository URL
-	Path       string        // Directory to pull to
-	Branch     string        // Git branch
-	Interval   time.Duration // Interval between pulls
-	CloneArgs  []string      // Additonal cli args to pass to git clone
-	PullArgs   []string      // Additonal cli args to pass to git pull
-	pulled     bool          // true if there was a successful pull
-	lastPull   time.Time     // time of the last successful pull
-	lastCommit string        // hash for the most recent commit
-	latestTag  string        // latest tag name
+	Name        string        // Name identifying the repository
+	URL         string        // Repository URL
+	Password    string        // Password of an HTTP(S) URL, kept out of it for git not to store it
+	Path        string        // Directory to pull to
+	Branch      string        // Git branch
+	Interval    time.Duration // Interval between pulls
+	Stagger     time.Duration // Window the periodic pulls of resolvers are spread over
+	CloneArgs   []string      // Additonal cli args to pass to git clone
+	PullArgs    []string      // Additonal cli args to pass to git pull
+	Depth       int           // Number of commits cloned, deepened for the history pulls need, all if 0
+	FetchJobs   int           // Number of submodules and remotes fetched in parallel, as git's default if 0
+	MaxAge      time.Duration // Max time since the last successful pull to be healthy
+	Breaker     int           // Number of pulls failing in a row opening the circuit breaker, none if 0
+	SkipReady   bool          // Don't wait for the first pull to report ready
+	AsyncStart  bool          // Don't wait for the first pull to start the server
+	MaxStart    time.Duration // Max time to wait for the first pull to start the server
+	RetryStart  bool          // Retry a failed first pull in background instead of failing to start
+	EarlyPull   bool          // Do the first pull when set up, before the plugins set up after git
+	LogFormat   string        // Format of pull logs, "text" or "json"
+	AuditLog    string        // File to append pull events to, or "syslog"
+	HistorySize int           // Number of pull events kept in memory
+	Chaos       bool          // Answer version.git.bind CHAOS TXT queries
+	StatusZone  string        // Zone to serve the status of the repository in
+	Admin       string        // Address of the HTTP admin endpoint
+	AdminToken  string        // Bearer token required by the admin endpoint, none if empty
+	Control     string        // Path of the control socket
+	ControlZone string        // Zone of the TSIG-signed control queries and updates
+	ControlKeys []string      // Names of the TSIG keys allowed in ControlZone, any if empty
+	PullSignal  string        // Signal triggering a pull, without the SIG prefix
+	Maps        []Mapping     // Subdirectories to publish elsewhere
+	Subpath     string        // Only directory of the repository published at Path
+	Overlay     string        // Directory of overlays layered on base published at Path
+	DryRun      bool          // Only log what pulls would change
+	Backend     string        // Name of the backend pulling the repository, empty for exec
+	Follow      bool          // Only read the checkout another process updates
+	NoShell     bool          // Forbid git from running commands through a shell
+	RunAs       *Owner        // User and group external commands run as, nil for this process
+	FileMode    os.FileMode   // Permissions of the checked out files, 0 for the recorded ones
+	DirMode     os.FileMode   // Permissions of the checked out directories, 0 for the default
+	Umask       os.FileMode   // Permissions removed from the checked out files and directories
+	StripModes  bool          // Remove the executable bits and special modes of the checked out files
+	Owner       *Owner        // User and group the published checkout is chowned to
+	Hosts       []string      // Patterns of the hosts remotes are allowed on, all if empty
+	Schemes     []string      // Schemes of the remotes allowed, all if empty
+	Files       []string      // Files of the repository downloaded by the raw backend
+	Mirrors     []string      // Other URLs of the repository, pulled from when URL fails
+	Resolver    string        // DNS server resolving the hosts of the remotes, the one of the host if empty
+	Addresses   []string      // Addresses of hosts of the remotes as HOST=ADDRESS, not resolved
+	Headers     []string      // Extra headers of the requests to HTTP(S) remotes, as NAME: VALUE
+	UserAgent   string        // User-Agent of the requests to HTTP(S) remotes, the one of git if empty
+	KeepAlive   time.Duration // Interval of the keep-alive messages of ssh, none if 0
+	AliveCount  int           // Number of keep-alive messages unanswered before ssh drops a connection
+	Multiplex   time.Duration // How long ssh keeps a connection open for the next commands, none if 0
+	Bandwidth   int64         // Max bytes per second transferred from the remotes, unlimited if 0
+	Redirects   string        // Whether redirects of HTTP(S) remotes are followed: true, false or initial, as by default if empty
+	InMemory    bool          // Keep the go-git repository in memory, exporting its files
+	Lease       time.Duration // TTL of the lease on a checkout shared by replicas, 0 for none
+	Election    string        // Kubernetes Lease electing the replica pulling, as NAMESPACE/NAME
+	Export      []string      // Globs of the files exported with InMemory, all if empty
+	Bundles     string        // Object store prefix holding git bundles of the repository
+	BundleFirst bool          // Whether to pull from Bundles before URL
+	MaxSize     int64         // Max bytes of the checkout, 0 for no limit
+	BaseDir     string        // Directory the repository only writes inside, anywhere if empty
+	Symlinks    string        // Policy of the symbolic links of the repository, empty to copy them
+	PublishOnly []string      // Globs of the files published by Maps and Subpath, all if empty
+	Render      bool          // Render the templates published by Maps and Subpath
+	Values      string        // File of the repository holding the values of templates
+	Vars        []string      // Values of templates as NAME=VALUE, overriding the ones of Values
+	Records     bool          // Compile the files of records published by Maps and Subpath
+	Includes    bool          // Flatten the $INCLUDE directives of the files published by Maps and Subpath
+	Assemblies  []Assembly    // Zone files assembled from fragments of the repository
+	Decrypt     bool          // Decrypt the SOPS and age files published by Maps and Subpath
+	DecryptKey  string        // File of the age identity decrypting files
+	EOL         bool          // Convert the CRLF line endings of the text files published by Maps and Subpath
+	Xattrs      []Xattr       // Extended attributes set on the checked out files
+	Push        bool          // Commit the local changes of the checkout and push them
+	PushBranch  string        // Branch local changes are pushed to, Branch if empty
+	Updates     []Update      // Zones whose dynamic updates are committed to the repository
+	Notifies    []Notify      // Zones whose NOTIFY messages trigger a pull
+	DriftCheck  time.Duration // Interval between checks of the local changes of the checkout, 0 for none
+	OnDrift     string        // Policy for the local changes of the checkout before pulls, none if empty
+	Backups     []Backup      // Directories snapshotted into the repository
+	SigningKey  string        // Key the commits of the plugin are signed with, unsigned if empty
+	SignFormat  string        // Format of SigningKey: openpgp, ssh or x509
+	Author      *mail.Address // Author and committer of the commits of the plugin, CoreDNS if nil
+	Messages    []Message     // Templates of the messages of the commits of the plugin
+	PullRequest bool          // Open a pull request of PushBranch into Branch after pushing
+	Forge       string        // Forge hosting the repository, github or gitlab, by its host if empty
+	ForgeAPI    string        // Base URL of the API of Forge, by its host if empty
+	ForgeToken  string        // Token of the API of Forge, the credentials of URL if empty
+	StatusName  string        // Name of the commit statuses reported to Forge, none if empty
+	Environment string        // GitHub environment the commits pulled are reported deployed to, none if empty
+	FailureHook string        // URL posted to once pulls failed MaxFailures times in a row, none if empty
+	MaxFailures int           // Number of pulls failing in a row before FailureHook is called
+	pulled      bool          // true if there was a successful pull
+	lastPull    time.Time     // time of the last successful pull
+	lastCommit  string        // hash for the most recent commit
+	prevCommit  string        // hash of the commit checked out before lastCommit
+	published   string        // hash of the commit last published
+	unpushed    bool          // true if local changes were committed but not pushed yet
+	drifted     string        // local changes found by the last drift check
+	rejected    string        // hash of the commit rejected by the running pull
+	reported    string        // commit and state of the last status reported
+	deployed    string        // hash of the commit last reported deployed
+	failures    int           // number of the last pulls which failed in a row
+	alerted     bool          // true if FailureHook was called for the failures in a row
+	backoff     time.Time     // time periodic pulls are skipped until
+	latestTag   string        // latest tag name
+	pulledAt    atomic.Int64  // lastPull in unix nanoseconds, readable without the lock
+	commit      atomic.Value  // lastCommit, readable without the lock
+	followed    atomic.Value  // headState before the last read of a follower
+	nextPull    atomic.Int64  // time of the next scheduled pull in unix nanoseconds
+	paused      atomic.Bool   // true if periodic pulls are paused
+	tripped     atomic.Bool   // true while the circuit breaker is open
+	history     history       // most recent pull events
+	prev        *Repo         // running repository to take the checkout of
+	tracer      ot.Tracer     // tracer of the trace plugin
+	span        ot.Span       // span of the running pull
+	limiter     *limiter      // limiter of the transfers to Bandwidth
+	throttle    string        // URL of the proxy limiting git to Bandwidth
+	controlDir  string        // directory of the sockets of the connections of ssh
+	kube        *kubeClient   // client of the Kubernetes API of Election
 	sync.Mutex
+
+	// lifetime of the repository, canceled when it is stopped
+	ctx     context.Context
+	cancel  context.CancelFunc
+	ctxOnce sync.Once
+
+	// URL of the remote being pulled from, and when the ones which failed
+	// last did
+	remote string
+	failed map[string]time.Time
+
+	// addresses the hosts of the remotes last resolved to, by Resolver
+	resolved map[string]string
+
+	// repository kept in memory with InMemory
+	mem *gogit.Repository
+}
+
+// String returns the name of the repository, or its URL without
+// credentials if it has no name.
+func (r *Repo) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return redact(r.URL)
+}
+
+// matches reports whether s identifies the repository, by name or URL.
+func (r *Repo) matches(s string) bool {
+	return s != "" && (s == r.Name || s == r.URL || s == redact(r.URL))
 }
 
 // Pull attempts a git pull.
 // It retries at most numRetries times if error occurs
-func (r *Repo) Pull() error {
+func (r *Repo) Pull() error { return r.PullContext(context.Background()) }
+
+// PullContext is like Pull, but the git processes are killed and the
+// pull fails if ctx is done before it completes.
+func (r *Repo) PullContext(ctx context.Context) error { return r.pullFrom(ctx, sourceManual) }
+
+// TriggerPull pulls r right away on behalf of another plugin of the
+// server, e.g. one noticing the files it serves are missing data. Like
+// PullContext, it waits for the pull to complete, which is killed if ctx
+// is done first, and does nothing if the repository was pulled less than 5
+// seconds ago.
+func (r *Repo) TriggerPull(ctx context.Context) error { return r.pullFrom(ctx, sourcePlugin) }
+
+// pullFrom performs PullContext on behalf of source, which is recorded
+// in the logs of the pull. The outcome of the pull is logged, its error
+// included, so callers don't log it again.
+func (r *Repo) pullFrom(ctx context.Context, source string) error {
 	r.Lock()
 	defer r.Unlock()
+	ctx = r.commandContext(ctx)
 
 	// prevent a pull if the last one was less than 5 seconds ago
 	if time.Since(r.lastPull) < 5*time.Second {
 		return nil
 	}
+	// back off from a remote failing until it is fixed
+	if source == sourceInterval && time.Now().Before(r.backoff) {
+		log.Debugf("Periodic pull of %v backed off until %v", r, r.backoff.Format(time.RFC3339))
+		return nil
+	}
 
 	// keep last commit hash for comparison later
 	lastCommit := r.lastCommit
+	r.rejected = ""
+	start := time.Now()
+	span := r.startPullSpan(source)
 
 	var err error
-	// Attempt to pull at most numRetries times
-	for i := 0; i < numRetries; i++ {
-		if err = r.pull(); err == nil {
-			break
-		}
-		log.Warning(err)
+	if unlock, lerr := r.lockCheckout(ctx); lerr != nil {
+		err = fmt.Errorf("cannot lock the checkout of %v: %s", r, lerr)
+	} else {
+		err = r.pullRetry(ctx)
+		unlock()
+	}
+
+	event := PullEvent{
+		Time:      start,
+		Repo:      r.String(),
+		Branch:    r.Branch,
+		Trigger:   source,
+		OldCommit: lastCommit,
+		NewCommit: r.lastCommit,
+		Duration:  time.Since(start).Seconds(),
+	}
+	if err != nil {
+		event.Error = err.Error()
+		event.ErrorClass = errorClass(err)
+		pullFailures.WithLabelValues(r.String(), event.ErrorClass).Inc()
 	}
+	r.logPull(event)
+	r.audit(event)
+	r.history.add(event, r.HistorySize)
+	hooked := r.startPhase("hooks")
+	r.countFailure(event)
+	r.reportStatus(ctx, lastCommit, err)
+	r.reportDeployment(ctx, err)
+	hooked(nil)
+	r.backOff(event.ErrorClass, time.Now())
+	r.breakCircuit(time.Now())
+	finishSpan(span, err)
+	r.span = nil
 
 	if err != nil {
 		return err
 	}
 
-	// check if there are new changes,
-	// then execute post pull command
-	if r.lastCommit == lastCommit {
-		log.Info("No new changes")
+	if lastCommit != "" && r.lastCommit != lastCommit {
+		r.prevCommit = lastCommit
 	}
+	r.commit.Store(r.lastCommit)
+	updateRepoInfo(r)
 	return nil
 }
 
-// pull performs git pull, or git clone if repository does not exist.
-func (r *Repo) pull() error {
-
-	// if not pulled, perform clone
-	if !r.pulled {
-		return r.clone()
+// pull clones the repository with its backend, or pulls it if it was
+// cloned already.
+func (r *Repo) pull(ctx context.Context) error {
+	r.resolveRemotes(ctx)
+	if err := r.startThrottle(); err != nil {
+		return err
+	}
+	if err := r.makeControlDir(); err != nil {
+		return err
+	}
+	if r.DryRun {
+		return r.dryRun(ctx)
 	}
 
-	// if latest tag config is set
-	if r.Branch == latestTag {
-		if err := r.checkoutLatestTag(); err != nil {
-			log.Errorf("Error retrieving latest tag: %s", err)
+	b, dir := r.backend(), r.workDir()
+	if r.Follow {
+		commit, err := r.follow(ctx, b, dir)
+		if err != nil {
 			return err
 		}
+		r.setLastPull(time.Now())
+		r.lastCommit = commit
 		return nil
 	}
+	if r.Lease > 0 {
+		held, err := r.acquireLease(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot acquire lease on %v: %s", r, err)
+		}
+		if !held {
+			commit, err := r.follow(ctx, b, dir)
+			if err != nil {
+				return err
+			}
+			r.setLastPull(time.Now())
+			r.lastCommit = commit
+			return nil
+		}
+	}
+
+	if err := r.reconcileDrift(ctx, dir); err != nil {
+		return err
+	}
 
-	params := append([]string{"pull"}, append(r.PullArgs, "origin", r.Branch)...)
+	cloned := !r.pulled
+	qctx, quota := r.watchQuota(ctx, dir)
+	fetched := r.startPhase("backend")
 	var err error
-	if err = r.gitCmd(params, r.Path); err == nil {
-		r.pulled = true
-		r.lastPull = time.Now()
-		log.Infof("pulled: %v", r.URL)
-		r.lastCommit, err = r.mostRecentCommit()
+	switch {
+	case r.Bundles == "":
+		err = r.pullRemote(qctx, b, dir)
+	case r.BundleFirst:
+		if err = r.pullBundle(qctx); err != nil {
+			log.Warningf("%s, pulling from %v", redact(err.Error()), r)
+			err = r.pullRemote(qctx, b, dir)
+		}
+	default:
+		if err = r.pullRemote(qctx, b, dir); err != nil {
+			log.Warningf("%s, pulling from the bundle mirror", redact(err.Error()))
+			err = r.pullBundle(qctx)
+		}
 	}
-	return err
+	if qerr := quota(); qerr != nil {
+		err = qerr
+	}
+	fetched(err)
+	if err != nil {
+		r.abortMerge(ctx, dir)
+		// don't leave a clone over the quota behind
+		if cloned && r.MaxSize > 0 {
+			r.removeClone(dir)
+		}
+		return err
+	}
+	validated := r.startPhase("validate")
+	err = r.validateCheckout(ctx, b, dir, cloned)
+	validated(err)
+	if err != nil {
+		return err
+	}
+
+	commit, err := b.Head(ctx, r, dir)
+	if err != nil {
+		return err
+	}
+	r.setLastPull(time.Now())
+	r.lastCommit = commit
+	return r.pushLocal(ctx, dir)
 }
 
-// clone performs git clone.
-func (r *Repo) clone() error {
-	params := append([]string{"clone", "-b", r.Branch}, append(r.CloneArgs, r.URL, r.Path)...)
+// validateCheckout checks the checkout at dir after a pull, cloned if
+// cloned is true, and undoes the pull if the checkout is rejected.
+func (r *Repo) validateCheckout(ctx context.Context, b Backend, dir string, cloned bool) error {
+	if err := r.checkQuota(dir); err != nil {
+		// don't leave a clone over the quota behind
+		if cloned && r.MaxSize > 0 {
+			r.removeClone(dir)
+		}
+		return err
+	}
+	err := r.checkLinks(dir)
+	if err == nil {
+		err = r.applySymlinks(dir)
+	}
+	if err != nil {
+		// don't serve the rejected commit either
+		if r.StatusName != "" {
+			r.rejected, _ = b.Head(ctx, r, dir)
+		}
+		switch {
+		case r.lastCommit != "":
+			if rerr := b.Reset(ctx, r, dir, r.lastCommit); rerr != nil {
+				log.Warningf("cannot reset %v to %s: %s", r, r.lastCommit, redact(rerr.Error()))
+			}
+		case cloned:
+			r.removeClone(dir)
+		}
+		return err
+	}
+	if err := r.applyModes(dir); err != nil {
+		return fmt.Errorf("cannot set the permissions of %v: %s", dir, err)
+	}
+	return nil
+}
 
-	tagMode := r.Branch == latestTag
-	if tagMode {
-		params = append([]string{"clone"}, append(r.CloneArgs, r.URL, r.Path)...)
+// removeClone removes the clone of r at dir, so the next pull clones it
+// again.
+func (r *Repo) removeClone(dir string) {
+	r.pulled = false
+	if err := clearDir(dir); err != nil {
+		log.Warningf("cannot remove the clone of %v: %s", r, err)
 	}
+}
 
-	var err error
-	if err = r.gitCmd(params, ""); err == nil {
-		r.pulled = true
-		r.lastPull = time.Now()
-		log.Infof("pulled: %v", r.URL)
-		r.lastCommit, err = r.mostRecentCommit()
-
-		// if latest tag config is set.
-		if tagMode {
-			if err := r.checkoutLatestTag(); err != nil {
-				log.Errorf("Error retrieving latest tag: %s", err)
-			}
+// cloneOrPull clones or pulls the checkout at dir from the current remote
+// with b.
+func (r *Repo) cloneOrPull(ctx context.Context, b Backend, dir string) error {
+	cloned := false
+	// if not pulled, perform clone
+	if !r.pulled {
+		if err := b.Clone(ctx, r, dir); err != nil {
 			return err
 		}
+		r.pulled, cloned = true, true
+	}
+	// a fresh clone is up to date, unless the latest tag is to be checked out
+	if !cloned || r.Branch == latestTag {
+		return b.Pull(ctx, r, dir)
 	}
+	return nil
+}
 
+// pullRetry pulls r, attempting at most numRetries times, then publishes
+// the checkout. Errors which retrying does not fix, such as rejected
+// credentials or a missing branch, are not retried.
+func (r *Repo) pullRetry(ctx context.Context) error {
+	var err error
+	for i := 0; i < numRetries; i++ {
+		if err = redactError(r.pull(ctx)); err == nil {
+			break
+		}
+		// the last failure is logged with the outcome of the pull
+		if ctx.Err() != nil || persistent(errorClass(err)) || i == numRetries-1 {
+			break
+		}
+		if r.LogFormat != "json" {
+			log.Warning(err)
+		}
+	}
+	if err == nil && !r.DryRun {
+		err = r.publish()
+	}
+	if err == nil && !r.DryRun && !r.Follow {
+		err = r.chownCheckout()
+	}
+	if err == nil && !r.DryRun && !r.Follow {
+		err = r.labelCheckout()
+	}
 	return err
 }
 
+// lifetime returns the context of the pulls of the running repository,
+// done once it is stopped.
+func (r *Repo) lifetime() context.Context {
+	r.ctxOnce.Do(func() { r.ctx, r.cancel = context.WithCancel(context.Background()) })
+	return r.ctx
+}
+
+// stop cancels the pulls of the repository, killing their git processes,
+// and releases its lease on the checkout.
+func (r *Repo) stop() {
+	r.lifetime()
+	r.cancel()
+	if r.Lease > 0 {
+		r.Lock()
+		r.releaseLease()
+		r.Unlock()
+	}
+}
+
+// setLastPull records t as the time of the last successful pull.
+func (r *Repo) setLastPull(t time.Time) {
+	r.lastPull = t
+	r.pulledAt.Store(t.UnixNano())
+}
+
+// LastPull returns the time of the last successful pull, or the zero
+// time if the repository was never pulled. It does not block on a
+// running pull.
+func (r *Repo) LastPull() time.Time {
+	if n := r.pulledAt.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// Commit returns the hash of the checked out commit as of the last
+// successful pull. It does not block on a running pull.
+func (r *Repo) Commit() string {
+	c, _ := r.commit.Load().(string)
+	return c
+}
+
+// setNextPull records t as the time of the next scheduled pull.
+func (r *Repo) setNextPull(t time.Time) { r.nextPull.Store(t.UnixNano()) }
+
+// NextPull returns the time of the next scheduled pull, or the zero time
+// if periodic pulls are not running.
+func (r *Repo) NextPull() time.Time {
+	if n := r.nextPull.Load(); n != 0 {
+		return time.Unix(0, n)
+	}
+	return time.Time{}
+}
+
+// Healthy reports whether the repository was successfully pulled within
+// MaxAge and its circuit breaker is closed. It is always true if MaxAge is
+// not set.
+func (r *Repo) Healthy() bool {
+	if r.tripped.Load() {
+		return false
+	}
+	if r.MaxAge <= 0 {
+		return true
+	}
+	last := r.LastPull()
+	return !last.IsZero() && time.Since(last) <= r.MaxAge
+}
+
+// Pause pauses periodic pulls. Pulls requested otherwise still happen.
+func (r *Repo) Pause() { r.paused.Store(true) }
+
+// Resume resumes periodic pulls.
+func (r *Repo) Resume() { r.paused.Store(false) }
+
+// Paused reports whether periodic pulls are paused.
+func (r *Repo) Paused() bool { return r.paused.Load() }
+
+// Rollback resets the checkout to the commit checked out before the last
+// change. It pauses periodic pulls so the next one doesn't undo it.
+func (r *Repo) Rollback() error {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.Follow {
+		return fmt.Errorf("cannot roll %v back, it is followed", r)
+	}
+	if r.prevCommit == "" {
+		return fmt.Errorf("no previous commit to roll back to for %v", r)
+	}
+	if r.Lease > 0 {
+		held, err := r.acquireLease(context.Background())
+		if err != nil {
+			return fmt.Errorf("cannot acquire lease on %v: %s", r, err)
+		}
+		if !held {
+			return fmt.Errorf("cannot roll %v back, another replica holds its lease", r)
+		}
+	}
+	unlock, err := r.lockCheckout(context.Background())
+	if err != nil {
+		return fmt.Errorf("cannot lock the checkout of %v: %s", r, err)
+	}
+	defer unlock()
+	if err := r.backend().Reset(r.commandContext(context.Background()), r, r.workDir(), r.prevCommit); err != nil {
+		return redactError(err)
+	}
+	if err := r.applySymlinks(r.workDir()); err != nil {
+		return err
+	}
+	log.Infof("rolled back %v from %v to %v", r, r.lastCommit, r.prevCommit)
+
+	r.Pause()
+	r.lastCommit, r.prevCommit = r.prevCommit, r.lastCommit
+	if err := r.publish(); err != nil {
+		return err
+	}
+	if err := r.chownCheckout(); err != nil {
+		return err
+	}
+	if err := r.labelCheckout(); err != nil {
+		return err
+	}
+	r.commit.Store(r.lastCommit)
+	updateRepoInfo(r)
+	return nil
+}
+
 // checkoutLatestTag checks out the latest tag of the repository.
-func (r *Repo) checkoutLatestTag() error {
-	tag, err := r.fetchLatestTag()
+func (r *Repo) checkoutLatestTag(ctx context.Context) error {
+	tag, err := r.fetchLatestTag(ctx)
 	if err != nil {
 		return err
 	}
 	if tag == "" {
-		return fmt.Errorf("no tags found for repo: %v", r.URL)
+		return fmt.Errorf("no tags found for repo: %v", r)
 	} else if tag == r.latestTag {
 		return nil
 	}
 
 	params := []string{"checkout", "tags/" + tag}
-	if err = r.gitCmd(params, r.Path); err == nil {
-		r.latestTag = tag
-		r.lastCommit, err = r.mostRecentCommit()
-	} else {
+	if err = r.gitCmd(ctx, params, r.workDir()); err != nil {
 		return err
 	}
+	r.latestTag = tag
 	return nil
 }
 
 // checkoutCommit checks out the specified commitHash.
-func (r *Repo) checkoutCommit(commitHash string) error {
+func (r *Repo) checkoutCommit(ctx context.Context, commitHash string) error {
 	var err error
 	params := []string{"checkout", commitHash}
-	if err = r.gitCmd(params, r.Path); err == nil {
+	if err = r.gitCmd(ctx, params, r.workDir()); err == nil {
 		log.Infof("commit %v checkout done", commitHash)
 	}
 	return err
 }
 
-// gitCmd performs a git command.
-func (r *Repo) gitCmd(params []string, dir string) error { return runCmd("git", params, dir) }
+// gitCmd performs a git command, traced as a child of the running pull.
+func (r *Repo) gitCmd(ctx context.Context, params []string, dir string) error {
+	span := r.startSpan(params[0])
+	output, err := runCmdCombined(ctx, "git", r.gitParams(params), r.gitEnv(), dir)
+	r.countFetched(fetchedBytes(output))
+	finishSpan(span, err)
+	return err
+}
+
+// gitOutput performs a git command and returns its output.
+func (r *Repo) gitOutput(ctx context.Context, params []string, dir string) (string, error) {
+	return runCmdOutputEnv(ctx, "git", r.gitParams(params), r.gitEnv(), dir)
+}
 
 // Prepare prepares for a git pull
 // and validates the configured directory
 func (r *Repo) Prepare() error {
+	// the checkout is elsewhere, only its subpath or base is published at path
+	if r.apart() {
+		if err := os.MkdirAll(r.Path, os.FileMode(0755)); err != nil {
+			return err
+		}
+	}
+
 	// check if directory exists or is empty
 	// if not, create directory
-	fs, err := ioutil.ReadDir(r.Path)
+	dir := r.workDir()
+	if r.InMemory {
+		// the files exported from memory are replaced by the first pull
+		return os.MkdirAll(dir, os.FileMode(0755))
+	}
+	fs, err := ioutil.ReadDir(dir)
 	if err != nil || len(fs) == 0 {
-		return os.MkdirAll(r.Path, os.FileMode(0755))
+		if err := os.MkdirAll(dir, os.FileMode(0755)); err != nil {
+			return err
+		}
+		// git clones into it as RunAs
+		return r.RunAs.chown(dir)
 	}
 
 	// validate git repo
+	meta := ".git"
+	switch r.Backend {
+	case backendHg:
+		meta = ".hg"
+	case backendArchive, backendRaw:
+		meta = archiveMeta
+	}
 	isGit := false
 	for _, f := range fs {
-		if f.IsDir() && f.Name() == ".git" {
+		if f.IsDir() && f.Name() == meta {
 			isGit = true
 			break
 		}
@@ -195,53 +678,97 @@ func (r *Repo) Prepare() error {
 		// check if same repository
 		var repoURL string
 		if repoURL, err = r.originURL(); err == nil {
-			if strings.TrimSuffix(repoURL, ".git") == strings.TrimSuffix(r.URL, ".git") {
+			stored, _ := splitPassword(repoURL)
+			if strings.TrimSuffix(stored, ".git") == strings.TrimSuffix(r.URL, ".git") {
 				r.pulled = true
+				if r.Backend == "" {
+					ctx := r.commandContext(context.Background())
+					// remove the password stored by a clone of an earlier version
+					if stored != repoURL {
+						if err := r.gitCmd(ctx, []string{"remote", "set-url", "origin", r.URL}, dir); err != nil {
+							return err
+						}
+					}
+					// the subpath may have changed since the clone
+					return r.sparseCheckout(ctx)
+				}
 				return nil
 			}
 		}
 		if err != nil {
-			return fmt.Errorf("cannot retrieve repo url for %v: %s", r.Path, err)
+			return fmt.Errorf("cannot retrieve repo url for %v: %s", dir, err)
 		}
-		return fmt.Errorf("another git repo '%v' exists at %v", repoURL, r.Path)
+		return fmt.Errorf("another git repo '%v' exists at %v", redact(repoURL), dir)
+	}
+	return fmt.Errorf("cannot git clone into %v, directory not empty", dir)
+}
+
+// adopt takes over the checkout of prev, a running repository configured
+// with the same checkout, along with its state, so a reload changing
+// other settings does not start over.
+func (r *Repo) adopt(prev *Repo) {
+	prev.Lock()
+	defer prev.Unlock()
+
+	r.pulled = prev.pulled
+	r.setLastPull(prev.lastPull)
+	r.lastCommit, r.prevCommit = prev.lastCommit, prev.prevCommit
+	// republish if what is published changed
+	if samePublish(r, prev) {
+		r.published = prev.published
+	}
+	r.latestTag = prev.latestTag
+	r.unpushed = prev.unpushed
+	r.mem = prev.mem
+	r.resolved = prev.resolved
+	r.commit.Store(prev.lastCommit)
+	r.paused.Store(prev.Paused())
+	for _, e := range prev.History() {
+		r.history.add(e, r.HistorySize)
+	}
+}
+
+// workDir returns the directory of the git checkout. It is Path, unless
+// only Subpath, or the base of Overlay, is published at Path: the checkout
+// is then kept in a hidden sibling directory of Path.
+func (r *Repo) workDir() string {
+	if !r.apart() {
+		return r.Path
 	}
-	return fmt.Errorf("cannot git clone into %v, directory not empty", r.Path)
+	return filepath.Join(filepath.Dir(r.Path), "."+filepath.Base(r.Path)+".git")
+}
+
+// sparseCheckout restricts the checkout to Subpath, if set.
+func (r *Repo) sparseCheckout(ctx context.Context) error {
+	if r.Subpath == "" {
+		return nil
+	}
+	return r.gitCmd(ctx, []string{"sparse-checkout", "set", filepath.ToSlash(r.Subpath)}, r.workDir())
 }
 
 // getMostRecentCommit gets the hash of the most recent commit to the
 // repository. Useful for checking if changes occur.
-func (r *Repo) mostRecentCommit() (string, error) {
-	command := "git" + ` --no-pager log -n 1 --pretty=format:"%H"`
-	c, args, err := caddy.SplitCommandAndArgs(command)
-	if err != nil {
-		return "", err
-	}
-	return runCmdOutput(c, args, r.Path)
+func (r *Repo) mostRecentCommit(ctx context.Context) (string, error) {
+	return r.gitOutput(ctx, []string{"--no-pager", "log", "-n", "1", "--pretty=format:%H"}, r.workDir())
 }
 
 // fetchLatestTag retrieves the most recent tag in the repository.
-func (r *Repo) fetchLatestTag() (string, error) {
+func (r *Repo) fetchLatestTag(ctx context.Context) (string, error) {
 	// fetch updates to get latest tag
-	params := []string{"fetch", "origin", "--tags"}
-	err := r.gitCmd(params, r.Path)
+	params := []string{"fetch", "--progress", "origin", "--tags"}
+	err := r.gitCmd(ctx, params, r.workDir())
 	if err != nil {
 		return "", err
 	}
 	// retrieve latest tag
-	command := "git" + ` describe origin --abbrev=0 --tags`
-	c, args, err := caddy.SplitCommandAndArgs(command)
-	if err != nil {
-		return "", err
-	}
-	return runCmdOutput(c, args, r.Path)
+	return r.gitOutput(ctx, []string{"describe", "origin", "--abbrev=0", "--tags"}, r.workDir())
 }
 
 // originURL retrieves remote origin url for the git repository at path
 func (r *Repo) originURL() (string, error) {
-	_, err := os.Stat(r.Path)
+	_, err := os.Stat(r.workDir())
 	if err != nil {
 		return "", err
 	}
-	args := []string{"config", "--get", "remote.origin.url"}
-	return runCmdOutput("git", args, r.Path)
+	return r.backend().Origin(r.commandContext(context.Background()), r, r.workDir())
 }
@@ -0,0 +1,183 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// backendGoGit is the backend pulling with go-git, in process, instead of
+// running the git command.
+const backendGoGit = "go-git"
+
+// goGitBackend pulls repositories with go-git.
+type goGitBackend struct{}
+
+// Clone implements Backend. In tag mode, the default branch is cloned and
+// the latest tag is checked out by Pull. With InMemory, the repository is
+// cloned in memory and its files exported to dir.
+func (goGitBackend) Clone(ctx context.Context, r *Repo, dir string) error {
+	opts := &gogit.CloneOptions{URL: r.URL, Auth: r.goGitAuth()}
+	if r.Branch != latestTag {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(r.Branch)
+		opts.SingleBranch = true
+	}
+	if r.InMemory {
+		if err := goGitCloneMemory(ctx, r, opts); err != nil {
+			return fmt.Errorf("cannot clone %v: %s", r, err)
+		}
+		return r.export(dir)
+	}
+	if _, err := gogit.PlainCloneContext(ctx, dir, false, opts); err != nil {
+		return fmt.Errorf("cannot clone %v: %s", r, err)
+	}
+	return nil
+}
+
+// Pull implements Backend. Only fast-forwards are supported.
+func (b goGitBackend) Pull(ctx context.Context, r *Repo, dir string) error {
+	if err := b.pull(ctx, r, dir); err != nil || !r.InMemory {
+		return err
+	}
+	return r.export(dir)
+}
+
+// pull pulls the repository of r checked out at dir.
+func (goGitBackend) pull(ctx context.Context, r *Repo, dir string) error {
+	repo, err := goGitOpen(r, dir)
+	if err != nil {
+		return err
+	}
+	if r.Branch == latestTag {
+		if err := goGitCheckoutLatestTag(ctx, r, repo); err != nil {
+			log.Errorf("Error retrieving latest tag: %s", redact(err.Error()))
+			return err
+		}
+		return nil
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	err = w.PullContext(ctx, &gogit.PullOptions{
+		RemoteName:    "origin",
+		ReferenceName: plumbing.NewBranchReferenceName(r.Branch),
+		SingleBranch:  true,
+		Auth:          r.goGitAuth(),
+	})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("cannot pull %v: %s", r, err)
+	}
+	return nil
+}
+
+// Head implements Backend.
+func (goGitBackend) Head(ctx context.Context, r *Repo, dir string) (string, error) {
+	repo, err := goGitOpen(r, dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+// Reset implements Backend.
+func (goGitBackend) Reset(ctx context.Context, r *Repo, dir, commit string) error {
+	repo, err := goGitOpen(r, dir)
+	if err != nil {
+		return err
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	err = w.Reset(&gogit.ResetOptions{Commit: plumbing.NewHash(commit), Mode: gogit.HardReset})
+	if err != nil || !r.InMemory {
+		return err
+	}
+	return r.export(dir)
+}
+
+// Origin implements Backend.
+func (goGitBackend) Origin(ctx context.Context, r *Repo, dir string) (string, error) {
+	repo, err := goGitOpen(r, dir)
+	if err != nil {
+		return "", err
+	}
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return "", err
+	}
+	if urls := remote.Config().URLs; len(urls) > 0 {
+		return urls[0], nil
+	}
+	return "", fmt.Errorf("no URL for remote origin")
+}
+
+// goGitCheckoutLatestTag checks out the most recent tag of repo, the one
+// of the most recently committed commit.
+func goGitCheckoutLatestTag(ctx context.Context, r *Repo, repo *gogit.Repository) error {
+	err := repo.FetchContext(ctx, &gogit.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{"+refs/tags/*:refs/tags/*"},
+		Tags:       gogit.AllTags,
+		Auth:       r.goGitAuth(),
+	})
+	if err != nil && !errors.Is(err, gogit.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("cannot fetch tags of %v: %s", r, err)
+	}
+
+	tags, err := repo.Tags()
+	if err != nil {
+		return err
+	}
+	var (
+		tag    string
+		latest *object.Commit
+	)
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		commit, err := goGitTagCommit(repo, ref.Hash())
+		if err != nil {
+			return err
+		}
+		if latest == nil || commit.Committer.When.After(latest.Committer.When) {
+			tag, latest = ref.Name().Short(), commit
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if latest == nil {
+		return fmt.Errorf("no tags found for repo: %v", r)
+	} else if tag == r.latestTag {
+		return nil
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := w.Checkout(&gogit.CheckoutOptions{Hash: latest.Hash, Force: true}); err != nil {
+		return fmt.Errorf("cannot check out tag %v of %v: %s", tag, r, err)
+	}
+	r.latestTag = tag
+	return nil
+}
+
+// goGitTagCommit returns the commit a lightweight or annotated tag at hash points to.
+func goGitTagCommit(repo *gogit.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	if tag, err := repo.TagObject(hash); err == nil {
+		return tag.Commit()
+	}
+	return repo.CommitObject(hash)
+}
@@ -0,0 +1,74 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitFile writes content to name in the worktree of repo and commits it.
+func commitFile(t *testing.T, repo *gogit.Repository, dir, name, content string) plumbing.Hash {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := w.Commit("update "+name, &gogit.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.org", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func TestGoGitPull(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "zones")
+	origin, err := gogit.PlainInitWithOptions(src, &gogit.PlainInitOptions{
+		InitOptions: gogit.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("master")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitFile(t, origin, src, "db.example.org", "v1")
+
+	repo := &Repo{URL: src, Path: filepath.Join(t.TempDir(), "zones"), Branch: "master", Backend: backendGoGit}
+	if err := repo.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected clone to succeed, found %v", err)
+	}
+	if repo.lastCommit != first.String() {
+		t.Errorf("Expected commit %v, found %v", first, repo.lastCommit)
+	}
+
+	second := commitFile(t, origin, src, "db.example.org", "v2")
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected pull to succeed, found %v", err)
+	}
+	if repo.lastCommit != second.String() {
+		t.Errorf("Expected commit %v, found %v", second, repo.lastCommit)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v2" {
+		t.Errorf("Expected pulled file content v2, found %q", b)
+	}
+
+	// an existing checkout is recognized
+	reloaded := &Repo{URL: src, Path: repo.Path, Branch: "master", Backend: backendGoGit}
+	if err := reloaded.Prepare(); err != nil || !reloaded.pulled {
+		t.Errorf("Expected existing checkout to be reused, found %v", err)
+	}
+}
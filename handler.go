@@ -0,0 +1,68 @@
+package git
+
+import (
+	"context"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Handler is the git plugin handler. It passes every query to the next
+// plugin and exposes the configured repositories to other plugins.
+type Handler struct {
+	Next  plugin.Handler
+	Repos Git
+
+	// Config is the configuration of the server block, holding the
+	// secrets of the TSIG keys of the tsig plugin.
+	Config *dnsserver.Config
+}
+
+// ServeDNS implements the plugin.Handler interface.
+func (h Handler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+
+	if state.QClass() == dns.ClassCHAOS && state.QType() == dns.TypeTXT && strings.EqualFold(state.Name(), chaosName) {
+		if rrs := h.chaosTXT(r.Question[0]); len(rrs) > 0 {
+			m := new(dns.Msg)
+			m.SetReply(r)
+			m.Authoritative = true
+			m.Answer = rrs
+			w.WriteMsg(m)
+			return dns.RcodeSuccess, nil
+		}
+	}
+
+	if (r.Opcode == dns.OpcodeQuery || r.Opcode == dns.OpcodeUpdate) && len(r.Question) == 1 && state.QClass() == dns.ClassINET {
+		if zone := h.controlZone(state.Name()); zone != "" {
+			return h.serveControlZone(w, r, zone)
+		}
+	}
+
+	if state.QClass() == dns.ClassINET {
+		if zone := h.statusZone(state.Name()); zone != "" {
+			return h.serveStatus(w, r, zone)
+		}
+	}
+
+	if r.Opcode == dns.OpcodeUpdate && len(r.Question) == 1 {
+		if repo, u := h.updateRepo(state.Name()); repo != nil {
+			return h.serveUpdate(ctx, w, r, repo, u)
+		}
+	}
+
+	if r.Opcode == dns.OpcodeNotify && len(r.Question) == 1 {
+		if repos := h.notifyRepos(state.Name()); len(repos) > 0 {
+			return h.serveNotify(w, r, repos)
+		}
+	}
+
+	return plugin.NextOrFailure(h.Name(), h.Next, ctx, w, r)
+}
+
+// Name implements the plugin.Handler interface.
+func (h Handler) Name() string { return "git" }
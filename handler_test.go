@@ -0,0 +1,83 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestChaos(t *testing.T) {
+	repo := &Repo{URL: "https://github.com/user/repo", Chaos: true}
+	repo.commit.Store("0123456789abcdef")
+	repo.setLastPull(time.Now())
+	h := Handler{Repos: Git{repo, &Repo{URL: "https://github.com/user/other"}}, Next: test.ErrorHandler()}
+
+	m := new(dns.Msg)
+	m.SetQuestion(chaosName, dns.TypeTXT)
+	m.Question[0].Qclass = dns.ClassCHAOS
+
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := h.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if len(rec.Msg.Answer) != 1 {
+		t.Fatalf("Expected 1 answer, found %v", len(rec.Msg.Answer))
+	}
+	txt := rec.Msg.Answer[0].(*dns.TXT).Txt[0]
+	if !strings.HasPrefix(txt, repo.URL+" 0123456789abcdef ") {
+		t.Errorf("Unexpected TXT record %q", txt)
+	}
+
+	m.Question[0].Qclass = dns.ClassINET
+	rec = dnstest.NewRecorder(&test.ResponseWriter{})
+	h.ServeDNS(context.TODO(), rec, m)
+	if rec.Rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected INET query to be passed to the next plugin")
+	}
+}
+
+func TestStatusZone(t *testing.T) {
+	repo := &Repo{URL: "https://github.com/user/repo", Branch: "master", StatusZone: "_git.status.example.org."}
+	repo.commit.Store("0123456789abcdef")
+	h := Handler{Repos: Git{repo}, Next: test.ErrorHandler()}
+
+	tests := []struct {
+		qname   string
+		qtype   uint16
+		rcode   int
+		answers int
+		soa     bool // SOA record in the authority section
+	}{
+		{"_git.status.example.org.", dns.TypeTXT, dns.RcodeSuccess, 1, false},
+		{"_GIT.status.example.org.", dns.TypeTXT, dns.RcodeSuccess, 1, false},
+		{"_git.status.example.org.", dns.TypeSOA, dns.RcodeSuccess, 1, false},
+		{"_git.status.example.org.", dns.TypeA, dns.RcodeSuccess, 0, true},
+		{"a._git.status.example.org.", dns.TypeTXT, dns.RcodeNameError, 0, true},
+		{"example.org.", dns.TypeTXT, dns.RcodeServerFailure, 0, false},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		h.ServeDNS(context.TODO(), rec, m)
+		if rec.Rcode != tc.rcode {
+			t.Errorf("Test %v expects rcode %v but found %v", i, tc.rcode, rec.Rcode)
+		}
+		if rec.Msg == nil {
+			continue
+		}
+		if len(rec.Msg.Answer) != tc.answers {
+			t.Errorf("Test %v expects %v answers but found %v", i, tc.answers, len(rec.Msg.Answer))
+		}
+		if soa := len(rec.Msg.Ns) == 1 && rec.Msg.Ns[0].Header().Rrtype == dns.TypeSOA && rec.Msg.Ns[0].Header().Name == "_git.status.example.org."; soa != tc.soa {
+			t.Errorf("Test %v expects a SOA record in the authority section %v, found %v", i, tc.soa, rec.Msg.Ns)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package git
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Values of http.followRedirects of git: redirects are always followed,
+// never, or only for the first request to a remote.
+const (
+	redirectsTrue    = "true"
+	redirectsFalse   = "false"
+	redirectsInitial = "initial"
+)
+
+// httpConfig returns the configuration of git sending UserAgent as its
+// User-Agent with its requests to HTTP(S) remotes, and following their
+// redirects as set by Redirects.
+func (r *Repo) httpConfig() []string {
+	var config []string
+	if r.Redirects != "" {
+		config = append(config, "-c", "http.followRedirects="+r.Redirects)
+	}
+	if r.UserAgent != "" {
+		config = append(config, "-c", "http.userAgent="+r.UserAgent)
+	}
+	return config
+}
+
+// headerConfig returns the configuration of git sending Headers with its
+// requests to HTTP(S) remotes. Their values may be tokens, passed to git
+// through its environment, see gitEnv.
+func (r *Repo) headerConfig() [][2]string {
+	var config [][2]string
+	for _, h := range r.Headers {
+		config = append(config, [2]string{"http.extraHeader", h})
+	}
+	return config
+}
+
+// setHeaders adds Headers, and UserAgent as its User-Agent, to req.
+func (r *Repo) setHeaders(req *http.Request) {
+	for _, h := range r.Headers {
+		name, value, _ := strings.Cut(h, ":")
+		req.Header.Add(name, strings.TrimSpace(value))
+	}
+	if r.UserAgent != "" {
+		req.Header.Set("User-Agent", r.UserAgent)
+	}
+}
+
+// httpClient returns c, not following redirects if Redirects forbids
+// them. A single request being made to each URL, they are otherwise
+// followed.
+func (r *Repo) httpClient(c *http.Client) *http.Client {
+	if r.Redirects != redirectsFalse {
+		return c
+	}
+	noRedirect := *c
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return &noRedirect
+}
+
+// validHeader reports whether name and value make an HTTP header git can
+// send: a token as name, and a value without line breaks.
+func validHeader(name, value string) bool {
+	if name == "" || strings.ContainsAny(value, "\r\n") {
+		return false
+	}
+	for _, c := range name {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestHTTPConfig(t *testing.T) {
+	repo := &Repo{URL: "https://github.com/user/zones", Headers: []string{"X-Proxy-Token: abc", "X-Env: prod"}, UserAgent: "coredns/1.0", Redirects: redirectsFalse}
+	expected := []string{
+		"-c", "http.followRedirects=false",
+		"-c", "http.userAgent=coredns/1.0",
+		"pull",
+	}
+	if params := repo.gitParams([]string{"pull"}); !reflect.DeepEqual(params, expected) {
+		t.Errorf("Expected parameters %q, found %q", expected, params)
+	}
+	env := []string{
+		"GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=X-Proxy-Token: abc",
+		"GIT_CONFIG_KEY_1=http.extraHeader", "GIT_CONFIG_VALUE_1=X-Env: prod",
+		"GIT_CONFIG_COUNT=2",
+	}
+	if found := repo.gitEnv(); !reflect.DeepEqual(found, env) {
+		t.Errorf("Expected environment %q, found %q", env, found)
+	}
+	if params := (&Repo{URL: repo.URL}).gitParams([]string{"pull"}); len(params) != 1 {
+		t.Errorf("Expected no configuration without headers, found %q", params)
+	}
+	if env := (&Repo{URL: repo.URL}).gitEnv(); env != nil {
+		t.Errorf("Expected no environment without headers, found %q", env)
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		header = req.Header
+	}))
+	defer srv.Close()
+
+	repo := &Repo{URL: srv.URL + "/zones.git", Headers: []string{"X-Proxy-Token: abc", "X-Proxy-Token: def"}, UserAgent: "coredns/1.0"}
+	u, _ := url.Parse(srv.URL + "/zones.tar.gz")
+	resp, err := httpGet(context.Background(), repo, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if tokens := header.Values("X-Proxy-Token"); !reflect.DeepEqual(tokens, []string{"abc", "def"}) {
+		t.Errorf("Expected both proxy tokens, found %q", tokens)
+	}
+	if agent := header.Get("User-Agent"); agent != "coredns/1.0" {
+		t.Errorf("Expected User-Agent coredns/1.0, found %q", agent)
+	}
+}
+
+func TestHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/moved.tar.gz" {
+			http.Redirect(w, req, "/zones.tar.gz", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL + "/moved.tar.gz")
+	for _, redirects := range []string{"", redirectsTrue, redirectsInitial} {
+		resp, err := httpGet(context.Background(), &Repo{URL: srv.URL, Redirects: redirects}, u)
+		if err != nil {
+			t.Errorf("Expected redirect to be followed with %q, found %v", redirects, err)
+			continue
+		}
+		resp.Body.Close()
+	}
+	if _, err := httpGet(context.Background(), &Repo{URL: srv.URL, Redirects: redirectsFalse}, u); err == nil {
+		t.Error("Expected redirect not to be followed")
+	}
+}
@@ -0,0 +1,84 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// backendHg is the backend pulling Mercurial repositories with the hg command.
+const backendHg = "hg"
+
+// hgBackend pulls Mercurial repositories with the hg command. Branch is a
+// Mercurial branch, tag or bookmark, and {latest} its most recent tag.
+type hgBackend struct{}
+
+// Clone implements Backend.
+func (hgBackend) Clone(ctx context.Context, r *Repo, dir string) error {
+	params := []string{"clone", "-u", r.Branch, r.URL, dir}
+	if r.Branch == latestTag {
+		params = []string{"clone", r.URL, dir}
+	}
+	return hgCmd(ctx, r, params, "")
+}
+
+// Pull implements Backend, as hg pull -u.
+func (hgBackend) Pull(ctx context.Context, r *Repo, dir string) error {
+	if r.Branch != latestTag {
+		return hgCmd(ctx, r, []string{"pull", "-u"}, dir)
+	}
+
+	if err := hgCmd(ctx, r, []string{"pull"}, dir); err != nil {
+		return err
+	}
+	// tags are listed from the most recent, tip first
+	out, err := runCmdOutput(ctx, "hg", []string{"tags", "-q"}, dir)
+	if err != nil {
+		return err
+	}
+	var tag string
+	for _, t := range strings.Fields(out) {
+		if t != "tip" {
+			tag = t
+			break
+		}
+	}
+	if tag == "" {
+		return fmt.Errorf("no tags found for repo: %v", r)
+	} else if tag == r.latestTag {
+		return nil
+	}
+	if err := hgCmd(ctx, r, []string{"update", "-C", tag}, dir); err != nil {
+		return err
+	}
+	r.latestTag = tag
+	return nil
+}
+
+// Head implements Backend.
+func (hgBackend) Head(ctx context.Context, r *Repo, dir string) (string, error) {
+	return runCmdOutput(ctx, "hg", []string{"log", "-r", ".", "--template", "{node}"}, dir)
+}
+
+// Reset implements Backend.
+func (hgBackend) Reset(ctx context.Context, r *Repo, dir, commit string) error {
+	return hgCmd(ctx, r, []string{"update", "-C", "-r", commit}, dir)
+}
+
+// Origin implements Backend.
+func (hgBackend) Origin(ctx context.Context, r *Repo, dir string) (string, error) {
+	return runCmdOutput(ctx, "hg", []string{"paths", "default"}, dir)
+}
+
+// hgCmd performs a hg command, traced as a child of the running pull.
+func hgCmd(ctx context.Context, r *Repo, params []string, dir string) error {
+	env, remove, err := r.hgCredentialConfig()
+	if err != nil {
+		return err
+	}
+	defer remove()
+	span := r.startSpan(params[0])
+	_, err = runCmdCombined(ctx, "hg", params, env, dir)
+	finishSpan(span, err)
+	return err
+}
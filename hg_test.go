@@ -0,0 +1,62 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/caddy"
+)
+
+// hgCommit writes content to name in the Mercurial repository at dir and commits it.
+func hgCommit(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runCmd(context.Background(), "hg", []string{"commit", "-A", "-u", "test", "-m", "update " + name}, dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHgPull(t *testing.T) {
+	if _, err := exec.LookPath("hg"); err != nil {
+		t.Skip("hg is not installed")
+	}
+
+	src := filepath.Join(t.TempDir(), "zones")
+	if err := runCmd(context.Background(), "hg", []string{"init", src}, ""); err != nil {
+		t.Fatal(err)
+	}
+	hgCommit(t, src, "db.example.org", "v1")
+
+	c := caddy.NewTestController("dns", `git `+src+` `+filepath.Join(t.TempDir(), "zones")+` {
+		backend hg
+	}`)
+	git, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	repo := git.Repo(0)
+	if repo.Branch != "default" {
+		t.Errorf("Expected branch default, found %v", repo.Branch)
+	}
+
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected clone to succeed, found %v", err)
+	}
+	first := repo.lastCommit
+
+	hgCommit(t, src, "db.example.org", "v2")
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected pull to succeed, found %v", err)
+	}
+	if repo.lastCommit == first {
+		t.Errorf("Expected a new commit, found %v", repo.lastCommit)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v2" {
+		t.Errorf("Expected pulled file content v2, found %q", b)
+	}
+}
@@ -0,0 +1,47 @@
+package git
+
+import "sync"
+
+// defaultHistorySize is the default number of pull events kept per repository.
+const defaultHistorySize = 10
+
+// history is a fixed size ring buffer of pull events.
+type history struct {
+	events []PullEvent
+	next   int  // index of the next event to write
+	full   bool // true if the buffer wrapped around
+	sync.RWMutex
+}
+
+// add records e, evicting the oldest event if the buffer is full.
+func (h *history) add(e PullEvent, size int) {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.events == nil {
+		if size <= 0 {
+			size = defaultHistorySize
+		}
+		h.events = make([]PullEvent, size)
+	}
+	h.events[h.next] = e
+	h.next = (h.next + 1) % len(h.events)
+	h.full = h.full || h.next == 0
+}
+
+// list returns the recorded events, oldest first. The caller must hold the lock.
+func (h *history) list() []PullEvent {
+	if !h.full {
+		return append([]PullEvent(nil), h.events[:h.next]...)
+	}
+	return append(append([]PullEvent(nil), h.events[h.next:]...), h.events[:h.next]...)
+}
+
+// History returns the most recent pull events of the repository, oldest first.
+// It does not block on a running pull.
+func (r *Repo) History() []PullEvent {
+	r.history.RLock()
+	defer r.history.RUnlock()
+
+	return r.history.list()
+}
@@ -0,0 +1,30 @@
+package git
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	repo := &Repo{HistorySize: 3}
+	if len(repo.History()) != 0 {
+		t.Errorf("Expected empty history, found %v", repo.History())
+	}
+
+	for i := 0; i < 5; i++ {
+		repo.history.add(PullEvent{NewCommit: fmt.Sprint(i)}, repo.HistorySize)
+		if i == 1 && len(repo.History()) != 2 {
+			t.Errorf("Expected 2 events, found %v", len(repo.History()))
+		}
+	}
+
+	events := repo.History()
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, found %v", len(events))
+	}
+	for i, e := range events {
+		if e.NewCommit != fmt.Sprint(i+2) {
+			t.Errorf("Expected event %v to be commit %v, found %v", i, i+2, e.NewCommit)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// maxIncludeDepth is how deep $INCLUDE directives can be nested.
+const maxIncludeDepth = 8
+
+// flattenFile atomically replaces dst with a copy of the zone file at src
+// with permissions perm, its $INCLUDE directives replaced with the files
+// they include, which must be inside root.
+func flattenFile(src, dst, root string, perm os.FileMode) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(bytes.ToUpper(b), []byte("$INCLUDE")) {
+		var buf bytes.Buffer
+		if _, err := flatten(&buf, b, evalPath(src), evalPath(root), "", nil); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	}
+	return replaceFile(bytes.NewReader(b), dst, perm)
+}
+
+// flatten writes the zone file b read from file to buf, replacing its
+// $INCLUDE directives, and returns the last $ORIGIN written. origin is the
+// one written before, and stack the files being included.
+func flatten(buf *bytes.Buffer, b []byte, file, root, origin string, stack []string) (string, error) {
+	if len(stack) >= maxIncludeDepth {
+		return "", fmt.Errorf("$INCLUDE of %v nested more than %d deep", file, maxIncludeDepth)
+	}
+	stack = append(stack, file)
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	s.Buffer(nil, len(b)+1)
+	for s.Scan() {
+		line := s.Text()
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && strings.EqualFold(fields[0], "$ORIGIN") {
+			origin = absOrigin(fields[1], origin)
+		}
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "$INCLUDE") {
+			buf.WriteString(line + "\n")
+			continue
+		}
+		if len(fields) < 2 || strings.HasPrefix(fields[1], ";") {
+			return "", fmt.Errorf("$INCLUDE without a file in %v", file)
+		}
+
+		// relative to the including file, like the file plugin does
+		name := fields[1]
+		if filepath.IsAbs(name) {
+			return "", fmt.Errorf("$INCLUDE of absolute path %v in %v", name, file)
+		}
+		path := evalPath(filepath.Join(filepath.Dir(file), filepath.FromSlash(name)))
+		if !inside(path, root) {
+			return "", fmt.Errorf("$INCLUDE of %v in %v is outside of the repository", name, file)
+		}
+		for _, f := range stack {
+			if f == path {
+				return "", fmt.Errorf("$INCLUDE cycle through %v in %v", name, file)
+			}
+		}
+		included, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+
+		// the origin set for or by the included file is restored after it
+		inner := origin
+		if len(fields) >= 3 && !strings.HasPrefix(fields[2], ";") {
+			inner = absOrigin(fields[2], origin)
+			buf.WriteString("$ORIGIN " + inner + "\n")
+		}
+		if inner, err = flatten(buf, included, path, root, inner, stack); err != nil {
+			return "", err
+		}
+		if inner != origin {
+			if origin == "" {
+				return "", fmt.Errorf("$INCLUDE of %v in %v changes the origin, which needs an $ORIGIN before it", name, file)
+			}
+			buf.WriteString("$ORIGIN " + origin + "\n")
+		}
+	}
+	return origin, s.Err()
+}
+
+// absOrigin returns the origin name, relative to origin unless it is fully
+// qualified.
+func absOrigin(name, origin string) string {
+	if dns.IsFqdn(name) || origin == "" {
+		return name
+	}
+	return name + "." + origin
+}
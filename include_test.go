@@ -0,0 +1,50 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFlattenFile(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"zones/db.example.org": "$ORIGIN example.org.\n@ IN SOA ns1 hostmaster 1 2 3 4 5\n$INCLUDE ../shared/ns.inc\n$include hosts.inc office ; comment\nwww IN A 192.0.2.1\n",
+		"zones/hosts.inc":      "printer IN A 192.0.2.10\n",
+		"shared/ns.inc":        "@ IN NS ns1\n@ IN NS ns2\n",
+		"zones/db.example.net": "no includes\n",
+		"zones/db.cycle":       "$ORIGIN example.com.\n$INCLUDE cycle.inc\n",
+		"zones/cycle.inc":      "$INCLUDE db.cycle\n",
+		"zones/db.escape":      "$INCLUDE ../../etc/passwd\n",
+		"zones/db.origin":      "$INCLUDE hosts.inc sub.example.org.\n",
+	})
+	dst := t.TempDir()
+
+	tests := []struct {
+		file, expected, err string
+	}{
+		{"db.example.org", "$ORIGIN example.org.\n@ IN SOA ns1 hostmaster 1 2 3 4 5\n@ IN NS ns1\n@ IN NS ns2\n" +
+			"$ORIGIN office.example.org.\nprinter IN A 192.0.2.10\n$ORIGIN example.org.\nwww IN A 192.0.2.1\n", ""},
+		{"db.example.net", "no includes\n", ""},
+		{"db.cycle", "", "cycle"},
+		{"db.escape", "", "outside"},
+		{"db.origin", "", "needs an $ORIGIN"},
+	}
+	for _, test := range tests {
+		err := flattenFile(filepath.Join(root, "zones", test.file), filepath.Join(dst, test.file), root, 0644)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("Expected %v to fail with %q, found %v", test.file, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Expected no error flattening %v, found %v", test.file, err)
+			continue
+		}
+		if b, _ := os.ReadFile(filepath.Join(dst, test.file)); string(b) != test.expected {
+			t.Errorf("Expected %v to hold %q, found %q", test.file, test.expected, b)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package git
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// leaseHolder identifies this process in the leases of shared checkouts.
+var leaseHolder = func() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b))
+}()
+
+// leaseFile returns the lock file of the checkout, next to it so it is on
+// the same volume.
+func (r *Repo) leaseFile() string {
+	dir := r.workDir()
+	return filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+".lease")
+}
+
+// readLease returns the holder of the lease and when it expires.
+func (r *Repo) readLease() (string, time.Time, error) {
+	b, err := os.ReadFile(r.leaseFile())
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	holder, expiry, _ := strings.Cut(strings.TrimSpace(string(b)), " ")
+	n, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid lease file %v: %q", r.leaseFile(), b)
+	}
+	return holder, time.Unix(0, n), nil
+}
+
+// acquireLease takes or renews the lease on the checkout, for Lease, and
+// reports whether this process holds it. The lease is the Kubernetes Lease
+// of Election if set, a file otherwise: it is written atomically and read
+// back, so of replicas racing for an expired lease only the last writer
+// takes it.
+func (r *Repo) acquireLease(ctx context.Context) (bool, error) {
+	if r.Election != "" {
+		return r.acquireElection(ctx)
+	}
+	holder, expiry, err := r.readLease()
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err == nil && holder != leaseHolder && time.Now().Before(expiry) {
+		return false, nil
+	}
+
+	lease := fmt.Sprintf("%s %d\n", leaseHolder, time.Now().Add(r.Lease).UnixNano())
+	if err := replaceFile(strings.NewReader(lease), r.leaseFile(), 0644); err != nil {
+		return false, err
+	}
+	holder, _, err = r.readLease()
+	return holder == leaseHolder, err
+}
+
+// releaseLease removes the lease on the checkout if this process holds it.
+func (r *Repo) releaseLease() {
+	if r.Election != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := r.releaseElection(ctx); err != nil {
+			log.Warningf("Cannot release lease of %v: %s", r, err)
+		}
+		return
+	}
+	if holder, _, err := r.readLease(); err == nil && holder == leaseHolder {
+		os.Remove(r.leaseFile())
+	}
+}
@@ -0,0 +1,46 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLease(t *testing.T) {
+	repo := &Repo{Path: filepath.Join(t.TempDir(), "zones"), Lease: time.Minute}
+	if held, err := repo.acquireLease(context.Background()); err != nil || !held {
+		t.Fatalf("Expected lease to be acquired, found %v, %v", held, err)
+	}
+	// renewed by its holder
+	if held, err := repo.acquireLease(context.Background()); err != nil || !held {
+		t.Fatalf("Expected lease to be renewed, found %v, %v", held, err)
+	}
+
+	// held by another replica until it expires
+	other := fmt.Sprintf("other %d\n", time.Now().Add(time.Minute).UnixNano())
+	if err := os.WriteFile(repo.leaseFile(), []byte(other), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := repo.acquireLease(context.Background()); err != nil || held {
+		t.Errorf("Expected lease of another replica not to be acquired, found %v, %v", held, err)
+	}
+	repo.releaseLease()
+	if _, err := os.Stat(repo.leaseFile()); err != nil {
+		t.Errorf("Expected lease of another replica not to be released, found %v", err)
+	}
+
+	expired := fmt.Sprintf("other %d\n", time.Now().Add(-time.Second).UnixNano())
+	if err := os.WriteFile(repo.leaseFile(), []byte(expired), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := repo.acquireLease(context.Background()); err != nil || !held {
+		t.Errorf("Expected expired lease to be acquired, found %v, %v", held, err)
+	}
+	repo.releaseLease()
+	if _, err := os.Stat(repo.leaseFile()); !os.IsNotExist(err) {
+		t.Errorf("Expected lease to be released, found %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"io"
+	"sync"
+)
+
+// listeners holds the endpoints shared by repositories, and across reloads.
+var listeners = &sharedListeners{m: map[string]*sharedListener{}}
+
+// sharedListeners is a reference counted set of running endpoints.
+type sharedListeners struct {
+	m map[string]*sharedListener
+	sync.Mutex
+}
+
+type sharedListener struct {
+	refs int
+	c    io.Closer
+}
+
+// acquire adds a reference to the endpoint identified by key, calling
+// start to run it if it is not running yet.
+func (s *sharedListeners) acquire(key string, start func() (io.Closer, error)) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if l, ok := s.m[key]; ok {
+		l.refs++
+		return nil
+	}
+	c, err := start()
+	if err != nil {
+		return err
+	}
+	s.m[key] = &sharedListener{refs: 1, c: c}
+	return nil
+}
+
+// release removes a reference to the endpoint identified by key and
+// closes it once it is no longer used.
+func (s *sharedListeners) release(key string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	l, ok := s.m[key]
+	if !ok {
+		return nil
+	}
+	if l.refs--; l.refs > 0 {
+		return nil
+	}
+	delete(s.m, key)
+	return l.c.Close()
+}
@@ -0,0 +1,78 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestEntry is a repository listed in a manifest file.
+type manifestEntry struct {
+	Name     string `yaml:"name"`
+	URL      string `yaml:"url"`
+	Branch   string `yaml:"branch"`
+	Path     string `yaml:"path"`
+	Interval int    `yaml:"interval"`
+}
+
+// importRepos returns a repository for every entry of the manifest file
+// at path. Repositories are configured as template, with the name, URL,
+// branch, path and interval of their entry. Entry paths are resolved with clonePath.
+func importRepos(template *Repo, path string, clonePath func(string) string) ([]*Repo, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []manifestEntry
+	if err := yaml.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("cannot parse %v: %s", path, err)
+	}
+
+	repos := make([]*Repo, len(entries))
+	for i, e := range entries {
+		repo := new(Repo)
+		copyConfig(repo, template)
+		repo.Name = e.Name
+
+		if repo.URL, err = expand(e.URL); err != nil {
+			return nil, fmt.Errorf("%v: entry %d: %s", path, i, err)
+		}
+		if repo.URL == "" {
+			return nil, fmt.Errorf("%v: entry %d: no URL set", path, i)
+		}
+		if e.Branch != "" {
+			if repo.Branch, err = expand(e.Branch); err != nil {
+				return nil, fmt.Errorf("%v: entry %d: %s", path, i, err)
+			}
+		}
+		if e.Path != "" {
+			if repo.Path, err = expand(e.Path); err != nil {
+				return nil, fmt.Errorf("%v: entry %d: %s", path, i, err)
+			}
+			repo.Path = clonePath(repo.Path)
+		}
+		switch {
+		case e.Interval == -1:
+			repo.Interval = -1
+		case e.Interval < 0 || e.Interval > 0 && e.Interval < minInterval:
+			return nil, fmt.Errorf("%v: entry %d: interval must be at least %d seconds, or -1", path, i, minInterval)
+		case e.Interval > 0:
+			repo.Interval = time.Duration(e.Interval) * time.Second
+		}
+		repos[i] = repo
+	}
+	return repos, nil
+}
+
+// copyConfig copies the configuration of src, its exported fields, to dst.
+func copyConfig(dst, src *Repo) {
+	dv, sv := reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem()
+	for i := 0; i < sv.NumField(); i++ {
+		if f := sv.Type().Field(i); f.IsExported() && !f.Anonymous {
+			dv.Field(i).Set(sv.Field(i))
+		}
+	}
+}
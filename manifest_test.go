@@ -0,0 +1,63 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImportRepos(t *testing.T) {
+	dir := t.TempDir()
+	manifest := filepath.Join(dir, "repos.yaml")
+	err := os.WriteFile(manifest, []byte(`
+- url: https://github.com/user/zones-a
+  path: zones-a
+- url: https://github.com/user/zones-b
+  branch: main
+  path: /srv/zones-b
+  interval: 300
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &Repo{Branch: "master", Interval: time.Hour, LogFormat: "json", CloneArgs: []string{"--depth", "1"}}
+	clonePath := func(s string) string {
+		if filepath.IsAbs(s) {
+			return s
+		}
+		return filepath.Join(dir, s)
+	}
+	repos, err := importRepos(template, manifest, clonePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*Repo{
+		{URL: "https://github.com/user/zones-a", Path: filepath.Join(dir, "zones-a"), Branch: "master", Interval: time.Hour, CloneArgs: []string{"--depth", "1"}},
+		{URL: "https://github.com/user/zones-b", Path: "/srv/zones-b", Branch: "main", Interval: 300 * time.Second, CloneArgs: []string{"--depth", "1"}},
+	}
+	if len(repos) != len(expected) {
+		t.Fatalf("Expected %v repos, found %v", len(expected), len(repos))
+	}
+	for i := range expected {
+		if !reposEqual(expected[i], repos[i]) || repos[i].LogFormat != "json" {
+			t.Errorf("Repo %v expects %v but found %v", i, expected[i], repos[i])
+		}
+	}
+
+	if err := os.WriteFile(manifest, []byte(`- branch: main`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importRepos(template, manifest, clonePath); err == nil {
+		t.Errorf("Expected entry without url to fail")
+	}
+
+	if err := os.WriteFile(manifest, []byte(`- url: https://github.com/{$GIT_TEST_UNSET}/zones`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importRepos(template, manifest, clonePath); err == nil {
+		t.Errorf("Expected entry referencing an unset variable to fail")
+	}
+}
@@ -0,0 +1,122 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// goGitOpen returns the repository of r checked out at dir, the one kept
+// in memory with InMemory.
+func goGitOpen(r *Repo, dir string) (*gogit.Repository, error) {
+	if r.InMemory {
+		if r.mem == nil {
+			return nil, fmt.Errorf("%v is not cloned", r)
+		}
+		return r.mem, nil
+	}
+	repo, err := gogit.PlainOpen(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open %v: %s", dir, err)
+	}
+	return repo, nil
+}
+
+// goGitCloneMemory clones r in memory.
+func goGitCloneMemory(ctx context.Context, r *Repo, opts *gogit.CloneOptions) error {
+	repo, err := gogit.CloneContext(ctx, memory.NewStorage(), memfs.New(), opts)
+	if err != nil {
+		return err
+	}
+	r.mem = repo
+	return nil
+}
+
+// exportable reports whether the file at name of the repository is
+// exported by in_memory: a regular file inside the repository matching one
+// of the Export globs, if any.
+func (r *Repo) exportable(name string, mode filemode.FileMode) bool {
+	if mode != filemode.Regular && mode != filemode.Executable || !validMappingSource(filepath.FromSlash(name)) {
+		return false
+	}
+	return matchGlobs(r.Export, name)
+}
+
+// export makes dir a copy of the exportable files of the HEAD commit of
+// the repository kept in memory. Files are only written when their content
+// changed, atomically, then the files no longer exported are removed.
+func (r *Repo) export(dir string) error {
+	head, err := r.mem.Head()
+	if err != nil {
+		return err
+	}
+	commit, err := r.mem.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	files, err := commit.Files()
+	if err != nil {
+		return err
+	}
+
+	span := r.startSpan("export")
+	keep := map[string]bool{dir: true}
+	err = files.ForEach(func(f *object.File) error {
+		if !r.exportable(f.Name, f.Mode) {
+			return nil
+		}
+		target := filepath.Join(dir, filepath.FromSlash(f.Name))
+		keep[target] = true
+		for d := filepath.Dir(target); d != dir && !keep[d]; d = filepath.Dir(d) {
+			keep[d] = true
+		}
+		// a file replaced by a directory
+		for d := filepath.Dir(target); d != dir; d = filepath.Dir(d) {
+			if fi, err := os.Lstat(d); err == nil && !fi.IsDir() {
+				if err := os.Remove(d); err != nil {
+					return err
+				}
+			}
+		}
+
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		perm := os.FileMode(0644)
+		if f.Mode == filemode.Executable {
+			perm = 0755
+		}
+		if existing, err := os.ReadFile(target); err == nil && string(existing) == content {
+			if fi, err := os.Lstat(target); err == nil && fi.Mode() == perm {
+				return nil
+			}
+		}
+		// a directory replaced by a file
+		if fi, err := os.Lstat(target); err == nil && fi.IsDir() {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return replaceFile(strings.NewReader(content), target, perm)
+	})
+	if err == nil {
+		err = removeStale(dir, keep)
+	}
+	finishSpan(span, err)
+	if err != nil {
+		return fmt.Errorf("cannot export %v to %v: %s", r, dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestInMemoryPull(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "zones")
+	origin, err := gogit.PlainInitWithOptions(src, &gogit.PlainInitOptions{
+		InitOptions: gogit.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("master")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, origin, src, "README.md", "zones")
+	first := commitFile(t, origin, src, "db.example.org", "v1")
+
+	repo := &Repo{URL: src, Path: filepath.Join(t.TempDir(), "zones"), Branch: "master", Backend: backendGoGit,
+		InMemory: true, Export: []string{"db.*"}}
+	writeFiles(t, repo.Path, map[string]string{"stale": "x"})
+	if err := repo.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected clone to succeed, found %v", err)
+	}
+	if repo.lastCommit != first.String() {
+		t.Errorf("Expected commit %v, found %v", first, repo.lastCommit)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v1" {
+		t.Errorf("Expected exported file content v1, found %q", b)
+	}
+	for _, name := range []string{".git", "README.md", "stale"} {
+		if _, err := os.Stat(filepath.Join(repo.Path, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %v not to be exported, found %v", name, err)
+		}
+	}
+
+	second := commitFile(t, origin, src, "db.example.org", "v2")
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected pull to succeed, found %v", err)
+	}
+	if repo.lastCommit != second.String() {
+		t.Errorf("Expected commit %v, found %v", second, repo.lastCommit)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v2" {
+		t.Errorf("Expected exported file content v2, found %q", b)
+	}
+
+	// rolling back exports the files of the previous commit
+	if err := repo.backend().Reset(context.Background(), repo, repo.workDir(), first.String()); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v1" {
+		t.Errorf("Expected exported file content v1, found %q", b)
+	}
+}
@@ -0,0 +1,122 @@
+package git
+
+import (
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// repoInfo is an info-style gauge carrying the checked out commit of each repository.
+	repoInfo = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "git",
+		Name:      "repo_info",
+		Help:      "Info about the currently checked out commit of a repository, value is always 1.",
+	}, []string{"repo", "branch", "commit"})
+
+	// checkoutSize is the size of the checkout of the repositories with a max_size.
+	checkoutSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "git",
+		Name:      "checkout_size_bytes",
+		Help:      "Size of the checkout of a repository after its last pull, for repositories with a max_size.",
+	}, []string{"repo"})
+
+	// driftedFiles is the number of files changed locally in the checkout of the repositories with a drift_check.
+	driftedFiles = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "git",
+		Name:      "drifted_files",
+		Help:      "Number of files modified or untracked in the checkout of a repository at its last drift check, for repositories with a drift_check.",
+	}, []string{"repo"})
+
+	// pullFailures counts the failed pulls of each repository by class of error.
+	pullFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "git",
+		Name:      "pull_failures_total",
+		Help:      "Counter of failed pulls of a repository, by class of error: transient, auth, ref, permanent or unknown.",
+	}, []string{"repo", "class"})
+
+	// bytesFetched counts the bytes fetched from the remotes of each repository.
+	bytesFetched = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "git",
+		Name:      "bytes_fetched_total",
+		Help:      "Counter of bytes fetched from the remotes of a repository, as reported by git or downloaded.",
+	}, []string{"repo"})
+
+	// lastPullAgeDesc describes the seconds elapsed since the last successful pull.
+	lastPullAgeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(plugin.Namespace, "git", "last_pull_age_seconds"),
+		"Seconds since the last successful pull of a repository.",
+		[]string{"repo"}, nil,
+	)
+
+	// healthyDesc describes whether a repository pulled within its health_on_failure window.
+	healthyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(plugin.Namespace, "git", "healthy"),
+		"Whether a repository was successfully pulled within its health_on_failure window (1) or not (0).",
+		[]string{"repo"}, nil,
+	)
+)
+
+func init() { prometheus.MustRegister(stalenessCollector{}) }
+
+// stalenessCollector computes the age of every registered repository at scrape time.
+type stalenessCollector struct{}
+
+// Describe implements prometheus.Collector.
+func (stalenessCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- lastPullAgeDesc
+	ch <- healthyDesc
+}
+
+// Collect implements prometheus.Collector.
+func (stalenessCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, r := range registry.all() {
+		if r.MaxAge > 0 {
+			healthy := 0.0
+			if r.Healthy() {
+				healthy = 1
+			}
+			ch <- prometheus.MustNewConstMetric(healthyDesc, prometheus.GaugeValue, healthy, r.String())
+		}
+
+		last := r.LastPull()
+		if last.IsZero() {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(lastPullAgeDesc, prometheus.GaugeValue, time.Since(last).Seconds(), r.String())
+	}
+}
+
+// updateRepoInfo replaces the info metric of r with its current commit.
+func updateRepoInfo(r *Repo) {
+	branch := r.Branch
+	if branch == latestTag && r.latestTag != "" {
+		branch = r.latestTag
+	}
+	repoInfo.DeletePartialMatch(prometheus.Labels{"repo": r.String()})
+	repoInfo.WithLabelValues(r.String(), branch, r.lastCommit).Set(1)
+}
+
+// deleteRepoMetrics deletes the metrics of r, removed from the registry,
+// unless a repository taking over from it in a reload reports them.
+func deleteRepoMetrics(r *Repo) {
+	for _, other := range registry.all() {
+		if other.String() == r.String() {
+			return
+		}
+	}
+	labels := prometheus.Labels{"repo": r.String()}
+	repoInfo.DeletePartialMatch(labels)
+	checkoutSize.DeletePartialMatch(labels)
+	driftedFiles.DeletePartialMatch(labels)
+	pullFailures.DeletePartialMatch(labels)
+	bytesFetched.DeletePartialMatch(labels)
+}
@@ -0,0 +1,44 @@
+package git
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRepoInfo(t *testing.T) {
+	repoInfo.Reset()
+	defer repoInfo.Reset()
+
+	repo := &Repo{URL: "https://github.com/user/zones", Branch: "master", lastCommit: "1111111"}
+	registry.add(repo)
+	updateRepoInfo(repo)
+	repo.lastCommit = "2222222"
+	updateRepoInfo(repo)
+
+	// only the commit checked out is reported
+	expected := `
+# HELP coredns_git_repo_info Info about the currently checked out commit of a repository, value is always 1.
+# TYPE coredns_git_repo_info gauge
+coredns_git_repo_info{branch="master",commit="2222222",repo="https://github.com/user/zones"} 1
+`
+	if err := testutil.CollectAndCompare(repoInfo, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	// a repository taking over in a reload keeps reporting it
+	next := &Repo{URL: repo.URL, Branch: "master", lastCommit: "2222222"}
+	registry.add(next)
+	registry.remove(repo)
+	deleteRepoMetrics(repo)
+	if err := testutil.CollectAndCompare(repoInfo, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+
+	registry.remove(next)
+	deleteRepoMetrics(next)
+	if n := testutil.CollectAndCount(repoInfo); n != 0 {
+		t.Errorf("Expected the info of the removed repository to be deleted, found %v series", n)
+	}
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// mirrorRetryDelay is how long a remote which failed is only pulled from
+// once the others failed too.
+const mirrorRetryDelay = 5 * time.Minute
+
+// remotes returns the URLs to pull r from: URL then its Mirrors, the ones
+// which failed in the last mirrorRetryDelay last.
+func (r *Repo) remotes() []string {
+	remotes := append([]string{r.URL}, r.Mirrors...)
+	sort.SliceStable(remotes, func(i, j int) bool {
+		return !r.failing(remotes[i]) && r.failing(remotes[j])
+	})
+	return remotes
+}
+
+// failing reports whether pulling from remote failed in the last
+// mirrorRetryDelay.
+func (r *Repo) failing(remote string) bool {
+	failed, ok := r.failed[remote]
+	return ok && time.Since(failed) < mirrorRetryDelay
+}
+
+// remoteURL returns the URL of the remote being pulled from.
+func (r *Repo) remoteURL() string {
+	if r.remote == "" {
+		return r.URL
+	}
+	return r.remote
+}
+
+// pullRemote clones or pulls the checkout at dir with b, failing over from
+// URL to its Mirrors in order.
+func (r *Repo) pullRemote(ctx context.Context, b Backend, dir string) error {
+	if len(r.Mirrors) == 0 {
+		return r.cloneOrPull(ctx, b, dir)
+	}
+	defer func() { r.remote = "" }()
+
+	var err error
+	for i, remote := range r.remotes() {
+		if i > 0 {
+			log.Warningf("%s, failing over to %v", redact(err.Error()), redact(remote))
+		}
+		r.remote = remote
+		if err = r.cloneOrPull(ctx, b, dir); err == nil {
+			delete(r.failed, remote)
+			return nil
+		}
+		if r.failed == nil {
+			r.failed = map[string]time.Time{}
+		}
+		r.failed[remote] = time.Now()
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return err
+}
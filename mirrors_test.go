@@ -0,0 +1,54 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRemotes(t *testing.T) {
+	repo := &Repo{URL: "https://a", Mirrors: []string{"https://b", "https://c"}}
+	repo.failed = map[string]time.Time{
+		"https://a": time.Now(),
+		"https://b": time.Now().Add(-2 * mirrorRetryDelay),
+	}
+	expected := []string{"https://b", "https://c", "https://a"}
+	if remotes := repo.remotes(); len(remotes) != 3 || remotes[0] != expected[0] || remotes[1] != expected[1] || remotes[2] != expected[2] {
+		t.Errorf("Expected remotes %v, found %v", expected, remotes)
+	}
+}
+
+func TestMirrorsPull(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	mirror := newOrigin(t, map[string]string{"db.example.org": "v1"})
+
+	repo := &Repo{URL: filepath.Join(dir, "missing.git"), Path: filepath.Join(dir, "zones"), Branch: "master", Mirrors: []string{mirror}}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected failover to the mirror, found %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v1" {
+		t.Errorf("Expected content v1, found %q", b)
+	}
+	if origin, _ := repo.originURL(); origin != repo.URL {
+		t.Errorf("Expected origin %v, found %v", repo.URL, origin)
+	}
+	if !repo.failing(repo.URL) || repo.failing(mirror) {
+		t.Errorf("Expected only %v to be failing, found %v", repo.URL, repo.failed)
+	}
+
+	// the mirror keeps being pulled from while URL is failing
+	writeFiles(t, mirror, map[string]string{"db.example.org": "v2"})
+	runGit(t, mirror, "commit", "-q", "-am", "v2")
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v2" {
+		t.Errorf("Expected content v2, found %q", b)
+	}
+}
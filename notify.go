@@ -0,0 +1,60 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Notify is a zone whose NOTIFY messages (RFC 1996) trigger a pull of the
+// repository.
+type Notify struct {
+	Zone string   // Zone notified, fully qualified
+	Keys []string // Names of the TSIG keys allowed to notify the zone, any if empty
+}
+
+// notifyRepos returns the repositories pulled when zone is notified, with
+// the names of the keys allowed to notify it.
+func (h Handler) notifyRepos(zone string) map[*Repo][]string {
+	repos := map[*Repo][]string{}
+	for _, r := range h.Repos {
+		for _, n := range r.Notifies {
+			if strings.EqualFold(n.Zone, zone) {
+				repos[r] = n.Keys
+			}
+		}
+	}
+	return repos
+}
+
+// serveNotify answers the NOTIFY r of a zone, triggering a pull of repos in
+// background. Only messages signed with a TSIG key allowed by each
+// repository trigger its pull: the response is refused if none is pulled.
+func (h Handler) serveNotify(w dns.ResponseWriter, r *dns.Msg, repos map[*Repo][]string) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	key, signed := h.tsigKey(w, r)
+	pulled := 0
+	for repo, keys := range repos {
+		if !signed || len(keys) > 0 && !contains(keys, key) {
+			continue
+		}
+		pulled++
+		go repo.pullFrom(repo.lifetime(), sourceNotify)
+	}
+	if pulled == 0 {
+		m.Rcode = dns.RcodeRefused
+	}
+	if t := r.IsTsig(); t != nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, t.Fudge, time.Now().Unix())
+	}
+
+	if err := w.WriteMsg(m); err != nil {
+		return dns.RcodeServerFailure, fmt.Errorf("writing notify response: %s", err)
+	}
+	return m.Rcode, nil
+}
@@ -0,0 +1,60 @@
+package git
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestServeNotify(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	work := newOrigin(t, map[string]string{"db.example.org": "v1"})
+
+	repo := &Repo{
+		URL: work, Path: filepath.Join(dir, "zones"), Branch: "master",
+		Notifies: []Notify{{Zone: "example.org.", Keys: []string{"notify."}}},
+	}
+	h := Handler{Repos: Git{repo}, Next: test.ErrorHandler(), Config: tsigConfig("notify.", "other.")}
+
+	notify := func(zone, key string) int {
+		m := new(dns.Msg)
+		m.SetNotify(zone)
+		if key != "" {
+			m = sign(t, m, key, testSecret)
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		h.ServeDNS(context.TODO(), rec, m)
+		return rec.Rcode
+	}
+
+	for _, key := range []string{"", "other."} {
+		if rcode := notify("example.org.", key); rcode != dns.RcodeRefused {
+			t.Errorf("Expected notify signed with %q to be refused, found %v", key, dns.RcodeToString[rcode])
+		}
+	}
+	// not handled by git
+	if rcode := notify("example.net.", "notify."); rcode != dns.RcodeServerFailure {
+		t.Errorf("Expected notify of another zone to be passed on, found %v", dns.RcodeToString[rcode])
+	}
+	if rcode := notify("example.org.", "notify."); rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected notify to succeed, found %v", dns.RcodeToString[rcode])
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for repo.Commit() == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if h := repo.History(); len(h) != 1 || h[0].Trigger != sourceNotify || h[0].Error != "" {
+		t.Errorf("Expected a pull triggered by the notify, found %+v", h)
+	}
+}
@@ -0,0 +1,8 @@
+package git
+
+// Layout of the repositories with an overlay: the files of the base
+// directory, overridden by the ones of the directory of the overlay.
+const (
+	overlayBase = "base"
+	overlaysDir = "overlays"
+)
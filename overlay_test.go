@@ -0,0 +1,57 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPublishOverlay(t *testing.T) {
+	dir := t.TempDir()
+	repo := &Repo{Path: filepath.Join(dir, "zones"), Overlay: "prod"}
+	repo.lastCommit = "c1"
+	writeFiles(t, repo.workDir(), map[string]string{
+		"base/db.example.org":           "base",
+		"base/db.example.net":           "base",
+		"base/keys/tsig.key":            "base",
+		"overlays/prod/db.example.org":  "prod",
+		"overlays/prod/keys/extra.key":  "prod",
+		"overlays/stage/db.example.org": "stage",
+	})
+	if err := repo.publish(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"db.example.org": "prod",
+		"db.example.net": "base",
+		"keys/tsig.key":  "base",
+		"keys/extra.key": "prod",
+	}
+	for name, content := range expected {
+		b, err := os.ReadFile(filepath.Join(repo.Path, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != content {
+			t.Errorf("%s: expected %q, got %q", name, content, b)
+		}
+	}
+
+	// files removed from the overlay fall back to the base
+	if err := os.Remove(filepath.Join(repo.workDir(), "overlays/prod/db.example.org")); err != nil {
+		t.Fatal(err)
+	}
+	repo.lastCommit = "c2"
+	if err := repo.publish(); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "base" {
+		t.Errorf("expected the base db.example.org, got %q", b)
+	}
+
+	repo.Overlay, repo.lastCommit = "test", "c3"
+	if err := repo.publish(); err == nil {
+		t.Error("expected an error for a missing overlay")
+	}
+}
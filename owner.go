@@ -0,0 +1,104 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Owner is a user and group, by numeric ids.
+type Owner struct {
+	UID int
+	GID int
+}
+
+// String returns owner as UID:GID.
+func (o *Owner) String() string { return fmt.Sprintf("%d:%d", o.UID, o.GID) }
+
+// parseOwner parses an owner written as UID:GID.
+func parseOwner(s string) (*Owner, error) {
+	uid, gid, ok := strings.Cut(s, ":")
+	u, uerr := strconv.Atoi(uid)
+	g, gerr := strconv.Atoi(gid)
+	if !ok || uerr != nil || gerr != nil || u < 0 || g < 0 {
+		return nil, fmt.Errorf("invalid owner, expected UID:GID: %s", s)
+	}
+	return &Owner{UID: u, GID: g}, nil
+}
+
+// chown makes o own path. It does nothing if o is nil.
+func (o *Owner) chown(path string) error {
+	if o == nil {
+		return nil
+	}
+	return os.Lchown(path, o.UID, o.GID)
+}
+
+// chownCheckout makes Owner own the files of the checkout and of the
+// targets it is published to. Git metadata and the checkout directory
+// itself are left untouched, for git not to refuse a checkout owned by
+// another user.
+func (r *Repo) chownCheckout() error {
+	if r.Owner == nil {
+		return nil
+	}
+	err := r.walkServed(func(path string) error {
+		if path == r.workDir() {
+			return nil
+		}
+		return r.Owner.chown(path)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot chown the checkout of %v to %v: %s", r, r.Owner, err)
+	}
+	return nil
+}
+
+// walkServed calls fn with every file and directory of the checkout and of
+// the targets it is published to, except git metadata.
+func (r *Repo) walkServed(fn func(path string) error) error {
+	dirs := []string{r.workDir()}
+	for _, m := range r.Maps {
+		dirs = append(dirs, m.To)
+	}
+	if r.apart() {
+		dirs = append(dirs, r.Path)
+	}
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() && (fi.Name() == ".git" || fi.Name() == ".hg" || fi.Name() == archiveMeta) {
+				return filepath.SkipDir
+			}
+			return fn(path)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runAsKey is the context key of the owner external commands run as.
+type runAsKey struct{}
+
+// commandContext returns ctx, carrying the owner the external commands of
+// r run as.
+func (r *Repo) commandContext(ctx context.Context) context.Context {
+	if r.RunAs == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, runAsKey{}, r.RunAs)
+}
+
+// RunAs returns the user and group a Runner must run the command of ctx
+// as, or nil to run it as this process.
+func RunAs(ctx context.Context) *Owner {
+	owner, _ := ctx.Value(runAsKey{}).(*Owner)
+	return owner
+}
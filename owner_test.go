@@ -0,0 +1,60 @@
+package git
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestParseOwner(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1000:1000", "1000:1000"},
+		{"65534:0", "65534:0"},
+		{"1000", ""},
+		{"nobody:nogroup", ""},
+		{"-1:0", ""},
+	}
+	for i, test := range tests {
+		owner, err := parseOwner(test.input)
+		if test.expected == "" {
+			if err == nil {
+				t.Errorf("Test %d: expected error, found %v", i, owner)
+			}
+			continue
+		}
+		if err != nil || owner.String() != test.expected {
+			t.Errorf("Test %d: expected %v, found %v, %v", i, test.expected, owner, err)
+		}
+	}
+}
+
+// ownerRunner records the owner commands are run as.
+type ownerRunner struct{ owners []*Owner }
+
+func (r *ownerRunner) Run(ctx context.Context, dir string, env []string, stdout, stderr io.Writer, command string, args ...string) error {
+	r.owners = append(r.owners, RunAs(ctx))
+	io.WriteString(stdout, "0123456789abcdef")
+	return nil
+}
+
+func TestRunAs(t *testing.T) {
+	runner := &ownerRunner{}
+	CommandRunner = runner
+	defer func() { CommandRunner = ExecRunner{} }()
+
+	repo := &Repo{URL: "https://github.com/user/zones", Path: t.TempDir(), Branch: "master", RunAs: &Owner{UID: 1000, GID: 1000}}
+	if err := repo.PullContext(context.Background()); err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if len(runner.owners) == 0 {
+		t.Fatal("Expected commands to run")
+	}
+	for _, owner := range runner.owners {
+		if owner != repo.RunAs {
+			t.Errorf("Expected commands to run as %v, found %v", repo.RunAs, owner)
+		}
+	}
+}
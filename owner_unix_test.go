@@ -0,0 +1,32 @@
+//go:build !windows && !plan9
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestChownCheckout(t *testing.T) {
+	if os.Getuid() != 0 {
+		t.Skip("chown needs root")
+	}
+	dir := t.TempDir()
+	repo := &Repo{Path: filepath.Join(dir, "zones"), Maps: []Mapping{{From: "dns", To: filepath.Join(dir, "dns")}}, Owner: &Owner{UID: 65534, GID: 65534}}
+	writeFiles(t, repo.Path, map[string]string{"dns/db.example.org": "v1", ".git/HEAD": "ref"})
+	writeFiles(t, filepath.Join(dir, "dns"), map[string]string{"db.example.org": "v1"})
+	if err := repo.chownCheckout(); err != nil {
+		t.Fatal(err)
+	}
+	for name, uid := range map[string]uint32{"zones": 0, "zones/dns/db.example.org": 65534, "dns/db.example.org": 65534, "zones/.git/HEAD": 0} {
+		fi, err := os.Lstat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st := fi.Sys().(*syscall.Stat_t); st.Uid != uid {
+			t.Errorf("Expected %v to be owned by %d, found %d", name, uid, st.Uid)
+		}
+	}
+}
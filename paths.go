@@ -0,0 +1,164 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coredns/caddy"
+)
+
+// claimedPathsKey is the key of the paths claimed by repositories in the
+// storage of the caddy instance, shared by all server blocks.
+type claimedPathsKey struct{}
+
+// paths returns the directories the repository writes to.
+func (r *Repo) paths() []string {
+	paths := []string{r.workDir()}
+	if r.apart() {
+		paths = append(paths, r.Path)
+	}
+	for _, m := range r.Maps {
+		paths = append(paths, m.To)
+	}
+	return paths
+}
+
+// claimPaths records the directories repo writes to in the instance of c.
+// It fails if one of them is, contains or is inside a directory another
+// repository, or repo itself, already writes to.
+func claimPaths(c *caddy.Controller, repo *Repo) error {
+	claimed, _ := c.Get(claimedPathsKey{}).(map[string]*Repo)
+	if claimed == nil {
+		claimed = map[string]*Repo{}
+		c.Set(claimedPathsKey{}, claimed)
+	}
+
+	for _, p := range repo.paths() {
+		for q, other := range claimed {
+			if !overlap(p, q) {
+				continue
+			}
+			if other == repo {
+				return fmt.Errorf("paths %v and %v of repo %v overlap", q, p, repo)
+			}
+			return fmt.Errorf("path %v of repo %v overlaps path %v of repo %v", p, repo, q, other)
+		}
+		claimed[p] = repo
+	}
+	return nil
+}
+
+// resolvePath returns path resolved against the directory base. On Windows,
+// paths with a drive but no root (C:zones) or a root but no drive (\zones)
+// are not absolute but cannot be joined to base either: the former are only
+// cleaned, the latter are put on the drive of base.
+func resolvePath(base, path string) string {
+	switch {
+	case filepath.IsAbs(path), filepath.VolumeName(path) != "":
+		return filepath.Clean(path)
+	case path != "" && os.IsPathSeparator(path[0]):
+		return filepath.Clean(filepath.VolumeName(base) + path)
+	}
+	return filepath.Join(base, path)
+}
+
+// overlap reports whether the directories a and b are the same, or one is inside the other.
+func overlap(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if a == b {
+		return true
+	}
+	sep := string(filepath.Separator)
+	return strings.HasPrefix(a, strings.TrimSuffix(b, sep)+sep) || strings.HasPrefix(b, strings.TrimSuffix(a, sep)+sep)
+}
+
+// expandPath replaces the {zone}, {repo} and {branch} placeholders in path
+// with the zone of the server block, the name of the repository and its
+// branch. The root zone is "root" and the {latest} branch "latest".
+func (r *Repo) expandPath(path, zone string) string {
+	zone = strings.TrimSuffix(zone, ".")
+	if zone == "" {
+		zone = "root"
+	}
+	branch := r.Branch
+	if branch == latestTag {
+		branch = "latest"
+	}
+	return strings.NewReplacer(
+		"{zone}", zone,
+		"{repo}", repoName(r.URL),
+		"{branch}", strings.ReplaceAll(branch, "/", "-"),
+	).Replace(path)
+}
+
+// inside reports whether path is dir or inside it.
+func inside(path, dir string) bool {
+	path, dir = filepath.Clean(path), filepath.Clean(dir)
+	sep := string(filepath.Separator)
+	return path == dir || strings.HasPrefix(path, strings.TrimSuffix(dir, sep)+sep)
+}
+
+// evalPath returns path with the symbolic links of its existing part
+// resolved, so it can be compared with the directory the links point to.
+func evalPath(path string) string {
+	var rest []string
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...)
+		}
+		if filepath.Dir(p) == p {
+			return filepath.Clean(path)
+		}
+		rest = append([]string{filepath.Base(p)}, rest...)
+	}
+}
+
+// checkBase checks the directories the repository writes to are inside
+// BaseDir, once symbolic links are resolved.
+func (r *Repo) checkBase() error {
+	if r.BaseDir == "" {
+		return nil
+	}
+	base := evalPath(r.BaseDir)
+	for _, p := range r.paths() {
+		if !inside(evalPath(p), base) {
+			return fmt.Errorf("path %v of repo %v is outside of base_dir %v", p, r, r.BaseDir)
+		}
+	}
+	return nil
+}
+
+// checkLinks checks the symbolic links of the checkout at dir point inside
+// BaseDir, so publishing, chowning or reading the checkout never reaches
+// other files of the host.
+func (r *Repo) checkLinks(dir string) error {
+	if r.BaseDir == "" {
+		return nil
+	}
+	base := evalPath(r.BaseDir)
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && (fi.Name() == ".git" || fi.Name() == ".hg" || fi.Name() == archiveMeta) {
+			return filepath.SkipDir
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			return nil
+		}
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(path), target)
+		}
+		if !inside(evalPath(target), base) {
+			rel, _ := filepath.Rel(dir, path)
+			return fmt.Errorf("symbolic link %v of repo %v points outside of base_dir %v", rel, r, r.BaseDir)
+		}
+		return nil
+	})
+}
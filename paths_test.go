@@ -0,0 +1,150 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/core/dnsserver"
+)
+
+func TestOverlap(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"/tmp/zones", "/tmp/zones", true},
+		{"/tmp/zones/", "/tmp/zones", true},
+		{"/tmp/zones", "/tmp/zones/prod", true},
+		{"/tmp/zones/prod", "/tmp/zones", true},
+		{"/tmp/zones", "/tmp/zones2", false},
+		{"/tmp/zones", "/tmp/.zones.git", false},
+		{"/", "/tmp/zones", true},
+	}
+	for i, test := range tests {
+		if got := overlap(test.a, test.b); got != test.expected {
+			t.Errorf("Test %v expects %v but found %v", i, test.expected, got)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	tests := []struct {
+		base, path, expected string
+	}{
+		{"/etc/coredns", "zones", "/etc/coredns/zones"},
+		{"/etc/coredns", "zones/../prod/", "/etc/coredns/prod"},
+		{"/etc/coredns", "/var/lib/zones", "/var/lib/zones"},
+		{"/etc/coredns", "", "/etc/coredns"},
+		{"", "zones", "zones"},
+	}
+	for i, test := range tests {
+		if got := resolvePath(filepath.FromSlash(test.base), filepath.FromSlash(test.path)); got != filepath.FromSlash(test.expected) {
+			t.Errorf("Test %v expects %v but found %v", i, test.expected, got)
+		}
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	repo := &Repo{URL: "git@github.com:user/zones.git", Branch: latestTag}
+	tests := []struct {
+		path, zone, expected string
+	}{
+		{"/var/lib/coredns/{zone}", "example.org.", "/var/lib/coredns/example.org"},
+		{"/var/lib/coredns/{zone}", ".", "/var/lib/coredns/root"},
+		{"/var/lib/coredns/{repo}/{branch}", "example.org.", "/var/lib/coredns/zones/latest"},
+		{"/var/lib/coredns/zones", "example.org.", "/var/lib/coredns/zones"},
+	}
+	for i, test := range tests {
+		if got := repo.expandPath(test.path, test.zone); got != test.expected {
+			t.Errorf("Test %v expects %v but found %v", i, test.expected, got)
+		}
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	root := t.TempDir()
+	c := caddy.NewTestController("dns", `git https://github.com/user/zones.git`)
+	dnsserver.GetConfig(c).Root = root
+	git, err := parse(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(root, "zones"); git[0].Path != expected {
+		t.Errorf("Expected the default path %v, found %v", expected, git[0].Path)
+	}
+
+	// never the working directory
+	_, err = parse(caddy.NewTestController("dns", `git https://github.com/user/zones.git`))
+	if err == nil || !strings.Contains(err.Error(), "no path set") {
+		t.Errorf("Expected no default path without root, found %v", err)
+	}
+}
+
+func TestCheckBase(t *testing.T) {
+	base := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(base, "link")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	tests := []struct {
+		repo      *Repo
+		shouldErr bool
+	}{
+		{&Repo{Path: filepath.Join(base, "zones")}, false},
+		{&Repo{Path: base}, false},
+		{&Repo{Path: filepath.Join(base, "zones"), Maps: []Mapping{{From: "prod", To: filepath.Join(base, "prod")}}}, false},
+		{&Repo{Path: filepath.Join(outside, "zones")}, true},
+		{&Repo{Path: filepath.Join(base, "..", "zones")}, true},
+		{&Repo{Path: filepath.Join(base, "link", "zones")}, true},
+		{&Repo{Path: filepath.Join(base, "zones"), Maps: []Mapping{{From: "prod", To: outside}}}, true},
+		{&Repo{Path: base, Subpath: "prod"}, true},
+	}
+	for i, test := range tests {
+		test.repo.BaseDir = base
+		err := test.repo.checkBase()
+		if test.shouldErr != (err != nil) {
+			t.Errorf("Test %d: expected error %v, found %v", i, test.shouldErr, err)
+		}
+	}
+}
+
+func TestCheckLinks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	src := newOrigin(t, map[string]string{"db.example.org": "v1"})
+	if err := os.Symlink("db.example.org", filepath.Join(src, "db.example.com")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	runGit(t, src, "add", ".")
+	runGit(t, src, "commit", "-q", "-m", "link")
+
+	base := filepath.Join(dir, "zones")
+	repo := &Repo{URL: src, Path: filepath.Join(base, "example"), Branch: "master", BaseDir: base}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected links inside base_dir to be allowed, found %v", err)
+	}
+	good := repo.lastCommit
+
+	// a link out of base_dir rejects the commit, which is not checked out
+	if err := os.Symlink("../../../etc", filepath.Join(src, "etc")); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, src, "add", ".")
+	runGit(t, src, "commit", "-q", "-m", "v2")
+	if err := repo.pull(context.Background()); err == nil {
+		t.Fatal("Expected a link outside of base_dir to fail the pull")
+	}
+	if _, err := os.Lstat(filepath.Join(repo.Path, "etc")); !os.IsNotExist(err) {
+		t.Errorf("Expected the link to be removed, found %v", err)
+	}
+	if repo.lastCommit != good {
+		t.Errorf("Expected commit %v to stay checked out, found %v", good, repo.lastCommit)
+	}
+}
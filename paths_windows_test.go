@@ -0,0 +1,36 @@
+package git
+
+import "testing"
+
+func TestResolvePathWindows(t *testing.T) {
+	tests := []struct {
+		base, path, expected string
+	}{
+		{`C:\coredns`, `zones`, `C:\coredns\zones`},
+		{`C:\coredns`, `D:\zones`, `D:\zones`},
+		{`C:\coredns`, `D:/zones/prod`, `D:\zones\prod`},
+		{`C:\coredns`, `D:zones`, `D:zones`},
+		{`C:\coredns`, `\zones`, `C:\zones`},
+		{`\\server\share\coredns`, `\zones`, `\\server\share\zones`},
+		{`C:\coredns`, `\\server\share\zones`, `\\server\share\zones`},
+	}
+	for i, test := range tests {
+		if got := resolvePath(test.base, test.path); got != test.expected {
+			t.Errorf("Test %v expects %v but found %v", i, test.expected, got)
+		}
+	}
+}
+
+func TestValidMappingSourceWindows(t *testing.T) {
+	for from, valid := range map[string]bool{
+		`zones\prod`: true,
+		`C:zones`:    false,
+		`C:\zones`:   false,
+		`\zones`:     false,
+		`..\zones`:   false,
+	} {
+		if validMappingSource(from) != valid {
+			t.Errorf("Expected %q valid to be %v", from, valid)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// specialModes are the setuid, setgid and sticky bits StripModes removes.
+const specialModes = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// applyModes sets the permissions of the files and directories of the
+// checkout at dir to FileMode and DirMode, or to theirs without the bits
+// of Umask, whatever the repository recorded. StripModes then removes the
+// executable bits of files and the special modes of both. Git metadata and
+// symbolic links are left untouched.
+func (r *Repo) applyModes(dir string) error {
+	if r.FileMode == 0 && r.DirMode == 0 && r.Umask == 0 && !r.StripModes {
+		return nil
+	}
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && (fi.Name() == ".git" || fi.Name() == ".hg" || fi.Name() == archiveMeta) {
+			return filepath.SkipDir
+		}
+		mode := fi.Mode().Perm() &^ r.Umask
+		switch {
+		case fi.IsDir() && r.DirMode != 0:
+			mode = r.DirMode
+		case fi.Mode().IsRegular() && r.FileMode != 0:
+			mode = r.FileMode
+		case !fi.IsDir() && !fi.Mode().IsRegular():
+			return nil
+		}
+		if r.StripModes && fi.Mode().IsRegular() {
+			mode &^= 0111
+		}
+		if mode == fi.Mode().Perm() && (!r.StripModes || fi.Mode()&specialModes == 0) {
+			return nil
+		}
+		return os.Chmod(path, mode)
+	})
+}
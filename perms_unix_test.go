@@ -0,0 +1,58 @@
+//go:build !windows && !plan9
+
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyModes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"db.example.org": "v1", "sub/db.example.net": "v1", "run.sh": "#!/bin/sh", ".git/HEAD": "ref"})
+	os.Chmod(filepath.Join(dir, "run.sh"), 0755)
+	mode := func(name string) os.FileMode {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fi.Mode().Perm()
+	}
+
+	repo := &Repo{Umask: 0027}
+	if err := repo.applyModes(dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]os.FileMode{"db.example.org": 0640, "run.sh": 0750, "sub": 0750, "sub/db.example.net": 0640, ".git/HEAD": 0644} {
+		if m := mode(name); m != expected {
+			t.Errorf("Expected %v with umask to have mode %v, found %v", name, expected, m)
+		}
+	}
+
+	repo = &Repo{FileMode: 0600, DirMode: 0700}
+	if err := repo.applyModes(dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]os.FileMode{"db.example.org": 0600, "run.sh": 0600, "sub": 0700, "sub/db.example.net": 0600} {
+		if m := mode(name); m != expected {
+			t.Errorf("Expected %v to have mode %v, found %v", name, expected, m)
+		}
+	}
+
+	os.Chmod(filepath.Join(dir, "run.sh"), 0755|os.ModeSetuid)
+	os.Chmod(filepath.Join(dir, "sub"), 0755|os.ModeSetgid)
+	repo = &Repo{StripModes: true}
+	if err := repo.applyModes(dir); err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]os.FileMode{"run.sh": 0644, "sub": 0755 | os.ModeDir} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := fi.Mode(); m != expected {
+			t.Errorf("Expected %v with strip_modes to have mode %v, found %v", name, expected, m)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package git
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// allowed checks the remotes r pulls from, its URL, Mirrors and bundle
+// mirror, are allowed by Hosts and Schemes.
+func (r *Repo) allowed() error {
+	remotes := append([]string{r.URL}, r.Mirrors...)
+	if r.Bundles != "" {
+		u, err := bundleURL(r.Bundles, r.Branch)
+		if err != nil {
+			return err
+		}
+		remotes = append(remotes, u)
+	}
+	for _, remote := range remotes {
+		scheme, host := remoteOf(remote)
+		if len(r.Schemes) > 0 && !contains(r.Schemes, scheme) {
+			return fmt.Errorf("scheme %s of %s is not allowed", scheme, redact(remote))
+		}
+		if len(r.Hosts) > 0 && !hostAllowed(r.Hosts, host) {
+			return fmt.Errorf("host of %s is not allowed", redact(remote))
+		}
+	}
+	return nil
+}
+
+// hostAllowed reports whether host matches one of patterns, host names
+// where * matches any labels, e.g. *.corp.example.
+func hostAllowed(patterns []string, host string) bool {
+	if host == "" {
+		return false
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether list contains s.
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
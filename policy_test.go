@@ -0,0 +1,28 @@
+package git
+
+import "testing"
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		repo      *Repo
+		shouldErr bool
+	}{
+		{&Repo{URL: "https://github.com/user/zones"}, false},
+		{&Repo{URL: "https://github.com/user/zones", Hosts: []string{"github.com"}}, false},
+		{&Repo{URL: "git@git.corp.example:dns/zones", Hosts: []string{"github.com", "*.corp.example"}}, false},
+		{&Repo{URL: "https://corp.example/dns/zones", Hosts: []string{"*.corp.example"}}, true},
+		{&Repo{URL: "https://github.com.evil.example/zones", Hosts: []string{"github.com"}}, true},
+		{&Repo{URL: "/srv/git/zones", Hosts: []string{"github.com"}}, true},
+		{&Repo{URL: "https://github.com/user/zones", Mirrors: []string{"https://mirror.example/zones"}, Hosts: []string{"github.com"}}, true},
+		{&Repo{URL: "https://github.com/user/zones", Bundles: "s3://bucket/zones", Branch: "master", Hosts: []string{"github.com", "*.amazonaws.com"}}, false},
+		{&Repo{URL: "https://github.com/user/zones", Schemes: []string{"https", "ssh"}}, false},
+		{&Repo{URL: "http://github.com/user/zones", Schemes: []string{"https", "ssh"}}, true},
+		{&Repo{URL: "git@github.com:user/zones", Schemes: []string{"https"}}, true},
+	}
+	for i, test := range tests {
+		err := test.repo.allowed()
+		if test.shouldErr != (err != nil) {
+			t.Errorf("Test %d: expected error %v, found %v", i, test.shouldErr, err)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Mapping publishes a subdirectory of the checkout at another path.
+type Mapping struct {
+	From string // Directory in the repository
+	To   string // Directory to publish to
+}
+
+// mappings returns the directories of the checkout to publish: the
+// subpath, or the base of the overlay, at the repository path, and the
+// mapped directories.
+func (r *Repo) mappings() []Mapping {
+	switch {
+	case r.Subpath != "":
+		return append([]Mapping{{From: r.Subpath, To: r.Path}}, r.Maps...)
+	case r.Overlay != "":
+		return append([]Mapping{{From: overlayBase, To: r.Path}}, r.Maps...)
+	}
+	return r.Maps
+}
+
+// apart reports whether the checkout is kept apart from Path, which only
+// holds what is published there.
+func (r *Repo) apart() bool { return r.Subpath != "" || r.Overlay != "" }
+
+// publish copies the mapped directories of the checkout to their targets.
+// It does nothing if the checked out commit was already published.
+func (r *Repo) publish() error {
+	maps := r.mappings()
+	if len(maps) == 0 && len(r.Assemblies) == 0 || r.lastCommit == r.published {
+		return nil
+	}
+
+	var values map[string]interface{}
+	if r.Render {
+		var err error
+		if values, err = r.renderValues(); err != nil {
+			return fmt.Errorf("cannot load the values of the templates of %v: %s", r, err)
+		}
+	}
+
+	span := r.startSpan("publish")
+	var err error
+	for _, m := range maps {
+		if err = r.publishDir(filepath.Join(r.workDir(), m.From), m.To, values); err != nil {
+			err = fmt.Errorf("cannot publish %v to %v: %s", m.From, m.To, err)
+			break
+		}
+	}
+	if err == nil {
+		err = r.assemble()
+	}
+	finishSpan(span, err)
+	if err != nil {
+		return err
+	}
+
+	r.published = r.lastCommit
+	return nil
+}
+
+// publishDir publishes the directory src of the checkout at dst: only the
+// files matching PublishOnly, following symbolic links with the follow
+// policy, rendering templates with Render, compiling records with Records,
+// flattening $INCLUDE directives with Includes, decrypting files with
+// Decrypt and normalizing line endings with EOL.
+func (r *Repo) publishDir(src, dst string, values map[string]interface{}) error {
+	opts := syncOptions{only: r.PublishOnly, values: values, records: r.Records, eol: r.EOL}
+	if r.Symlinks == symlinksFollow {
+		opts.root = r.workDir()
+	}
+	if r.Includes {
+		opts.includes = r.workDir()
+	}
+	if r.Decrypt {
+		ctx := r.commandContext(r.lifetime())
+		opts.decrypt = func(tool, src, dst string, perm os.FileMode) error {
+			return r.decryptFile(ctx, tool, src, dst, perm)
+		}
+	}
+	if r.Overlay != "" && dst == r.Path {
+		opts.overlays = []string{filepath.Join(r.workDir(), overlaysDir, r.Overlay)}
+	}
+	// the zones assembled at path are replaced afterwards
+	if dst == r.Path {
+		for _, a := range r.Assemblies {
+			opts.preserve = append(opts.preserve, filepath.Join(r.Path, a.File))
+		}
+	}
+	return syncTree(src, dst, opts)
+}
+
+// syncDir makes dst a copy of src, ignoring .git directories. Each file
+// is replaced atomically so readers never see a partially written file,
+// then the files not in src are removed from dst.
+func syncDir(src, dst string) error { return syncTree(src, dst, syncOptions{}) }
+
+// syncOptions changes what syncTree copies.
+type syncOptions struct {
+	// directory the symbolic links are followed inside of: the files and
+	// directories they point to are copied instead, none if empty
+	root string
+
+	// globs of the files copied, all if empty
+	only []string
+
+	// values the templates are rendered with, copied as is if nil
+	values map[string]interface{}
+
+	// whether to compile files of records into zone files
+	records bool
+
+	// directory the $INCLUDE directives of the files are resolved inside
+	// of, copied as is if empty
+	includes string
+
+	// files of dst kept although they are not in src
+	preserve []string
+
+	// directories copied before src, whose files override the ones of src
+	overlays []string
+
+	// whether to convert the CRLF line endings of text files to LF
+	eol bool
+
+	// decrypts the file src encrypted with tool into dst, encrypted files
+	// are copied as is if nil
+	decrypt func(tool, src, dst string, perm os.FileMode) error
+}
+
+// syncTree is syncDir, copying what opts selects.
+func syncTree(src, dst string, opts syncOptions) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("%v is not a directory", src)
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	s := syncer{syncOptions: opts, dst: dst, keep: map[string]bool{dst: true}, copying: map[string]bool{}}
+	for _, path := range opts.preserve {
+		for p := path; inside(p, dst) && !s.keep[p]; p = filepath.Dir(p) {
+			s.keep[p] = true
+		}
+	}
+	for _, overlay := range opts.overlays {
+		if fi, err := os.Stat(overlay); err != nil || !fi.IsDir() {
+			return fmt.Errorf("no overlay directory %v", overlay)
+		}
+		if err := s.copy(overlay, dst); err != nil {
+			return err
+		}
+	}
+	if err := s.copy(src, dst); err != nil {
+		return err
+	}
+	return removeStale(dst, s.keep)
+}
+
+// syncer copies a directory for syncTree.
+type syncer struct {
+	syncOptions
+	dst     string          // directory copied to
+	keep    map[string]bool // paths copied
+	copying map[string]bool // directories being copied, to detect links to one of them
+}
+
+// copy copies src to dst, a directory inside s.dst.
+func (s *syncer) copy(src, dst string) error {
+	real := evalPath(src)
+	s.copying[real] = true
+	defer delete(s.copying, real)
+
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && fi.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		followed := false
+		if s.root != "" && fi.Mode()&os.ModeSymlink != 0 {
+			followed = true
+			if path, err = filepath.EvalSymlinks(path); err != nil {
+				return err
+			}
+			if !inside(path, evalPath(s.root)) {
+				return fmt.Errorf("symbolic link %v points outside of the repository", rel)
+			}
+			if fi, err = os.Stat(path); err != nil {
+				return err
+			}
+		}
+
+		// templates are published rendered, without their suffix
+		rendered := s.values != nil && fi.Mode().IsRegular() && strings.HasSuffix(target, renderSuffix) && filepath.Base(target) != renderSuffix
+		if rendered {
+			target = strings.TrimSuffix(target, renderSuffix)
+		}
+		// and records compiled into zone files
+		compiled := false
+		if s.records && !rendered && fi.Mode().IsRegular() {
+			target, compiled = recordsTarget(target)
+		}
+
+		// encrypted files are published decrypted
+		encrypted := ""
+		if s.decrypt != nil && !rendered && !compiled && fi.Mode().IsRegular() {
+			if encrypted, err = encryptedWith(path); err != nil {
+				return err
+			}
+			if encrypted == encryptedAge && filepath.Base(target) != ageSuffix {
+				target = strings.TrimSuffix(target, ageSuffix)
+			}
+		}
+
+		// what an overlay copied already is not replaced
+		if s.keep[target] {
+			if !fi.IsDir() {
+				return nil
+			}
+			if existing, err := os.Lstat(target); err == nil && !existing.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+
+		// directories are only created along with their files with only
+		if fi.IsDir() && len(s.only) > 0 {
+			if !followed {
+				return nil
+			}
+		} else if name, _ := filepath.Rel(s.dst, target); !fi.IsDir() && !matchGlobs(s.only, filepath.ToSlash(name)) {
+			return nil
+		} else if err := s.mark(target, fi.IsDir()); err != nil {
+			return err
+		}
+
+		switch {
+		case fi.IsDir() && followed:
+			if s.copying[path] {
+				return fmt.Errorf("symbolic link %v points to a directory containing it", rel)
+			}
+			if len(s.only) == 0 {
+				if err := os.MkdirAll(target, 0755); err != nil {
+					return err
+				}
+			}
+			return s.copy(path, target)
+		case fi.IsDir():
+			return os.MkdirAll(target, 0755)
+		case fi.Mode()&os.ModeSymlink != 0:
+			return copySymlink(path, target)
+		case !fi.Mode().IsRegular():
+			return nil
+		case rendered:
+			err = renderFile(path, target, fi.Mode().Perm(), s.values)
+		case compiled:
+			err = compileRecords(path, target, fi.Mode().Perm())
+		case encrypted != "":
+			err = s.decrypt(encrypted, path, target, fi.Mode().Perm())
+		case s.includes != "":
+			err = flattenFile(path, target, s.includes, fi.Mode().Perm())
+		default:
+			err = copyFile(path, target, fi.Mode().Perm())
+		}
+		if err == nil && s.eol {
+			err = normalizeEOL(target)
+		}
+		return err
+	})
+}
+
+// mark keeps target, and its parent directories, which it creates. The
+// ones of another type than they were are removed first.
+func (s *syncer) mark(target string, dir bool) error {
+	// a directory replaced by a file or the other way around
+	if existing, err := os.Lstat(target); err == nil && existing.IsDir() != dir {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+	}
+	s.keep[target] = true
+	for p := filepath.Dir(target); !s.keep[p]; p = filepath.Dir(p) {
+		if existing, err := os.Lstat(p); err == nil && !existing.IsDir() {
+			if err := os.RemoveAll(p); err != nil {
+				return err
+			}
+		}
+		s.keep[p] = true
+	}
+	return os.MkdirAll(filepath.Dir(target), 0755)
+}
+
+// matchGlobs reports whether the slash separated path name, or its base
+// name, matches one of globs, or there are none.
+func matchGlobs(globs []string, name string) bool {
+	if len(globs) == 0 {
+		return true
+	}
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(glob, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// removeStale removes the files and directories of dst not in keep.
+func removeStale(dst string, keep map[string]bool) error {
+	// remove what is no longer kept, deepest paths first
+	var stale []string
+	err := filepath.Walk(dst, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !keep[path] {
+			stale = append(stale, path)
+			if fi.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for i := len(stale) - 1; i >= 0; i-- {
+		if err := os.RemoveAll(stale[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFile atomically replaces dst with a copy of src with permissions perm.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return replaceFile(in, dst, perm)
+}
+
+// replaceFile atomically replaces dst with the content of in with
+// permissions perm.
+func replaceFile(in io.Reader, dst string, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// copySymlink atomically replaces dst with a symbolic link to the target of src.
+func copySymlink(src, dst string) error {
+	target, err := os.Readlink(src)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+".tmplink")
+	os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// validMappingSource reports whether from is a relative path inside the repository.
+func validMappingSource(from string) bool {
+	from = filepath.Clean(from)
+	return !filepath.IsAbs(from) && filepath.VolumeName(from) == "" && !os.IsPathSeparator(from[0]) && from != ".." && !strings.HasPrefix(from, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,96 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSyncDir(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "zones")
+	writeFiles(t, src, map[string]string{
+		"db.example.org":     "example.org",
+		"sub/db.example.net": "example.net",
+		".git/config":        "[core]",
+	})
+	if err := syncDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(filepath.Join(src, "db.example.org"))
+	os.RemoveAll(filepath.Join(src, "sub"))
+	writeFiles(t, src, map[string]string{"sub": "now a file", "db.example.com": "example.com"})
+	if err := syncDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{"sub": "now a file", "db.example.com": "example.com"}
+	found := map[string]string{}
+	filepath.Walk(dst, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && !fi.IsDir() {
+			b, _ := os.ReadFile(path)
+			rel, _ := filepath.Rel(dst, path)
+			found[rel] = string(b)
+		}
+		return err
+	})
+	if len(found) != len(expected) {
+		t.Fatalf("Expected files %v, found %v", expected, found)
+	}
+	for name, content := range expected {
+		if found[name] != content {
+			t.Errorf("Expected %v to hold %q, found %q", name, content, found[name])
+		}
+	}
+}
+
+func TestValidMappingSource(t *testing.T) {
+	for from, valid := range map[string]bool{
+		"zones":        true,
+		"zones/prod":   true,
+		".":            true,
+		"..":           false,
+		"../zones":     false,
+		"zones/../..":  false,
+		"/etc/zones":   false,
+		"..zones/prod": true,
+	} {
+		if validMappingSource(from) != valid {
+			t.Errorf("Expected %q valid to be %v", from, valid)
+		}
+	}
+}
+
+func TestSyncTreeOnly(t *testing.T) {
+	src, dst := t.TempDir(), filepath.Join(t.TempDir(), "zones")
+	writeFiles(t, src, map[string]string{
+		"db.example.org":         "example.org",
+		"prod/example.net.zone":  "example.net",
+		"scripts/deploy.sh":      "#!/bin/sh",
+		".github/workflows/ci":   "on: push",
+		"README.md":              "zones",
+		"prod/notes/db.internal": "internal",
+	})
+	writeFiles(t, dst, map[string]string{"scripts": "stale file", "README.md": "stale"})
+	if err := syncTree(src, dst, syncOptions{only: []string{"*.zone", "db.*"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"db.example.org", "prod", "prod/example.net.zone", "prod/notes", "prod/notes/db.internal"}
+	var found []string
+	filepath.Walk(dst, func(path string, fi os.FileInfo, err error) error {
+		if err == nil && path != dst {
+			rel, _ := filepath.Rel(dst, path)
+			found = append(found, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %v, found %v", expected, found)
+	}
+	for i := range expected {
+		if found[i] != expected[i] {
+			t.Errorf("Expected %v, found %v", expected[i], found[i])
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package git
+
+import (
+	"context"
+	"fmt"
+)
+
+// pushBranch returns the branch local changes are pushed to.
+func (r *Repo) pushBranch() string {
+	if r.PushBranch != "" {
+		return r.PushBranch
+	}
+	return r.Branch
+}
+
+// commitLocal commits the changes made to the checkout at dir since the
+// last pull, if any, for pushLocal to push them once the pull merged them
+// with the remote ones.
+func (r *Repo) commitLocal(ctx context.Context, dir string) error {
+	status, err := r.gitOutput(ctx, []string{"status", "--porcelain"}, dir)
+	if err != nil {
+		return fmt.Errorf("cannot list the local changes of %v: %s", r, err)
+	}
+	if status == "" {
+		return nil
+	}
+	if err := r.gitCmd(ctx, []string{"add", "--all"}, dir); err != nil {
+		return err
+	}
+	msg, err := r.commitMessage(messageLocal, commitData{Changes: status})
+	if err != nil {
+		return err
+	}
+	if err := r.gitCmd(ctx, []string{"commit", "--quiet", "-m", msg}, dir); err != nil {
+		return err
+	}
+	r.unpushed = true
+	log.Infof("committed local changes of %v:\n%s", r, status)
+	return nil
+}
+
+// pushLocal pushes the local changes committed by commitLocal.
+func (r *Repo) pushLocal(ctx context.Context, dir string) error {
+	if !r.unpushed {
+		return nil
+	}
+	if err := r.gitCmd(ctx, []string{"push", "origin", "HEAD:refs/heads/" + r.pushBranch()}, dir); err != nil {
+		return fmt.Errorf("cannot push the local changes of %v: %s", r, err)
+	}
+	// pushed again with the next pull if the request can't be opened now
+	if r.PullRequest {
+		if err := r.openPullRequest(ctx); err != nil {
+			return err
+		}
+	}
+	r.unpushed = false
+	log.Infof("pushed local changes of %v to %v", r, r.pushBranch())
+	return nil
+}
+
+// committed pushes the commit just made in the checkout at dir, or leaves
+// it to the next pull if it can't be pushed now, then makes it the commit
+// checked out and publishes it.
+func (r *Repo) committed(ctx context.Context, dir string) error {
+	r.unpushed = true
+	if err := r.pushLocal(ctx, dir); err != nil {
+		log.Warning(redactError(err))
+	}
+	commit, err := r.mostRecentCommit(ctx)
+	if err != nil {
+		return redactError(err)
+	}
+	r.prevCommit, r.lastCommit = r.lastCommit, commit
+	r.commit.Store(commit)
+	updateRepoInfo(r)
+	return r.publish()
+}
+
+// abortMerge aborts the merge of the local changes with the pulled ones
+// after a conflict, so the files served never hold conflict markers.
+func (r *Repo) abortMerge(ctx context.Context, dir string) {
+	if !r.unpushed {
+		return
+	}
+	if err := r.gitCmd(ctx, []string{"merge", "--abort"}, dir); err != nil {
+		log.Debugf("cannot abort the merge of %v: %s", r, err)
+	}
+}
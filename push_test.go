@@ -0,0 +1,81 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPushLocal(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	work := newOrigin(t, map[string]string{"db.example.org": "v1", "db.example.net": "v1"})
+	origin := filepath.Join(dir, "origin.git")
+	runGit(t, dir, "clone", "-q", "--bare", work, origin)
+	runGit(t, work, "remote", "add", "origin", origin)
+
+	repo := &Repo{URL: origin, Path: filepath.Join(dir, "zones"), Branch: "master", Push: true}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// changed both locally and upstream
+	writeFiles(t, repo.Path, map[string]string{"db.example.org": "local", "db.example.com": "local"})
+	writeFiles(t, work, map[string]string{"db.example.net": "v2"})
+	runGit(t, work, "commit", "-q", "-am", "v2")
+	runGit(t, work, "push", "-q", "origin", "master")
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{"db.example.org": "local", "db.example.com": "local", "db.example.net": "v2"} {
+		if b, _ := os.ReadFile(filepath.Join(repo.Path, name)); string(b) != expected {
+			t.Errorf("Expected checked out %v to be %q, found %q", name, expected, b)
+		}
+		out, err := exec.Command("git", "--git-dir", origin, "show", "master:"+name).Output()
+		if err != nil || string(out) != expected {
+			t.Errorf("Expected pushed %v to be %q, found %q (%v)", name, expected, out, err)
+		}
+	}
+	out, _ := exec.Command("git", "--git-dir", origin, "log", "--format=%an%n%B", "-n", "1", "master^1").Output()
+	if !strings.HasPrefix(string(out), "CoreDNS\nCommit local changes of") || !strings.Contains(string(out), "db.example.com") {
+		t.Errorf("Expected a commit of the local changes, found %q", out)
+	}
+	if repo.unpushed {
+		t.Error("Expected the local changes to be pushed")
+	}
+}
+
+func TestPushSigned(t *testing.T) {
+	for _, command := range []string{"git", "ssh-keygen"} {
+		if _, err := exec.LookPath(command); err != nil {
+			t.Skipf("%s is not installed", command)
+		}
+	}
+	dir := t.TempDir()
+	key := filepath.Join(dir, "signing")
+	if out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen: %v: %s", err, out)
+	}
+	work := newOrigin(t, map[string]string{"db.example.org": "v1"})
+	origin := filepath.Join(dir, "origin.git")
+	runGit(t, dir, "clone", "-q", "--bare", work, origin)
+
+	repo := &Repo{URL: origin, Path: filepath.Join(dir, "zones"), Branch: "master", Push: true, SigningKey: key, SignFormat: signSSH}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, repo.Path, map[string]string{"db.example.org": "local"})
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := exec.Command("git", "--git-dir", origin, "cat-file", "-p", "master").Output()
+	if !strings.Contains(string(out), "gpgsig -----BEGIN SSH SIGNATURE-----") {
+		t.Errorf("Expected a signed commit, found %q", out)
+	}
+}
@@ -0,0 +1,130 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errQuota is the error of pulls which made a checkout larger than its
+// MaxSize. They are not retried.
+var errQuota = errors.New("over its max_size")
+
+// quotaPoll is how often the size of a checkout is measured while it is
+// cloned or pulled.
+var quotaPoll = time.Second
+
+// parseSize parses a number of bytes with an optional K, M, G or T suffix
+// for binary multiples, e.g. 512M.
+func parseSize(s string) (int64, error) {
+	shift := 0
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGT", s[n-1]&^0x20); i >= 0 {
+			shift, s = 10*(i+1), s[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 || n > (1<<63-1)>>shift {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return n << shift, nil
+}
+
+// dirSize returns the number of bytes of the regular files under dir,
+// git metadata included.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// files come and go while git runs
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// watchQuota returns a context derived from ctx which is canceled once
+// the checkout at dir grows over MaxSize, aborting the git processes
+// filling it, and a function to call once they are done, which returns
+// an error if they were aborted.
+func (r *Repo) watchQuota(ctx context.Context, dir string) (context.Context, func() error) {
+	if r.MaxSize == 0 {
+		return ctx, func() error { return nil }
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	done, exited, over := make(chan struct{}), make(chan struct{}), make(chan int64, 1)
+	ticker := time.NewTicker(quotaPoll)
+	go func() {
+		defer close(exited)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if size, err := dirSize(dir); err == nil && size > r.MaxSize {
+					over <- size
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+	return ctx, func() error {
+		close(done)
+		// the size measured as the processes complete counts too
+		<-exited
+		cancel()
+		select {
+		case size := <-over:
+			return fmt.Errorf("aborted pulling %v: checkout grew to %d bytes, %w of %d", r, size, errQuota, r.MaxSize)
+		default:
+			return nil
+		}
+	}
+}
+
+// checkQuota returns an error if the checkout at dir is larger than
+// MaxSize.
+func (r *Repo) checkQuota(dir string) error {
+	if r.MaxSize == 0 {
+		return nil
+	}
+	size, err := dirSize(dir)
+	if err != nil {
+		return fmt.Errorf("cannot measure the checkout of %v: %s", r, err)
+	}
+	checkoutSize.WithLabelValues(r.String()).Set(float64(size))
+	if size > r.MaxSize {
+		return fmt.Errorf("checkout of %v is %d bytes, %w of %d", r, size, errQuota, r.MaxSize)
+	}
+	return nil
+}
+
+// clearDir removes the content of dir, e.g. a clone aborted midway.
+func clearDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := map[string]int64{
+		"1024": 1024,
+		"512K": 512 << 10,
+		"10m":  10 << 20,
+		"2G":   2 << 30,
+		"1T":   1 << 40,
+		"":     0,
+		"M":    0,
+		"0":    0,
+		"-1K":  0,
+		"1.5G": 0,
+		"10MB": 0,
+	}
+	for s, expected := range tests {
+		size, err := parseSize(s)
+		if expected == 0 && err == nil {
+			t.Errorf("Expected an error parsing %q, found %d", s, size)
+		}
+		if expected != 0 && size != expected {
+			t.Errorf("Expected %q to be %d bytes, found %d (%v)", s, expected, size, err)
+		}
+	}
+}
+
+func TestWatchQuota(t *testing.T) {
+	defer func(poll time.Duration) { quotaPoll = poll }(quotaPoll)
+	quotaPoll = 10 * time.Millisecond
+
+	dir := t.TempDir()
+	repo := &Repo{URL: "https://github.com/user/zones", MaxSize: 1024}
+	ctx, quota := repo.watchQuota(context.Background(), dir)
+	writeFiles(t, dir, map[string]string{"db.example.org": strings.Repeat("x", 2048)})
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the pull to be aborted")
+	}
+	if err := quota(); !errors.Is(err, errQuota) {
+		t.Errorf("Expected a quota error, found %v", err)
+	}
+
+	ctx, quota = repo.watchQuota(context.Background(), t.TempDir())
+	if err := quota(); err != nil {
+		t.Errorf("Expected no error, found %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Error("Expected the context to be canceled once done")
+	}
+}
+
+func TestQuotaPull(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	src := newOrigin(t, map[string]string{"db.example.org": strings.Repeat("x", 64<<10)})
+
+	repo := &Repo{URL: src, Path: filepath.Join(dir, "zones"), Branch: "master", MaxSize: 32 << 10}
+	if err := os.MkdirAll(repo.Path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.pull(context.Background()); !errors.Is(err, errQuota) {
+		t.Fatalf("Expected a quota error, found %v", err)
+	}
+	if entries, _ := os.ReadDir(repo.Path); len(entries) != 0 || repo.pulled {
+		t.Errorf("Expected the clone over the quota to be removed, found %v", entries)
+	}
+
+	repo.MaxSize = 1 << 20
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+
+	// a checkout growing past the quota fails the pull but is kept
+	writeFiles(t, src, map[string]string{"db.example.org": strings.Repeat("y", 2<<20)})
+	runGit(t, src, "commit", "-q", "-am", "v2")
+	if err := repo.pull(context.Background()); !errors.Is(err, errQuota) {
+		t.Fatalf("Expected a quota error, found %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo.Path, ".git")); err != nil || !repo.pulled {
+		t.Errorf("Expected the checkout to be kept, found %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// backendRaw is the backend downloading only the Files of repositories,
+// as raw files of the commit their branch points to.
+const backendRaw = "raw"
+
+// rawBackend checks the Files of repositories out one by one over HTTPS.
+// Checkouts are recorded as those of archiveBackend.
+type rawBackend struct{ archiveBackend }
+
+// Clone implements Backend.
+func (b rawBackend) Clone(ctx context.Context, r *Repo, dir string) error {
+	return b.Pull(ctx, r, dir)
+}
+
+// Pull implements Backend. The files are only downloaded if the branch
+// points to another commit than the checked out one.
+func (rawBackend) Pull(ctx context.Context, r *Repo, dir string) error {
+	commit, err := archiveRef(ctx, r)
+	if err != nil {
+		return err
+	}
+	if head, _ := os.ReadFile(filepath.Join(dir, archiveMeta, "commit")); string(head) == commit {
+		return nil
+	}
+	return rawCheckout(ctx, r, dir, commit)
+}
+
+// Reset implements Backend.
+func (rawBackend) Reset(ctx context.Context, r *Repo, dir, commit string) error {
+	return rawCheckout(ctx, r, dir, commit)
+}
+
+// validRaw checks the raw backend can pull r.
+func validRaw(r *Repo) error {
+	if err := validArchive(r); err != nil {
+		return err
+	}
+	if len(r.Files) == 0 {
+		return fmt.Errorf("the raw backend needs files to download")
+	}
+	return nil
+}
+
+// rawURL returns the URL of the raw file name of commit: on
+// raw.githubusercontent.com for github.com, GitLab's for hosts named so,
+// and Gitea's otherwise.
+func rawURL(r *Repo, commit, name string) (*url.URL, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return nil, err
+	}
+	repo := strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".git")
+	switch {
+	case u.Hostname() == "github.com":
+		u.Host = "raw.githubusercontent.com"
+		u.Path = path.Join(repo, commit, name)
+	case strings.Contains(u.Hostname(), "gitlab"):
+		u.Path = path.Join(repo, "-/raw", commit, name)
+	default:
+		u.Path = path.Join(repo, "raw/commit", commit, name)
+	}
+	return u, nil
+}
+
+// rawCheckout downloads the Files of commit and makes dir a copy of them,
+// replacing each file atomically.
+func rawCheckout(ctx context.Context, r *Repo, dir, commit string) error {
+	return downloadCheckout(r, dir, commit, func(tmp string) error {
+		for _, name := range r.Files {
+			u, err := rawURL(r, commit, name)
+			if err != nil {
+				return err
+			}
+			resp, err := httpGet(ctx, r, u)
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(tmp, filepath.FromSlash(name))
+			if err = os.MkdirAll(filepath.Dir(target), 0755); err == nil {
+				err = writeFile(resp.Body, target, 0644)
+			}
+			resp.Body.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
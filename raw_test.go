@@ -0,0 +1,84 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRawPull(t *testing.T) {
+	commit := strings.Repeat("a", 40)
+	first := commit
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/user/zones.git/info/refs":
+			fmt.Fprint(w, pktLine("# service=git-upload-pack\n")+"0000"+
+				pktLine(commit+" HEAD\x00multi_ack\n")+pktLine(commit+" refs/heads/master\n")+"0000")
+		case "/user/zones/raw/commit/" + first + "/db.example.org":
+			fmt.Fprint(w, "v1")
+		case "/user/zones/raw/commit/" + first + "/sub/db.example.net":
+			fmt.Fprint(w, "v2")
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+	archiveClient = srv.Client()
+	defer func() { archiveClient = http.DefaultClient }()
+
+	repo := &Repo{URL: srv.URL + "/user/zones.git", Path: filepath.Join(t.TempDir(), "zones"), Branch: "master", Backend: backendRaw,
+		Files: []string{"db.example.org", "sub/db.example.net"}}
+	if err := validRaw(repo); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if repo.lastCommit != commit {
+		t.Errorf("Expected commit %v, found %v", commit, repo.lastCommit)
+	}
+	for name, content := range map[string]string{"db.example.org": "v1", "sub/db.example.net": "v2"} {
+		if b, _ := os.ReadFile(filepath.Join(repo.Path, name)); string(b) != content {
+			t.Errorf("Expected %v content %v, found %q", name, content, b)
+		}
+	}
+
+	// a file missing from the new commit fails the pull, leaving the
+	// checkout untouched
+	commit = strings.Repeat("b", 40)
+	if err := repo.pull(context.Background()); err == nil {
+		t.Error("Expected missing file to fail")
+	}
+	if b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org")); string(b) != "v1" {
+		t.Errorf("Expected checkout to be left untouched, found %q", b)
+	}
+}
+
+func TestRawURL(t *testing.T) {
+	commit := "abc"
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"https://github.com/user/zones.git", "https://raw.githubusercontent.com/user/zones/abc/dir/db.example.org"},
+		{"https://gitlab.example.com/group/zones", "https://gitlab.example.com/group/zones/-/raw/abc/dir/db.example.org"},
+		{"https://git.example.com/user/zones.git", "https://git.example.com/user/zones/raw/commit/abc/dir/db.example.org"},
+	}
+	for i, test := range tests {
+		u, err := rawURL(&Repo{URL: test.url}, commit, "dir/db.example.org")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.String() != test.expected {
+			t.Errorf("Test %d: expected %v, found %v", i, test.expected, u)
+		}
+	}
+}
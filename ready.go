@@ -0,0 +1,14 @@
+package git
+
+// Ready implements the ready.Readiness interface. It reports ready once
+// every repository without skip_ready completed its first clone or pull.
+// Health isn't reported, as the ready plugin no longer queries a plugin
+// once it is ready.
+func (h Handler) Ready() bool {
+	for _, r := range h.Repos {
+		if !r.SkipReady && r.LastPull().IsZero() {
+			return false
+		}
+	}
+	return true
+}
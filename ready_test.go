@@ -0,0 +1,18 @@
+package git
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReady(t *testing.T) {
+	pulled, waiting, skipped := &Repo{}, &Repo{}, &Repo{SkipReady: true}
+	pulled.setLastPull(time.Now())
+
+	if h := (Handler{Repos: Git{pulled, skipped}}); !h.Ready() {
+		t.Errorf("Expected ready with pulled and skipped repos")
+	}
+	if h := (Handler{Repos: Git{pulled, waiting}}); h.Ready() {
+		t.Errorf("Expected not ready while a repo was never pulled")
+	}
+}
@@ -0,0 +1,99 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+	"gopkg.in/yaml.v3"
+)
+
+// recordsSuffixes are the suffixes of the files of records compiled into
+// zone files with Records.
+var recordsSuffixes = []string{".records.yaml", ".records.yml", ".records.json"}
+
+// recordsTTL is the TTL of the records of files setting none.
+const recordsTTL = 3600
+
+// recordsFile is a zone described as a list of records.
+type recordsFile struct {
+	Origin  string         `yaml:"origin"`
+	TTL     uint32         `yaml:"ttl"`
+	Records []recordsEntry `yaml:"records"`
+}
+
+// recordsEntry is one or more records of a name and type.
+type recordsEntry struct {
+	Name   string   `yaml:"name"`
+	Type   string   `yaml:"type"`
+	TTL    uint32   `yaml:"ttl"`
+	Value  string   `yaml:"value"`
+	Values []string `yaml:"values"`
+}
+
+// recordsTarget returns the zone file the file of records at path is
+// compiled into, path without its suffix, and whether it is one.
+func recordsTarget(path string) (string, bool) {
+	for _, suffix := range recordsSuffixes {
+		if strings.HasSuffix(path, suffix) && !strings.HasSuffix(path, string(os.PathSeparator)+suffix) {
+			return strings.TrimSuffix(path, suffix), true
+		}
+	}
+	return path, false
+}
+
+// compileRecords atomically replaces dst with the zone file of the records
+// of the YAML or JSON file at src, with permissions perm. Every record is
+// parsed, so an invalid one fails the pull rather than the zone.
+func compileRecords(src, dst string, perm os.FileMode) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	var f recordsFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return fmt.Errorf("cannot parse %v: %s", src, err)
+	}
+	if f.Origin == "" {
+		return fmt.Errorf("no origin in %v", src)
+	}
+	origin := dns.Fqdn(f.Origin)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "$ORIGIN %s\n", origin)
+	for i, e := range f.Records {
+		values := e.Values
+		if e.Value != "" {
+			values = append([]string{e.Value}, values...)
+		}
+		if e.Type == "" || len(values) == 0 {
+			return fmt.Errorf("record %d of %v has no type or value", i, src)
+		}
+		name, ttl := e.Name, e.TTL
+		if name == "" {
+			name = "@"
+		}
+		if ttl == 0 {
+			ttl = f.TTL
+		}
+		if ttl == 0 {
+			ttl = recordsTTL
+		}
+		for _, v := range values {
+			line := name + " " + strconv.FormatUint(uint64(ttl), 10) + " IN " + e.Type + " " + v
+			zp := dns.NewZoneParser(strings.NewReader(line), origin, src)
+			rr, ok := zp.Next()
+			if err := zp.Err(); err != nil {
+				return fmt.Errorf("invalid record %d of %v: %s", i, src, err)
+			}
+			if !ok {
+				return fmt.Errorf("invalid record %d of %v: %s", i, src, line)
+			}
+			buf.WriteString(rr.String() + "\n")
+		}
+	}
+	return replaceFile(&buf, dst, perm)
+}
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishRecords(t *testing.T) {
+	dir := t.TempDir()
+	repo := &Repo{Path: filepath.Join(dir, "zones"), Subpath: "dns", Records: true}
+	repo.lastCommit = "c1"
+	writeFiles(t, repo.workDir(), map[string]string{
+		"dns/db.example.org.records.yaml": `origin: example.org
+ttl: 3600
+records:
+  - type: SOA
+    value: ns1 hostmaster 1 7200 3600 1209600 3600
+  - type: NS
+    values: [ns1, ns2.example.net.]
+  - name: www
+    type: A
+    ttl: 300
+    values: [192.0.2.1, 192.0.2.2]
+  - name: txt
+    type: TXT
+    value: '"v=spf1 -all"'
+`,
+		"dns/db.example.net.records.json": `{"origin": "example.net.", "records": [{"name": "@", "type": "AAAA", "value": "2001:db8::1"}]}`,
+	})
+	if err := repo.publish(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"db.example.org": "$ORIGIN example.org.\n" +
+			"example.org.\t3600\tIN\tSOA\tns1.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600\n" +
+			"example.org.\t3600\tIN\tNS\tns1.example.org.\n" +
+			"example.org.\t3600\tIN\tNS\tns2.example.net.\n" +
+			"www.example.org.\t300\tIN\tA\t192.0.2.1\n" +
+			"www.example.org.\t300\tIN\tA\t192.0.2.2\n" +
+			"txt.example.org.\t3600\tIN\tTXT\t\"v=spf1 -all\"\n",
+		"db.example.net": "$ORIGIN example.net.\n" +
+			"example.net.\t3600\tIN\tAAAA\t2001:db8::1\n",
+	}
+	for name, content := range expected {
+		if b, _ := os.ReadFile(filepath.Join(repo.Path, name)); string(b) != content {
+			t.Errorf("Expected %v to hold %q, found %q", name, content, b)
+		}
+	}
+	if entries, _ := os.ReadDir(repo.Path); len(entries) != len(expected) {
+		t.Errorf("Expected only the zone files to be published, found %v", entries)
+	}
+
+	// an invalid record fails the publication
+	writeFiles(t, repo.workDir(), map[string]string{"dns/db.example.net.records.json": `{"origin": "example.net.", "records": [{"type": "A", "value": "not an address"}]}`})
+	repo.lastCommit = "c2"
+	if err := repo.publish(); err == nil || !strings.Contains(err.Error(), "record 0") {
+		t.Errorf("Expected an invalid record to fail, found %v", err)
+	}
+}
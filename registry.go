@@ -0,0 +1,201 @@
+package git
+
+import (
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// registry holds every repository started by the plugin.
+var registry = &repos{}
+
+// repos is a concurrency safe list of repositories. A repository may be
+// added several times, e.g. by the old and new instances of a reload, and
+// stays in the list until it is removed as many times.
+type repos struct {
+	repos []*Repo
+	refs  map[*Repo]int
+	sync.RWMutex
+}
+
+// add adds a reference to r, adding it to the list of repositories if
+// it is not in it yet. It reports whether r was added to the list.
+func (rs *repos) add(r *Repo) bool {
+	rs.Lock()
+	defer rs.Unlock()
+
+	if rs.refs == nil {
+		rs.refs = map[*Repo]int{}
+	}
+	if rs.refs[r]++; rs.refs[r] > 1 {
+		return false
+	}
+	rs.repos = append(rs.repos, r)
+	return true
+}
+
+// remove removes a reference to r, removing it from the list of
+// repositories once it is no longer referenced. It reports whether r was
+// removed from the list.
+func (rs *repos) remove(r *Repo) bool {
+	rs.Lock()
+	defer rs.Unlock()
+
+	if rs.refs[r]--; rs.refs[r] > 0 {
+		return false
+	}
+	delete(rs.refs, r)
+	for i := range rs.repos {
+		if rs.repos[i] == r {
+			rs.repos = append(rs.repos[:i], rs.repos[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// all returns a copy of the list of repositories.
+func (rs *repos) all() []*Repo {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	return append([]*Repo(nil), rs.repos...)
+}
+
+// RepoForPath returns the running repository writing path, its checkout
+// or a directory it publishes to, or a file or directory inside them, or
+// nil if there is none. It lets other plugins of the server find the
+// repository of the files they serve, e.g. to pull it with TriggerPull.
+func RepoForPath(path string) *Repo {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	for _, r := range registry.all() {
+		for _, p := range r.paths() {
+			if inside(path, p) {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// RepoInfo is a snapshot of a running repository.
+type RepoInfo struct {
+	Config RepoConfig // Configuration of the repository
+	Status RepoStatus // Status of the repository
+}
+
+// RepoConfig is a copy of the configuration of a running repository,
+// without its credentials.
+type RepoConfig struct {
+	Name       string        // Name identifying the repository
+	URL        string        // Repository URL, credentials redacted
+	Mirrors    []string      // Other URLs of the repository, credentials redacted
+	Path       string        // Directory the repository is pulled to
+	Branch     string        // Branch, tag or {latest} pulled
+	Subpath    string        // Only directory of the repository published at Path
+	Maps       []Mapping     // Subdirectories published elsewhere
+	Backend    string        // Name of the backend pulling the repository, empty for exec
+	Interval   time.Duration // Interval between pulls
+	Follow     bool          // Only read the checkout another process updates
+	DryRun     bool          // Only log what pulls would change
+	Push       bool          // Commit the local changes of the checkout and push them
+	PushBranch string        // Branch local changes are pushed to, Branch if empty
+}
+
+// Repos returns a snapshot of the running repositories of every server,
+// so programs embedding CoreDNS can enumerate them without parsing the
+// Corefile. The snapshots can't pull nor change the repositories, and
+// don't hold their credentials. It does not block on running pulls.
+func Repos() []RepoInfo {
+	var infos []RepoInfo
+	for _, r := range registry.all() {
+		infos = append(infos, RepoInfo{Config: r.config(), Status: r.status()})
+	}
+	return infos
+}
+
+// config returns a copy of the configuration of r, without credentials.
+func (r *Repo) config() RepoConfig {
+	c := RepoConfig{
+		Name:       r.Name,
+		URL:        redact(r.URL),
+		Path:       r.Path,
+		Branch:     r.Branch,
+		Subpath:    r.Subpath,
+		Maps:       append([]Mapping(nil), r.Maps...),
+		Backend:    r.Backend,
+		Interval:   r.Interval,
+		Follow:     r.Follow,
+		DryRun:     r.DryRun,
+		Push:       r.Push,
+		PushBranch: r.PushBranch,
+	}
+	for _, u := range r.Mirrors {
+		c.Mirrors = append(c.Mirrors, redact(u))
+	}
+	return c
+}
+
+// running returns the repository of the list configured as r, if any, so
+// a reload keeps the repositories it did not change running.
+func (rs *repos) running(r *Repo) *Repo {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	for _, other := range rs.repos {
+		if sameConfig(other, r) {
+			return other
+		}
+	}
+	return nil
+}
+
+// checkout returns the repository of the list with the same checkout as
+// r, if any, so a reload only changing other settings reuses it.
+func (rs *repos) checkout(r *Repo) *Repo {
+	rs.RLock()
+	defer rs.RUnlock()
+
+	for _, other := range rs.repos {
+		if sameCheckout(other, r) {
+			return other
+		}
+	}
+	return nil
+}
+
+// sameCheckout reports whether a and b clone the same branch of the same
+// repository, in the same way, with the same backend and owner, and
+// publish it at the same places.
+func sameCheckout(a, b *Repo) bool {
+	return a.URL == b.URL && a.Path == b.Path && a.Branch == b.Branch && a.Subpath == b.Subpath && a.Overlay == b.Overlay &&
+		a.DryRun == b.DryRun && reflect.DeepEqual(a.CloneArgs, b.CloneArgs) && reflect.DeepEqual(a.Maps, b.Maps) &&
+		a.InMemory == b.InMemory && reflect.DeepEqual(a.Export, b.Export) &&
+		a.Backend == b.Backend && a.Depth == b.Depth && reflect.DeepEqual(a.RunAs, b.RunAs) && a.Follow == b.Follow
+}
+
+// samePublish reports whether a and b publish their checkout the same way.
+func samePublish(a, b *Repo) bool {
+	return a.Symlinks == b.Symlinks && reflect.DeepEqual(a.PublishOnly, b.PublishOnly) &&
+		a.Render == b.Render && a.Values == b.Values && reflect.DeepEqual(a.Vars, b.Vars) && a.Records == b.Records &&
+		a.Includes == b.Includes && reflect.DeepEqual(a.Assemblies, b.Assemblies) &&
+		a.Decrypt == b.Decrypt && a.DecryptKey == b.DecryptKey && a.EOL == b.EOL
+}
+
+// sameConfig reports whether a and b have the same configuration, their
+// exported fields.
+func sameConfig(a, b *Repo) bool {
+	av, bv := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	for i := 0; i < av.NumField(); i++ {
+		if f := av.Type().Field(i); f.IsExported() && !f.Anonymous {
+			if !reflect.DeepEqual(av.Field(i).Interface(), bv.Field(i).Interface()) {
+				return false
+			}
+		}
+	}
+	return true
+}
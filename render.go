@@ -0,0 +1,59 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// renderSuffix is the suffix of the templates rendered with Render.
+const renderSuffix = ".tmpl"
+
+// renderValues returns the values the templates of the checkout are
+// rendered with: the ones of the Values file of the repository,
+// overridden by Vars.
+func (r *Repo) renderValues() (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if r.Values != "" {
+		b, err := os.ReadFile(filepath.Join(r.workDir(), r.Values))
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(b, &values); err != nil {
+			return nil, fmt.Errorf("cannot parse %v: %s", r.Values, err)
+		}
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+	}
+	for _, v := range r.Vars {
+		name, value, _ := strings.Cut(v, "=")
+		values[name] = value
+	}
+	return values, nil
+}
+
+// renderFile atomically replaces dst with the Go template at src rendered
+// with values, with permissions perm. The env function of the template
+// returns the environment variables of CoreDNS, and referencing a missing
+// value is an error.
+func renderFile(src, dst string, perm os.FileMode, values map[string]interface{}) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	t, err := template.New(filepath.Base(src)).Funcs(template.FuncMap{"env": os.Getenv}).Option("missingkey=error").Parse(string(b))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, values); err != nil {
+		return err
+	}
+	return replaceFile(&buf, dst, perm)
+}
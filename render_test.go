@@ -0,0 +1,49 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishRender(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("COREDNS_GIT_TEST_TTL", "300")
+	repo := &Repo{
+		Path:   filepath.Join(dir, "zones"),
+		Maps:   []Mapping{{From: "zones", To: filepath.Join(dir, "prod")}},
+		Render: true,
+		Values: filepath.Join("values", "prod.yaml"),
+		Vars:   []string{"ns=ns2.example.org."},
+	}
+	repo.lastCommit = "c1"
+	writeFiles(t, repo.Path, map[string]string{
+		"values/prod.yaml":            "ip: 192.0.2.1\nns: ns1.example.org.\n",
+		"zones/db.example.org.tmpl":   "@ {{ env \"COREDNS_GIT_TEST_TTL\" }} IN NS {{ .ns }}\nwww IN A {{ .ip }}\n",
+		"zones/db.example.net":        "{{ .ip }}",
+		"zones/sub/example.zone.tmpl": "{{ .ip }}",
+	})
+	if err := repo.publish(); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"db.example.org":   "@ 300 IN NS ns2.example.org.\nwww IN A 192.0.2.1\n",
+		"db.example.net":   "{{ .ip }}",
+		"sub/example.zone": "192.0.2.1",
+	} {
+		if b, _ := os.ReadFile(filepath.Join(dir, "prod", name)); string(b) != content {
+			t.Errorf("Expected %v to hold %q, found %q", name, content, b)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "prod", "db.example.org.tmpl")); !os.IsNotExist(err) {
+		t.Errorf("Expected the template not to be published, found %v", err)
+	}
+
+	// a missing value fails the publication
+	writeFiles(t, repo.Path, map[string]string{"zones/db.example.org.tmpl": "{{ .missing }}"})
+	repo.lastCommit = "c2"
+	if err := repo.publish(); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected a missing value to fail, found %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package git
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// resolveTimeout bounds the resolution of the hosts of the remotes.
+const resolveTimeout = 10 * time.Second
+
+// resolveRemotes resolves the hosts of the remotes of r with the DNS
+// server Resolver, for git not to depend on the resolver of the host, which
+// may be this server. A host which can't be resolved keeps the address it
+// resolved to before, if any.
+func (r *Repo) resolveRemotes(ctx context.Context) {
+	if r.Resolver == "" {
+		return
+	}
+	res := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, r.Resolver)
+		},
+	}
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	for _, remote := range append([]string{r.URL}, r.Mirrors...) {
+		_, host := remoteOf(remote)
+		if _, ok := r.address(host); ok || host == "" || net.ParseIP(host) != nil {
+			continue
+		}
+		addrs, err := res.LookupHost(ctx, host)
+		if err != nil || len(addrs) == 0 {
+			log.Warningf("cannot resolve %s with %s, keeping its last address: %s", host, r.Resolver, err)
+			continue
+		}
+		if r.resolved == nil {
+			r.resolved = map[string]string{}
+		}
+		r.resolved[host] = addrs[0]
+	}
+}
+
+// address returns the address of host given in Addresses, if any.
+func (r *Repo) address(host string) (string, bool) {
+	for _, a := range r.Addresses {
+		if h, addr, _ := strings.Cut(a, "="); h == host {
+			return addr, true
+		}
+	}
+	return "", false
+}
+
+// pinnedAddress returns the address git connects to host at: the one of
+// Addresses, or the one host last resolved to.
+func (r *Repo) pinnedAddress(host string) (string, bool) {
+	if addr, ok := r.address(host); ok {
+		return addr, true
+	}
+	addr, ok := r.resolved[host]
+	return addr, ok
+}
+
+// pinConfig returns the configuration of curl, run by git, resolving the
+// hosts of HTTP(S) remotes to their Addresses, or the ones they resolved
+// to. ssh is configured by sshConfig.
+func (r *Repo) pinConfig() []string {
+	var config []string
+	pinned := map[string]bool{}
+	for _, remote := range append([]string{r.URL}, r.Mirrors...) {
+		scheme, host := remoteOf(remote)
+		addr, ok := r.pinnedAddress(host)
+		if !ok || scheme != "http" && scheme != "https" {
+			continue
+		}
+		u, err := url.Parse(remote)
+		if err != nil {
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443"}[scheme]
+		}
+		if pin := host + ":" + port + ":" + hostURL(addr); !pinned[pin] {
+			pinned[pin] = true
+			config = append(config, "-c", "http.curloptResolve="+pin)
+		}
+	}
+	return config
+}
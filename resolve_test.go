@@ -0,0 +1,63 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestResolveRemotes(t *testing.T) {
+	dns.HandleFunc("corp.", func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		if req.Question[0].Qtype == dns.TypeA && req.Question[0].Name != "down.corp." {
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   []byte{10, 1, 2, 3},
+			})
+		} else if req.Question[0].Name == "down.corp." {
+			m.Rcode = dns.RcodeServerFailure
+		}
+		w.WriteMsg(m)
+	})
+	defer dns.HandleRemove("corp.")
+	srv := &dns.Server{Addr: "127.0.0.1:0", Net: "udp"}
+	started := make(chan struct{})
+	srv.NotifyStartedFunc = func() { close(started) }
+	go srv.ListenAndServe()
+	<-started
+	defer srv.Shutdown()
+
+	repo := &Repo{
+		URL:      "https://git.corp/user/zones",
+		Mirrors:  []string{"git@mirror.corp:user/zones.git", "http://down.corp:8080/zones"},
+		Resolver: srv.PacketConn.LocalAddr().String(),
+	}
+	// kept when the host can't be resolved anymore
+	repo.resolved = map[string]string{"down.corp": "10.9.9.9"}
+	repo.resolveRemotes(context.Background())
+
+	params := strings.Join(repo.gitParams([]string{"pull"}), " ")
+	for _, expected := range []string{"http.curloptResolve=git.corp:443:10.1.2.3", "http.curloptResolve=down.corp:8080:10.9.9.9"} {
+		if !strings.Contains(params, expected) {
+			t.Errorf("Expected %v in %v", expected, params)
+		}
+	}
+	// ssh only connects to the address of the remote being pulled from
+	if strings.Contains(params, "sshCommand") {
+		t.Errorf("Expected no SSH pin, found %v", params)
+	}
+	// given addresses are not resolved
+	repo.Addresses = []string{"git.corp=10.7.7.7"}
+	repo.resolveRemotes(context.Background())
+	if params := strings.Join(repo.gitParams([]string{"pull"}), " "); !strings.Contains(params, "http.curloptResolve=git.corp:443:10.7.7.7") || repo.resolved["git.corp"] != "10.1.2.3" {
+		t.Errorf("Expected the given address of git.corp, found %v", params)
+	}
+
+	repo.remote = repo.Mirrors[0]
+	if params := strings.Join(repo.gitParams([]string{"pull"}), " "); !strings.Contains(params, "core.sshCommand=ssh -o HostName=10.1.2.3 -o HostKeyAlias=mirror.corp") {
+		t.Errorf("Expected an SSH pin, found %v", params)
+	}
+}
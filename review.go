@@ -0,0 +1,64 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// pullRequest is the part of a GitHub pull request or GitLab merge
+// request the plugin reads.
+type pullRequest struct {
+	HTMLURL string `json:"html_url"` // GitHub
+	WebURL  string `json:"web_url"`  // GitLab
+}
+
+// url returns the web URL of the request.
+func (p pullRequest) url() string {
+	if p.HTMLURL != "" {
+		return p.HTMLURL
+	}
+	return p.WebURL
+}
+
+// openPullRequest opens a pull request, or merge request on GitLab, of the
+// branch local changes are pushed to into the branch of r, unless one is
+// open already.
+func (r *Repo) openPullRequest(ctx context.Context) error {
+	forge, _ := r.forge()
+	project, head := r.forgeProject(), r.pushBranch()
+	host, _ := os.Hostname()
+	title := fmt.Sprintf("Changes of %v from CoreDNS on %s", r, host)
+	body := fmt.Sprintf("Local changes, dynamic updates and backups committed by CoreDNS on %s to %s.", host, head)
+
+	var open []pullRequest
+	var created pullRequest
+	var err error
+	switch forge {
+	case forgeGitLab:
+		q := url.Values{"state": {"opened"}, "source_branch": {head}, "target_branch": {r.Branch}}
+		if err = r.forgeRequest(ctx, http.MethodGet, "/projects/"+project+"/merge_requests?"+q.Encode(), nil, &open); err != nil || len(open) > 0 {
+			break
+		}
+		in := map[string]string{"source_branch": head, "target_branch": r.Branch, "title": title, "description": body}
+		err = r.forgeRequest(ctx, http.MethodPost, "/projects/"+project+"/merge_requests", in, &created)
+	default:
+		owner, _, _ := strings.Cut(project, "/")
+		q := url.Values{"state": {"open"}, "head": {owner + ":" + head}, "base": {r.Branch}}
+		if err = r.forgeRequest(ctx, http.MethodGet, "/repos/"+project+"/pulls?"+q.Encode(), nil, &open); err != nil || len(open) > 0 {
+			break
+		}
+		in := map[string]string{"head": head, "base": r.Branch, "title": title, "body": body}
+		err = r.forgeRequest(ctx, http.MethodPost, "/repos/"+project+"/pulls", in, &created)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot open a pull request of %v into %v of %v: %s", head, r.Branch, r, err)
+	}
+	if created.url() != "" {
+		log.Infof("opened pull request %s of %v into %v of %v", created.url(), head, r.Branch, r)
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package git
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -8,6 +11,11 @@ import (
 var (
 	// Services holds all git pulling services and provides the function to stop them.
 	Services = &services{}
+
+	// startupRetryDelay is the delay before retrying a failed first pull,
+	// doubled after every failure up to maxStartupRetryDelay.
+	startupRetryDelay    = minInterval * time.Second
+	maxStartupRetryDelay = 5 * time.Minute
 )
 
 // repoService is the service that runs in background and periodically pull from the repository.
@@ -15,28 +23,46 @@ type repoService struct {
 	repo   *Repo
 	ticker *time.Ticker  // ticker to tick at intervals
 	halt   chan struct{} // channel to notify service to halt and stop pulling.
+	done   chan struct{} // closed once the service has halted.
 }
 
 // Start starts a new background service to pull periodically.
 func Start(repo *Repo) {
+	if repo.Follow {
+		startWatch(repo)
+	}
+	if repo.DriftCheck > 0 {
+		startDriftCheck(repo)
+	}
+	for _, b := range repo.Backups {
+		startBackup(repo, b)
+	}
 	if repo.Interval <= 0 {
 		// ignore, don't setup periodic pull.
 		log.Warningf("Interval negative, periodic pull not enabled")
 		return
 	}
+	first := repo.firstDelay(time.Now())
 	service := &repoService{
 		repo,
-		time.NewTicker(repo.Interval),
+		time.NewTicker(first),
+		make(chan struct{}),
 		make(chan struct{}),
 	}
+	repo.setNextPull(time.Now().Add(first))
 	go func(s *repoService) {
+		defer close(s.done)
 		for {
 			select {
 			case <-s.ticker.C:
-				err := repo.Pull()
-				if err != nil {
-					log.Warning(err)
+				// pull every interval after the staggered first pull
+				s.ticker.Reset(repo.Interval)
+				repo.setNextPull(time.Now().Add(repo.Interval))
+				if repo.Paused() {
+					log.Debugf("Periodic pull of %v paused", repo)
+					continue
 				}
+				repo.pullFrom(repo.lifetime(), sourceInterval)
 			case <-s.halt:
 				s.ticker.Stop()
 				return
@@ -48,6 +74,83 @@ func Start(repo *Repo) {
 	Services.add(service)
 }
 
+// startupPull does the first pull of repo when the server starts. Its
+// error aborts the start, unless the pull runs in background with
+// async_start or RetryStart is set, in which case it is only logged by
+// the pull. With
+// RetryStart, a failed first pull is then retried in background until it
+// succeeds.
+func (r *Repo) startupPull() error {
+	if r.AsyncStart {
+		go func() {
+			if err := r.firstPull(); err != nil && r.RetryStart {
+				startRetry(r)
+			}
+		}()
+		return nil
+	}
+
+	err := r.firstPull()
+	if err != nil && r.RetryStart {
+		startRetry(r)
+		return nil
+	}
+	return err
+}
+
+// firstPull does the first pull of r, killing it if it does not complete
+// within MaxStart.
+func (r *Repo) firstPull() error {
+	ctx := r.lifetime()
+	if r.MaxStart > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.MaxStart)
+		defer cancel()
+	}
+	err := r.pullFrom(ctx, sourceStartup)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("first pull of %v did not complete within %v: %s", r, r.MaxStart, err)
+	}
+	return err
+}
+
+// startRetry starts a background service retrying the first pull of repo,
+// waiting twice as long after every failure, up to maxStartupRetryDelay,
+// and stopping after the first successful pull.
+func startRetry(repo *Repo) {
+	delay := startupRetryDelay
+	service := &repoService{
+		repo,
+		time.NewTicker(delay),
+		make(chan struct{}),
+		make(chan struct{}),
+	}
+	go func(s *repoService) {
+		defer close(s.done)
+		defer s.ticker.Stop()
+		for {
+			select {
+			case <-s.ticker.C:
+				if !repo.LastPull().IsZero() {
+					return
+				}
+				if err := repo.pullFrom(repo.lifetime(), sourceStartup); err == nil {
+					return
+				}
+				if delay *= 2; delay > maxStartupRetryDelay {
+					delay = maxStartupRetryDelay
+				}
+				s.ticker.Reset(delay)
+			case <-s.halt:
+				return
+			}
+		}
+	}(service)
+
+	// add to services to make it stoppable
+	Services.add(service)
+}
+
 // services stores all repoServices
 type services struct {
 	services []*repoService
@@ -76,6 +179,7 @@ func (s *services) Stop(repoURL string, limit int) {
 		if string(service.repo.URL) == repoURL {
 			// send halt signal
 			service.halt <- struct{}{}
+			<-service.done
 			s.services[i] = nil
 			j++
 		}
@@ -90,3 +194,29 @@ func (s *services) Stop(repoURL string, limit int) {
 	}
 	s.services = services
 }
+
+// stopRepo stops the services pulling repo, leaving the ones of other
+// repositories with the same URL running. It waits until they are
+// terminated, including any pull in progress, before returning.
+func (s *services) stopRepo(repo *Repo) {
+	s.Lock()
+	var stopped []*repoService
+	services := s.services[:0]
+	for _, service := range s.services {
+		if service.repo == repo {
+			stopped = append(stopped, service)
+		} else {
+			services = append(services, service)
+		}
+	}
+	for i := len(services); i < len(s.services); i++ {
+		s.services[i] = nil
+	}
+	s.services = services
+	s.Unlock()
+
+	for _, service := range stopped {
+		close(service.halt)
+		<-service.done
+	}
+}
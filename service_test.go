@@ -1,7 +1,13 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
+	stdlog "log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -65,3 +71,135 @@ func TestServices(t *testing.T) {
 		t.Errorf("Expected %v service(s), found %v", 0, len(Services.services))
 	}
 }
+
+func TestServicesStopRepo(t *testing.T) {
+	old := &Repo{URL: "git@github.com:user/repo", Interval: time.Second}
+	reloaded := &Repo{URL: "git@github.com:user/repo", Interval: time.Second}
+	Start(old)
+	Start(reloaded)
+
+	Services.stopRepo(old)
+	if len(Services.services) != 1 || Services.services[0].repo != reloaded {
+		t.Fatalf("Expected only the service of the reloaded repo, found %v", len(Services.services))
+	}
+
+	service := Services.services[0]
+	Services.stopRepo(reloaded)
+	if len(Services.services) != 0 {
+		t.Errorf("Expected %v service(s), found %v", 0, len(Services.services))
+	}
+	select {
+	case <-service.done:
+	default:
+		t.Error("Expected service to be terminated")
+	}
+}
+
+func TestStartupPullAsync(t *testing.T) {
+	dir := t.TempDir()
+	url := filepath.Join(dir, "missing.git")
+
+	repo := &Repo{URL: url, Path: filepath.Join(dir, "sync"), Branch: "master"}
+	if err := repo.startupPull(); err == nil {
+		t.Error("Expected first pull of a missing repository to fail")
+	}
+
+	repo = &Repo{URL: url, Path: filepath.Join(dir, "async"), Branch: "master", AsyncStart: true}
+	if err := repo.startupPull(); err != nil {
+		t.Errorf("Expected no error with async_start, found %v", err)
+	}
+	for i := 0; len(repo.History()) == 0; i++ {
+		if i == 100 {
+			t.Fatal("Expected first pull to complete in background")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if repo.History()[0].Error == "" {
+		t.Error("Expected first pull in background to fail")
+	}
+}
+
+func TestStartupPullTimeout(t *testing.T) {
+	// a git server accepting connections but never answering
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make(chan net.Conn, 10)
+	go func() {
+		defer close(conns)
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+		}
+	}()
+
+	repo := &Repo{
+		URL:      "http://" + l.Addr().String() + "/zones.git",
+		Path:     filepath.Join(t.TempDir(), "zones"),
+		Branch:   "master",
+		MaxStart: 100 * time.Millisecond,
+	}
+	if err := repo.startupPull(); err == nil {
+		t.Error("Expected first pull to time out")
+	}
+
+	l.Close()
+	for conn := range conns {
+		conn.Close()
+	}
+}
+
+func TestStartupPullRetry(t *testing.T) {
+	startupRetryDelay = 50 * time.Millisecond
+	defer func() { startupRetryDelay = minInterval * time.Second }()
+
+	dir := t.TempDir()
+	repo := &Repo{
+		URL:        filepath.Join(dir, "missing.git"),
+		Path:       filepath.Join(dir, "zones"),
+		Branch:     "master",
+		RetryStart: true,
+	}
+	if err := repo.startupPull(); err != nil {
+		t.Errorf("Expected no error with startup_failure retry, found %v", err)
+	}
+	defer Services.stopRepo(repo)
+
+	for i := 0; len(repo.History()) < 2; i++ {
+		if i == 100 {
+			t.Fatal("Expected first pull to be retried in background")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestStartupPullLog(t *testing.T) {
+	var buf bytes.Buffer
+	stdlog.SetOutput(&buf)
+	defer stdlog.SetOutput(os.Stderr)
+
+	dir := t.TempDir()
+	for _, format := range []string{"text", "json"} {
+		buf.Reset()
+		repo := &Repo{
+			URL:        filepath.Join(dir, "missing.git"),
+			Path:       filepath.Join(dir, format),
+			Branch:     "master",
+			LogFormat:  format,
+			RetryStart: true,
+		}
+		if err := repo.startupPull(); err != nil {
+			t.Fatal(err)
+		}
+		Services.stopRepo(repo)
+
+		// the failure is only logged with the outcome of the pull
+		if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], "[ERROR]") {
+			t.Errorf("Expected the %v failure to be logged once, found %q", format, lines)
+		}
+	}
+}
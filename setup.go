@@ -2,14 +2,22 @@ package git
 
 import (
 	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"os"
+	"path"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coredns/caddy"
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/miekg/dns"
 )
 
 var log = clog.NewWithPlugin("git")
@@ -18,8 +26,18 @@ const (
 	// DefaultInterval is the minimum interval to delay before
 	// requesting another git pull
 	DefaultInterval time.Duration = time.Hour
+
+	// minInterval is the minimum interval between pulls, in seconds
+	minInterval = 5
 )
 
+// ValidateOnly makes the plugin only check its configuration: repositories
+// are neither prepared nor pulled, secret files are not read and nothing
+// is started, so Corefiles can be linted without network access or
+// credentials, e.g. with caddy.ValidateAndExecuteDirectives. It is set if
+// the COREDNS_GIT_VALIDATE_ONLY environment variable is true.
+var ValidateOnly, _ = strconv.ParseBool(os.Getenv("COREDNS_GIT_VALIDATE_ONLY"))
+
 func init() { plugin.Register("git", setup) }
 
 func setup(c *caddy.Controller) error {
@@ -27,7 +45,11 @@ func setup(c *caddy.Controller) error {
 	if err != nil {
 		return err
 	}
+	if ValidateOnly {
+		return nil
+	}
 
+	config := dnsserver.GetConfig(c)
 	var startupFuncs []func() error // functions to execute at startup
 
 	// loop through all repos and and start monitoring
@@ -35,21 +57,78 @@ func setup(c *caddy.Controller) error {
 		repo := git.Repo(i)
 
 		startupFuncs = append(startupFuncs, func() error {
+			// a repository kept running across a reload is already started
+			started := !registry.add(repo)
+			if !started {
+				repo.tracer = tracerFor(config)
+			}
+			if repo.prev != nil {
+				repo.adopt(repo.prev)
+				repo.prev = nil
+			}
+
+			if repo.Admin != "" {
+				if err := startAdmin(repo.Admin); err != nil {
+					return err
+				}
+			}
+			if repo.Control != "" {
+				if err := startControl(repo.Control); err != nil {
+					return err
+				}
+			}
+			if repo.PullSignal != "" {
+				if err := startSignal(repo.PullSignal); err != nil {
+					return err
+				}
+			}
+
+			if started {
+				return nil
+			}
 
 			// Start service routine in background
 			Start(repo)
 
+			// the checkout taken over across a reload is already pulled
+			if !repo.LastPull().IsZero() {
+				return nil
+			}
+
 			// Do a pull right away to return error
-			return repo.Pull()
+			return repo.startupPull()
 		})
 	}
 
+	config.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		return Handler{Next: next, Repos: git, Config: config}
+	})
+
 	// ensure the functions are executed once per server block
 	// for cases like server1.com, server2.com { ... }
 	c.OncePerServerBlock(func() error {
 		for i := range startupFuncs {
 			c.OnStartup(startupFuncs[i])
 		}
+		c.OnShutdown(func() error {
+			for _, repo := range git {
+				if registry.remove(repo) {
+					repo.stop()
+					Services.stopRepo(repo)
+					deleteRepoMetrics(repo)
+				}
+				if repo.Admin != "" {
+					stopAdmin(repo.Admin)
+				}
+				if repo.Control != "" {
+					stopControl(repo.Control)
+				}
+				if repo.PullSignal != "" {
+					stopSignal(repo.PullSignal)
+				}
+			}
+			return nil
+		})
 		return nil
 	})
 
@@ -61,74 +140,1126 @@ func parse(c *caddy.Controller) (Git, error) {
 
 	config := dnsserver.GetConfig(c)
 	for c.Next() {
-		repo := &Repo{Branch: "master", Interval: DefaultInterval, Path: config.Root}
+		repo := &Repo{Branch: "master", Interval: DefaultInterval}
 
 		args := c.RemainingArgs()
 
-		clonePath := func(s string) string {
-			if filepath.IsAbs(s) {
-				return filepath.Clean(s)
+		// base is the directory relative paths are relative to
+		base := config.Root
+		clonePath := func(s string) string { return resolvePath(base, s) }
+
+		// arg returns the current argument with its placeholders expanded
+		arg := func(s string) (string, error) {
+			v, err := expand(s)
+			if err != nil {
+				return "", plugin.Error("git", c.Err(err.Error()))
 			}
-			return filepath.Join(config.Root, s)
+			return v, nil
 		}
 
+		var (
+			manifest  string // manifest file to import repositories from
+			branchSet bool   // true if the branch is configured
+			err       error
+		)
 		switch len(args) {
 		case 2:
-			repo.Path = clonePath(args[1])
+			if repo.Path, err = arg(args[1]); err != nil {
+				return nil, err
+			}
 			fallthrough
 		case 1:
-			repo.URL = args[0]
+			if repo.URL, err = arg(args[0]); err != nil {
+				return nil, err
+			}
 		}
 
 		for c.NextBlock() {
 			switch c.Val() {
+			case "name":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.Name = c.Val()
 			case "repo":
 				if !c.NextArg() {
 					return nil, plugin.Error("git", c.ArgErr())
 				}
-				repo.URL = c.Val()
+				if repo.URL, err = arg(c.Val()); err != nil {
+					return nil, err
+				}
 			case "path":
 				if !c.NextArg() {
 					return nil, plugin.Error("git", c.ArgErr())
 				}
-				repo.Path = clonePath(c.Val())
+				if repo.Path, err = arg(c.Val()); err != nil {
+					return nil, err
+				}
 			case "branch":
 				if !c.NextArg() {
 					return nil, plugin.Error("git", c.ArgErr())
 				}
-				repo.Branch = c.Val()
+				if repo.Branch, err = arg(c.Val()); err != nil {
+					return nil, err
+				}
+				branchSet = true
 			case "interval":
 				if !c.NextArg() {
 					return nil, plugin.Error("git", c.ArgErr())
 				}
-				t, _ := strconv.Atoi(c.Val())
-				if t > 0 {
+				t, err := strconv.Atoi(c.Val())
+				switch {
+				case err != nil:
+					return nil, plugin.Error("git", c.Errf("invalid interval, expected seconds: %s", c.Val()))
+				case t == -1:
+					// disables periodic pulls
+					repo.Interval = -1
+				case t < minInterval:
+					return nil, plugin.Error("git", c.Errf("interval must be at least %d seconds, or -1: %s", minInterval, c.Val()))
+				default:
 					repo.Interval = time.Duration(t) * time.Second
 				}
+			case "run_as":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if !runAsSupported {
+					return nil, plugin.Error("git", c.Err("run_as is not supported on this platform"))
+				}
+				owner, err := parseOwner(c.Val())
+				if err != nil {
+					return nil, plugin.Error("git", c.Err(err.Error()))
+				}
+				repo.RunAs = owner
+			case "allow_hosts", "allow_schemes":
+				option := c.Val()
+				values := c.RemainingArgs()
+				if len(values) == 0 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				for _, v := range values {
+					if _, err := path.Match(v, ""); err != nil {
+						return nil, plugin.Error("git", c.Errf("invalid %s pattern %s: %s", option, v, err))
+					}
+				}
+				if option == "allow_hosts" {
+					repo.Hosts = append(repo.Hosts, values...)
+				} else {
+					for _, v := range values {
+						repo.Schemes = append(repo.Schemes, strings.ToLower(v))
+					}
+				}
+			case "owner":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if !runAsSupported {
+					return nil, plugin.Error("git", c.Err("owner is not supported on this platform"))
+				}
+				owner, err := parseOwner(c.Val())
+				if err != nil {
+					return nil, plugin.Error("git", c.Err(err.Error()))
+				}
+				repo.Owner = owner
+			case "file_mode", "dir_mode", "umask":
+				option := c.Val()
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				mode, err := strconv.ParseUint(c.Val(), 8, 32)
+				if err != nil || mode > 0777 {
+					return nil, plugin.Error("git", c.Errf("invalid %s, expected octal permissions: %s", option, c.Val()))
+				}
+				switch option {
+				case "file_mode":
+					repo.FileMode = os.FileMode(mode)
+				case "dir_mode":
+					repo.DirMode = os.FileMode(mode)
+				default:
+					repo.Umask = os.FileMode(mode)
+				}
+			case "strip_modes":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.StripModes = true
+			case "base_dir":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if repo.BaseDir, err = arg(c.Val()); err != nil {
+					return nil, err
+				}
+			case "selinux_context", "xattr":
+				option := c.Val()
+				args := c.RemainingArgs()
+				if option == "selinux_context" {
+					args = append([]string{selinuxXattr}, args...)
+				}
+				if len(args) != 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if !xattrSupported {
+					return nil, plugin.Error("git", c.Errf("%s is only supported on Linux", option))
+				}
+				if !strings.Contains(args[0], ".") {
+					return nil, plugin.Error("git", c.Errf("xattr name must have a namespace, e.g. user.%s", args[0]))
+				}
+				repo.Xattrs = append(repo.Xattrs, Xattr{Name: args[0], Value: args[1]})
+			case "render":
+				repo.Render = true
+				if c.NextArg() {
+					if !validMappingSource(c.Val()) {
+						return nil, plugin.Error("git", c.Errf("render values must be a file inside the repository: %s", c.Val()))
+					}
+					repo.Values = filepath.Clean(c.Val())
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "records":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.Records = true
+			case "flatten_includes":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.Includes = true
+			case "normalize_eol":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.EOL = true
+			case "assemble":
+				args := c.RemainingArgs()
+				if len(args) < 3 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if _, ok := dns.IsDomainName(args[0]); !ok {
+					return nil, plugin.Error("git", c.Errf("invalid zone: %s", args[0]))
+				}
+				for _, p := range args[1:] {
+					if !validMappingSource(p) || filepath.Clean(p) == "." {
+						return nil, plugin.Error("git", c.Errf("assemble files must be inside the repository: %s", p))
+					}
+					if _, err := filepath.Match(p, ""); err != nil {
+						return nil, plugin.Error("git", c.Errf("invalid assemble glob %s: %s", p, err))
+					}
+				}
+				repo.Assemblies = append(repo.Assemblies, Assembly{Zone: args[0], File: filepath.Clean(args[1]), Sources: args[2:]})
+			case "decrypt":
+				repo.Decrypt = true
+				if c.NextArg() {
+					if repo.DecryptKey, err = arg(c.Val()); err != nil {
+						return nil, err
+					}
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "render_var":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				value, err := arg(args[1])
+				if err != nil {
+					return nil, err
+				}
+				repo.Vars = append(repo.Vars, args[0]+"="+value)
+			case "publish_only":
+				globs := c.RemainingArgs()
+				if len(globs) == 0 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				for _, glob := range globs {
+					if _, err := path.Match(glob, ""); err != nil {
+						return nil, plugin.Error("git", c.Errf("invalid publish_only glob %s: %s", glob, err))
+					}
+				}
+				repo.PublishOnly = append(repo.PublishOnly, globs...)
+			case "symlinks":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				switch c.Val() {
+				case symlinksIgnore, symlinksFollow, symlinksReject:
+					repo.Symlinks = c.Val()
+				default:
+					return nil, plugin.Error("git", c.Errf("unknown symlinks policy: %s", c.Val()))
+				}
+			case "max_size":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				size, err := parseSize(c.Val())
+				if err != nil {
+					return nil, plugin.Error("git", c.Err(err.Error()))
+				}
+				repo.MaxSize = size
+			case "no_shell":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.NoShell = true
+			case "mode":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				switch c.Val() {
+				case "pull":
+					repo.Follow = false
+				case "follower":
+					repo.Follow = true
+				default:
+					return nil, plugin.Error("git", c.Errf("unknown mode: %s", c.Val()))
+				}
+			case "stagger":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil || d <= 0 {
+					return nil, plugin.Error("git", c.Errf("invalid stagger: %s", c.Val()))
+				}
+				repo.Stagger = d
+			case "health_on_failure":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil || d <= 0 {
+					return nil, plugin.Error("git", c.Errf("invalid health_on_failure duration: %s", c.Val()))
+				}
+				repo.MaxAge = d
+				if c.NextArg() {
+					n, err := strconv.Atoi(c.Val())
+					if err != nil || n < 1 {
+						return nil, plugin.Error("git", c.Errf("invalid health_on_failure failures: %s", c.Val()))
+					}
+					repo.Breaker = n
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "skip_ready":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.SkipReady = true
+			case "async_start":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.AsyncStart = true
+			case "early_pull":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.EarlyPull = true
+			case "startup_timeout":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				d, err := time.ParseDuration(c.Val())
+				if err != nil || d <= 0 {
+					return nil, plugin.Error("git", c.Errf("invalid startup_timeout duration: %s", c.Val()))
+				}
+				repo.MaxStart = d
+			case "startup_failure":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				switch c.Val() {
+				case "fail":
+					repo.RetryStart = false
+				case "retry":
+					repo.RetryStart = true
+				default:
+					return nil, plugin.Error("git", c.Errf("unknown startup_failure policy: %s", c.Val()))
+				}
+			case "log_format":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				switch c.Val() {
+				case "text", "json":
+					repo.LogFormat = c.Val()
+				default:
+					return nil, plugin.Error("git", c.Errf("unknown log_format: %s", c.Val()))
+				}
+			case "audit_log":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.AuditLog = c.Val()
+			case "history":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				n, err := strconv.Atoi(c.Val())
+				if err != nil || n <= 0 {
+					return nil, plugin.Error("git", c.Errf("invalid history size: %s", c.Val()))
+				}
+				repo.HistorySize = n
+			case "chaos":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.Chaos = true
+			case "status_zone":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.StatusZone = plugin.Name(c.Val()).Normalize()
+			case "admin":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.Admin = c.Val()
+				if c.NextArg() {
+					repo.AdminToken = c.Val()
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "control_socket":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.Control = c.Val()
+			case "control_zone":
+				args := c.RemainingArgs()
+				if len(args) < 1 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if _, ok := dns.IsDomainName(args[0]); !ok {
+					return nil, plugin.Error("git", c.Errf("invalid zone: %s", args[0]))
+				}
+				repo.ControlZone = plugin.Name(args[0]).Normalize()
+				for _, key := range args[1:] {
+					repo.ControlKeys = append(repo.ControlKeys, dns.CanonicalName(key))
+				}
+			case "pull_signal":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.PullSignal = strings.TrimPrefix(strings.ToUpper(c.Val()), "SIG")
+				if _, err := parseSignal(repo.PullSignal); err != nil {
+					return nil, plugin.Error("git", err)
+				}
+			case "import_repos":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				manifest = c.Val()
+			case "map":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if !validMappingSource(args[0]) {
+					return nil, plugin.Error("git", c.Errf("map source must be inside the repository: %s", args[0]))
+				}
+				repo.Maps = append(repo.Maps, Mapping{From: filepath.Clean(args[0]), To: args[1]})
+			case "subpath":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if !validMappingSource(c.Val()) || filepath.Clean(c.Val()) == "." {
+					return nil, plugin.Error("git", c.Errf("subpath must be a directory inside the repository: %s", c.Val()))
+				}
+				repo.Subpath = filepath.Clean(c.Val())
+			case "overlay":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if repo.Overlay, err = arg(c.Val()); err != nil {
+					return nil, err
+				}
+				if strings.ContainsAny(repo.Overlay, `/\`) || repo.Overlay == "." || repo.Overlay == ".." {
+					return nil, plugin.Error("git", c.Errf("invalid overlay: %s", repo.Overlay))
+				}
+			case "dry_run":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.DryRun = true
+			case "push":
+				repo.Push = true
+				if c.NextArg() {
+					if repo.PushBranch, err = arg(c.Val()); err != nil {
+						return nil, err
+					}
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "dynamic_update":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if _, ok := dns.IsDomainName(args[0]); !ok {
+					return nil, plugin.Error("git", c.Errf("invalid zone: %s", args[0]))
+				}
+				if !validMappingSource(args[1]) || filepath.Clean(args[1]) == "." {
+					return nil, plugin.Error("git", c.Errf("zone file must be inside the repository: %s", args[1]))
+				}
+				u := Update{Zone: dns.CanonicalName(args[0]), File: filepath.Clean(args[1])}
+				for _, key := range args[2:] {
+					u.Keys = append(u.Keys, dns.CanonicalName(key))
+				}
+				repo.Updates = append(repo.Updates, u)
+			case "notify":
+				args := c.RemainingArgs()
+				if len(args) < 1 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if _, ok := dns.IsDomainName(args[0]); !ok {
+					return nil, plugin.Error("git", c.Errf("invalid zone: %s", args[0]))
+				}
+				n := Notify{Zone: dns.CanonicalName(args[0])}
+				for _, key := range args[1:] {
+					n.Keys = append(n.Keys, dns.CanonicalName(key))
+				}
+				repo.Notifies = append(repo.Notifies, n)
+			case "backup":
+				args := c.RemainingArgs()
+				if len(args) < 2 || len(args) > 3 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				dir, err := arg(args[0])
+				if err != nil {
+					return nil, err
+				}
+				b := Backup{Dir: dir, Target: filepath.Clean(args[1]), Interval: -1}
+				if !validMappingSource(b.Target) || b.Target == "." {
+					return nil, plugin.Error("git", c.Errf("backup target must be a directory inside the repository: %s", args[1]))
+				}
+				if len(args) == 3 {
+					d, err := time.ParseDuration(args[2])
+					if err != nil || d < time.Second {
+						return nil, plugin.Error("git", c.Errf("invalid backup interval: %s", args[2]))
+					}
+					b.Interval = d
+				}
+				repo.Backups = append(repo.Backups, b)
+			case "commit_author":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				author, err := mail.ParseAddress(c.Val())
+				if err != nil || author.Name == "" {
+					return nil, plugin.Error("git", c.Errf("invalid commit_author, expected \"NAME <EMAIL>\": %s", c.Val()))
+				}
+				repo.Author = author
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "commit_message":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if _, ok := commitTemplates[args[0]]; !ok {
+					return nil, plugin.Error("git", c.Errf("unknown kind of commits: %s", args[0]))
+				}
+				if _, err := parseMessage(args[1]); err != nil {
+					return nil, plugin.Error("git", c.Errf("invalid commit_message template: %s", err))
+				}
+				repo.Messages = append(repo.Messages, Message{Kind: args[0], Template: args[1]})
+			case "pull_request":
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.PullRequest = true
+			case "forge":
+				args := c.RemainingArgs()
+				if len(args) < 1 || len(args) > 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if args[0] != forgeGitHub && args[0] != forgeGitLab {
+					return nil, plugin.Error("git", c.Errf("unknown forge: %s", args[0]))
+				}
+				repo.Forge = args[0]
+				if len(args) == 2 {
+					if u, err := url.Parse(args[1]); err != nil || u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+						return nil, plugin.Error("git", c.Errf("invalid forge API URL: %s", args[1]))
+					}
+					repo.ForgeAPI = args[1]
+				}
+			case "forge_token":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if repo.ForgeToken, err = arg(c.Val()); err != nil {
+					return nil, err
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "commit_status":
+				host, _ := os.Hostname()
+				repo.StatusName = "coredns/" + host
+				if c.NextArg() {
+					repo.StatusName = c.Val()
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "deployment":
+				repo.Environment = "production"
+				if c.NextArg() {
+					repo.Environment = c.Val()
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "on_failure":
+				args := c.RemainingArgs()
+				if len(args) < 1 || len(args) > 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if repo.FailureHook, err = arg(args[0]); err != nil {
+					return nil, err
+				}
+				if u, err := url.Parse(repo.FailureHook); err != nil || u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+					return nil, plugin.Error("git", c.Errf("invalid on_failure URL: %s", redact(args[0])))
+				}
+				repo.MaxFailures = defaultMaxFailures
+				if len(args) == 2 {
+					if repo.MaxFailures, err = strconv.Atoi(args[1]); err != nil || repo.MaxFailures < 1 {
+						return nil, plugin.Error("git", c.Errf("invalid on_failure count: %s", args[1]))
+					}
+				}
+			case "sign_commits":
+				args := c.RemainingArgs()
+				if len(args) < 1 || len(args) > 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if repo.SigningKey, err = arg(args[0]); err != nil {
+					return nil, err
+				}
+				repo.SignFormat = signOpenPGP
+				if len(args) == 2 {
+					switch args[1] {
+					case signOpenPGP, signSSH, signX509:
+						repo.SignFormat = args[1]
+					default:
+						return nil, plugin.Error("git", c.Errf("unknown signing key format: %s", args[1]))
+					}
+				}
+			case "relative_to":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				switch c.Val() {
+				case "root":
+					base = config.Root
+				case "temp":
+					base = os.TempDir()
+				default:
+					if !filepath.IsAbs(c.Val()) {
+						return nil, plugin.Error("git", c.Errf("relative_to must be root, temp or an absolute directory: %s", c.Val()))
+					}
+					base = filepath.Clean(c.Val())
+				}
+			case "backend":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if _, ok := lookupBackend(c.Val()); !ok {
+					return nil, plugin.Error("git", c.Errf("unknown backend: %s", c.Val()))
+				}
+				repo.Backend = c.Val()
+				if repo.Backend == backendExec {
+					repo.Backend = ""
+				}
+			case "shared_volume", "leader_election":
+				option := c.Val()
+				args := c.RemainingArgs()
+				if option == "leader_election" {
+					if len(args) == 0 {
+						return nil, plugin.Error("git", c.ArgErr())
+					}
+					repo.Election, args = args[0], args[1:]
+				}
+				switch len(args) {
+				case 0:
+					repo.Lease = -1
+				case 1:
+					d, err := time.ParseDuration(args[0])
+					if err != nil || d < time.Second {
+						return nil, plugin.Error("git", c.Errf("invalid %s lease duration: %s", option, args[0]))
+					}
+					repo.Lease = d
+				default:
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "drift_check":
+				switch args := c.RemainingArgs(); len(args) {
+				case 0:
+					repo.DriftCheck = -1
+				case 1:
+					d, err := time.ParseDuration(args[0])
+					if err != nil || d < time.Second {
+						return nil, plugin.Error("git", c.Errf("invalid drift_check interval: %s", args[0]))
+					}
+					repo.DriftCheck = d
+				default:
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "on_drift":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				switch c.Val() {
+				case driftFail, driftReset, driftStash, driftCommit:
+					repo.OnDrift = c.Val()
+				default:
+					return nil, plugin.Error("git", c.Errf("unknown on_drift policy: %s", c.Val()))
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "in_memory":
+				repo.InMemory = true
+				for _, glob := range c.RemainingArgs() {
+					if _, err := path.Match(glob, ""); err != nil {
+						return nil, plugin.Error("git", c.Errf("invalid in_memory glob %s: %s", glob, err))
+					}
+					repo.Export = append(repo.Export, glob)
+				}
+			case "resolver":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.Resolver = c.Val()
+				if _, _, err := net.SplitHostPort(repo.Resolver); err != nil {
+					repo.Resolver = net.JoinHostPort(repo.Resolver, "53")
+				}
+				if host, _, _ := net.SplitHostPort(repo.Resolver); net.ParseIP(host) == nil {
+					return nil, plugin.Error("git", c.Errf("invalid resolver address: %s", c.Val()))
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "resolve":
+				args := c.RemainingArgs()
+				if len(args) == 0 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				for _, a := range args {
+					host, addr, ok := strings.Cut(a, "=")
+					if !ok || host == "" || net.ParseIP(addr) == nil {
+						return nil, plugin.Error("git", c.Errf("invalid address, expected HOST=ADDRESS: %s", a))
+					}
+					repo.Addresses = append(repo.Addresses, strings.ToLower(host)+"="+addr)
+				}
+			case "http_header":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if !validHeader(args[0], args[1]) {
+					return nil, plugin.Error("git", c.Errf("invalid HTTP header: %s", args[0]))
+				}
+				repo.Headers = append(repo.Headers, args[0]+": "+args[1])
+			case "user_agent":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				repo.UserAgent = c.Val()
+				if strings.ContainsAny(repo.UserAgent, "\r\n") || c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "ssh_keepalive":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				interval, err := time.ParseDuration(args[0])
+				if err != nil || interval < time.Second {
+					return nil, plugin.Error("git", c.Errf("invalid keep-alive interval, expected at least 1s: %s", args[0]))
+				}
+				repo.KeepAlive, repo.AliveCount = interval, defaultAliveCount
+				if len(args) == 2 {
+					if repo.AliveCount, err = strconv.Atoi(args[1]); err != nil || repo.AliveCount < 1 {
+						return nil, plugin.Error("git", c.Errf("invalid keep-alive count: %s", args[1]))
+					}
+				}
+			case "ssh_multiplex":
+				repo.Multiplex = defaultMultiplex
+				if c.NextArg() {
+					persist, err := time.ParseDuration(c.Val())
+					if err != nil || persist < time.Second {
+						return nil, plugin.Error("git", c.Errf("invalid duration, expected at least 1s: %s", c.Val()))
+					}
+					repo.Multiplex = persist
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "max_bandwidth":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				rate, err := parseRate(c.Val())
+				if err != nil {
+					return nil, plugin.Error("git", c.Err(err.Error()))
+				}
+				repo.Bandwidth = rate
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "follow_redirects":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				switch repo.Redirects = c.Val(); repo.Redirects {
+				case redirectsTrue, redirectsFalse, redirectsInitial:
+				default:
+					return nil, plugin.Error("git", c.Errf("unknown redirect policy: %s", repo.Redirects))
+				}
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "mirrors":
+				mirrors := c.RemainingArgs()
+				if len(mirrors) == 0 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				for _, m := range mirrors {
+					if m, err = arg(m); err != nil {
+						return nil, err
+					}
+					repo.Mirrors = append(repo.Mirrors, m)
+				}
+			case "bundle_mirror":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				if _, err := bundleURL(args[0], ""); err != nil {
+					return nil, plugin.Error("git", c.Err(err.Error()))
+				}
+				repo.Bundles, repo.BundleFirst = args[0], false
+				if len(args) == 2 {
+					switch args[1] {
+					case "fallback":
+					case "primary":
+						repo.BundleFirst = true
+					default:
+						return nil, plugin.Error("git", c.Errf("unknown bundle_mirror policy: %s", args[1]))
+					}
+				}
+			case "files":
+				files := c.RemainingArgs()
+				if len(files) == 0 {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				for _, f := range files {
+					if !validMappingSource(f) || filepath.Clean(f) == "." {
+						return nil, plugin.Error("git", c.Errf("files must be inside the repository: %s", f))
+					}
+					repo.Files = append(repo.Files, filepath.ToSlash(filepath.Clean(f)))
+				}
 			case "args":
 				repo.CloneArgs = c.RemainingArgs()
 			case "pull_args":
 				repo.PullArgs = c.RemainingArgs()
+			case "fetch_jobs":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				jobs, err := strconv.Atoi(c.Val())
+				if err != nil || jobs < 1 {
+					return nil, plugin.Error("git", c.Errf("invalid number of fetch jobs: %s", c.Val()))
+				}
+				repo.FetchJobs = jobs
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+			case "depth":
+				if !c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
+				depth, err := strconv.Atoi(c.Val())
+				if err != nil || depth < 1 {
+					return nil, plugin.Error("git", c.Errf("invalid depth: %s", c.Val()))
+				}
+				repo.Depth = depth
+				if c.NextArg() {
+					return nil, plugin.Error("git", c.ArgErr())
+				}
 			default:
 				return nil, plugin.Error("git", c.ArgErr())
 			}
 		}
 
-		// if repo is not specified, return error
-		if repo.URL == "" {
-			return nil, plugin.Error("git", fmt.Errorf("no URL set"))
+		if repo.Backend != "" && (repo.CloneArgs != nil || repo.PullArgs != nil || repo.DryRun || repo.Bundles != "" || repo.Mirrors != nil || repo.Push) {
+			return nil, plugin.Error("git", c.Err("args, pull_args, dry_run, bundle_mirror, mirrors and push are only supported by the exec backend"))
+		}
+		if repo.Updates != nil && !repo.Push {
+			return nil, plugin.Error("git", c.Err("dynamic_update needs push"))
+		}
+		if repo.Backups != nil && !repo.Push {
+			return nil, plugin.Error("git", c.Err("backup needs push"))
+		}
+		if (repo.Resolver != "" || repo.Addresses != nil) && repo.Backend != "" && repo.Backend != backendExec {
+			return nil, plugin.Error("git", c.Err("resolver and resolve are only supported by the exec backend"))
+		}
+		if (repo.Headers != nil || repo.UserAgent != "" || repo.Redirects != "") && (repo.Backend == backendGoGit || repo.Backend == backendHg) {
+			return nil, plugin.Error("git", c.Errf("http_header, user_agent and follow_redirects are not supported by the %s backend", repo.Backend))
+		}
+		if (repo.Depth > 0 || repo.FetchJobs > 0) && repo.Backend != "" && repo.Backend != backendExec {
+			return nil, plugin.Error("git", c.Err("depth and fetch_jobs are only supported by the exec backend"))
+		}
+		if (repo.KeepAlive > 0 || repo.Multiplex > 0) && repo.Backend != "" && repo.Backend != backendExec {
+			return nil, plugin.Error("git", c.Err("ssh_keepalive and ssh_multiplex are only supported by the exec backend"))
+		}
+		if repo.Bandwidth > 0 {
+			if repo.Backend == backendGoGit || repo.Backend == backendHg {
+				return nil, plugin.Error("git", c.Errf("max_bandwidth is not supported by the %s backend", repo.Backend))
+			}
+			if repo.Resolver != "" || repo.Addresses != nil {
+				return nil, plugin.Error("git", c.Err("max_bandwidth is not supported with resolver and resolve"))
+			}
+		}
+		if repo.EarlyPull && repo.AsyncStart {
+			return nil, plugin.Error("git", c.Err("early_pull is not supported with async_start"))
+		}
+		if repo.PullRequest && (repo.PushBranch == "" || repo.PushBranch == repo.Branch) {
+			return nil, plugin.Error("git", c.Err("pull_request needs push to another branch than the one of the repository"))
+		}
+		if repo.StatusName != "" && (repo.DryRun || projectOf(repo.URL) == "") {
+			return nil, plugin.Error("git", c.Err("commit_status needs a repository on a forge, without dry_run"))
+		}
+		if forge, _ := repo.forge(); repo.Environment != "" && (repo.DryRun || projectOf(repo.URL) == "" || forge != forgeGitHub) {
+			return nil, plugin.Error("git", c.Err("deployment needs a repository on GitHub, without dry_run"))
+		}
+		if (repo.SigningKey != "" || repo.Author != nil || repo.Messages != nil) && !repo.Push {
+			return nil, plugin.Error("git", c.Err("sign_commits, commit_author and commit_message need push"))
+		}
+		// backups are taken as often as the repository is pulled by default
+		for i := range repo.Backups {
+			if repo.Backups[i].Interval < 0 {
+				repo.Backups[i].Interval = repo.Interval
+				if repo.Interval <= 0 {
+					repo.Backups[i].Interval = DefaultInterval
+				}
+			}
+		}
+		if repo.Push && (repo.DryRun || repo.Follow || repo.apart() || repo.Branch == latestTag || repo.Symlinks == symlinksIgnore) {
+			return nil, plugin.Error("git", c.Errf("push is not supported with dry_run, mode follower, subpath, overlay, %s nor symlinks %s", latestTag, symlinksIgnore))
+		}
+		if (repo.DriftCheck != 0 || repo.OnDrift != "") && (repo.Backend != "" && repo.Backend != backendExec || repo.Symlinks == symlinksIgnore) {
+			return nil, plugin.Error("git", c.Errf("drift_check and on_drift are only supported by the exec backend, without symlinks %s", symlinksIgnore))
+		}
+		if repo.OnDrift != "" && (repo.DryRun || repo.Follow) {
+			return nil, plugin.Error("git", c.Err("on_drift is not supported with dry_run nor mode follower"))
+		}
+		if repo.OnDrift == driftCommit && !repo.Push {
+			return nil, plugin.Error("git", c.Errf("on_drift %s needs push", driftCommit))
+		}
+		// drift is checked as often as the repository is pulled by default
+		if repo.DriftCheck < 0 {
+			repo.DriftCheck = repo.Interval
+			if repo.Interval <= 0 {
+				repo.DriftCheck = DefaultInterval
+			}
+		}
+		// the lease outlives the pulls of its holder
+		if repo.Lease < 0 {
+			repo.Lease = 3 * repo.Interval
+			if repo.Interval <= 0 {
+				repo.Lease = 3 * DefaultInterval
+			}
+		}
+		if repo.Symlinks == symlinksIgnore && repo.Backend != "" && repo.Backend != backendExec && repo.Backend != backendArchive && repo.Backend != backendRaw {
+			return nil, plugin.Error("git", c.Errf("symlinks %s is not supported by the %s backend", symlinksIgnore, repo.Backend))
+		}
+		if repo.Follow && (repo.Symlinks == symlinksIgnore || repo.Symlinks == symlinksReject) {
+			return nil, plugin.Error("git", c.Errf("symlinks %s is not supported by followers", repo.Symlinks))
+		}
+		if repo.Subpath != "" && repo.Overlay != "" {
+			return nil, plugin.Error("git", c.Err("subpath and overlay are mutually exclusive"))
+		}
+		if repo.Symlinks == symlinksFollow && !repo.apart() && len(repo.Maps) == 0 {
+			return nil, plugin.Error("git", c.Errf("symlinks %s only applies to map and subpath", symlinksFollow))
+		}
+		if repo.Vars != nil && !repo.Render {
+			return nil, plugin.Error("git", c.Err("render_var needs render"))
+		}
+		if (repo.Render || repo.Records || repo.Includes || repo.Decrypt || repo.EOL) && !repo.apart() && len(repo.Maps) == 0 {
+			return nil, plugin.Error("git", c.Err("render, records, flatten_includes, decrypt and normalize_eol only apply to map and subpath"))
+		}
+		if repo.PublishOnly != nil && !repo.apart() && len(repo.Maps) == 0 {
+			return nil, plugin.Error("git", c.Err("publish_only only applies to map and subpath"))
+		}
+		if repo.Follow && (repo.DryRun || repo.Bundles != "" || repo.Mirrors != nil || repo.InMemory || repo.Lease != 0) {
+			return nil, plugin.Error("git", c.Err("dry_run, bundle_mirror, mirrors, in_memory, shared_volume and leader_election are not supported by followers"))
+		}
+		if repo.RunAs != nil && repo.Backend != "" && repo.Backend != backendHg {
+			return nil, plugin.Error("git", c.Errf("run_as is not supported by the %s backend, which runs in process", repo.Backend))
+		}
+		if repo.InMemory && repo.Backend != backendGoGit {
+			return nil, plugin.Error("git", c.Err("in_memory is only supported by the go-git backend"))
+		}
+		if (repo.Bundles != "" || repo.Mirrors != nil) && repo.Branch == latestTag {
+			return nil, plugin.Error("git", c.Errf("bundle_mirror and mirrors do not support %s", latestTag))
+		}
+
+		if repo.Admin != "" && repo.AdminToken == "" && !loopback(repo.Admin) {
+			return nil, plugin.Error("git", c.Errf("admin endpoint %s is not on a loopback address and needs a token", repo.Admin))
 		}
 
-		if repo.Path == "" {
-			return nil, plugin.Error("git", fmt.Errorf("no path set"))
+		// the default branch of Mercurial repositories is called default
+		if repo.Backend == backendHg && !branchSet {
+			repo.Branch = "default"
 		}
 
-		// prepare repo for use
-		if err := repo.Prepare(); err != nil {
-			return nil, plugin.Error("git", err)
+		// resolve relative paths, now that base is known
+		if repo.Path != "" {
+			repo.Path = clonePath(repo.Path)
+		}
+		for i := range repo.Maps {
+			repo.Maps[i].To = clonePath(repo.Maps[i].To)
+		}
+		if repo.AuditLog != "" && repo.AuditLog != "syslog" {
+			repo.AuditLog = clonePath(repo.AuditLog)
+		}
+		if repo.Control != "" {
+			repo.Control = clonePath(repo.Control)
+		}
+		if repo.BaseDir != "" {
+			repo.BaseDir = clonePath(repo.BaseDir)
+		}
+		if repo.DecryptKey != "" {
+			repo.DecryptKey = clonePath(repo.DecryptKey)
+		}
+		for i := range repo.Backups {
+			repo.Backups[i].Dir = clonePath(repo.Backups[i].Dir)
+		}
+		// SSH signing keys are files, unless given literally
+		if repo.SignFormat == signSSH && !strings.HasPrefix(repo.SigningKey, "key::") {
+			repo.SigningKey = clonePath(repo.SigningKey)
 		}
 
-		git = append(git, repo)
+		repos := []*Repo{repo}
+		if manifest != "" {
+			imported, err := importRepos(repo, clonePath(manifest), clonePath)
+			if err != nil {
+				return nil, plugin.Error("git", err)
+			}
+			// the block only holds defaults if it has no URL
+			if repo.URL == "" {
+				repos = imported
+			} else {
+				repos = append(repos, imported...)
+			}
+		}
+
+		for _, repo := range repos {
+			// if repo is not specified, return error
+			if repo.URL == "" {
+				return nil, plugin.Error("git", fmt.Errorf("no URL set"))
+			}
+			if repo.Password == "" {
+				repo.URL, repo.Password = splitPassword(repo.URL)
+			}
+			if err := repo.allowed(); err != nil {
+				return nil, plugin.Error("git", err)
+			}
+			if repo.NoShell {
+				if err := validNoShell(repo); err != nil {
+					return nil, plugin.Error("git", fmt.Errorf("no_shell: %s", err))
+				}
+			}
+			if repo.Bandwidth > 0 {
+				if err := validBandwidth(repo); err != nil {
+					return nil, plugin.Error("git", err)
+				}
+			}
+			switch {
+			case repo.Backend == backendArchive:
+				if err := validArchive(repo); err != nil {
+					return nil, plugin.Error("git", err)
+				}
+			case repo.Backend == backendRaw:
+				if err := validRaw(repo); err != nil {
+					return nil, plugin.Error("git", err)
+				}
+			case len(repo.Files) > 0:
+				return nil, plugin.Error("git", fmt.Errorf("files are only downloaded by the raw backend"))
+			}
+
+			// without a path, clone into a directory named after the
+			// repository, but not into the working directory of the process,
+			// e.g. / under systemd
+			if repo.Path == "" && repoName(repo.URL) != "" {
+				if base == "" {
+					return nil, plugin.Error("git", fmt.Errorf("no path set for %v, and no root or relative_to to clone it into", redact(repo.URL)))
+				}
+				repo.Path = clonePath(repoName(repo.URL))
+			}
+
+			repo.Path = repo.expandPath(repo.Path, config.Zone)
+			maps := make([]Mapping, len(repo.Maps))
+			for i, m := range repo.Maps {
+				maps[i] = Mapping{From: m.From, To: repo.expandPath(m.To, config.Zone)}
+			}
+			repo.Maps = maps
+
+			if repo.Path == "" {
+				return nil, plugin.Error("git", fmt.Errorf("no path set"))
+			}
+			if err := repo.checkBase(); err != nil {
+				return nil, plugin.Error("git", err)
+			}
+
+			if repo.Name != "" {
+				for _, r := range git {
+					if r.Name == repo.Name {
+						return nil, plugin.Error("git", fmt.Errorf("duplicate repo name: %s", repo.Name))
+					}
+				}
+			}
+
+			if err := claimPaths(c, repo); err != nil {
+				return nil, plugin.Error("git", err)
+			}
+
+			// keep the repository running if a reload did not change it
+			if running := registry.running(repo); running != nil {
+				git = append(git, running)
+				continue
+			}
+
+			// take the checkout over if the reload only changed other settings
+			if repo.prev = registry.checkout(repo); repo.prev != nil {
+				git = append(git, repo)
+				continue
+			}
+
+			// prepare repo for use
+			if !ValidateOnly {
+				if err := repo.Prepare(); err != nil {
+					return nil, plugin.Error("git", redactError(err))
+				}
+				// the checkout is there when the plugins set up next load their zones
+				if repo.EarlyPull {
+					if err := repo.firstPull(); err != nil && !repo.RetryStart {
+						return nil, plugin.Error("git", err)
+					}
+				}
+			}
+
+			git = append(git, repo)
+		}
 	}
 
 	return git, nil
@@ -2,12 +2,19 @@ package git
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/coredns/caddy"
 )
 
 func TestGitParse(t *testing.T) {
+	t.Setenv("GIT_TEST_REPO", "https://github.com/user/repo")
+	t.Setenv("GIT_TEST_PATH", "/tmp/git1")
+
 	tests := []struct {
 		input     string
 		shouldErr bool
@@ -29,6 +36,435 @@ func TestGitParse(t *testing.T) {
 			Path:      "/tmp/git1",
 			CloneArgs: []string{"--depth", "1"},
 		}},
+		{`git git@github.com:user/repo {
+			path /tmp/git1
+			health_on_failure 30m
+		}`, false, &Repo{
+			URL:    "git@github.com:user/repo",
+			Path:   "/tmp/git1",
+			MaxAge: 30 * time.Minute,
+		}},
+		{`git git@github.com:user/repo {
+			path /tmp/git1
+			health_on_failure soon
+		}`, true, nil},
+		{`git git@github.com:user/repo {
+			path /tmp/git1
+			health_on_failure 30m 3
+		}`, false, &Repo{
+			URL:     "git@github.com:user/repo",
+			Path:    "/tmp/git1",
+			MaxAge:  30 * time.Minute,
+			Breaker: 3,
+		}},
+		{`git git@github.com:user/repo {
+			path /tmp/git1
+			health_on_failure 30m 0
+		}`, true, nil},
+		{`git git@github.com:user/repo {
+			path /tmp/git1
+			health_on_failure 30m 3 5
+		}`, true, nil},
+		{`git git@github.com:user/repo {
+			path /tmp/git1
+			pull_signal SIGUSR1
+		}`, true, nil},
+		{`git git@github.com:user/repo {
+			path /tmp/git1
+			admin localhost:8054
+		}`, false, &Repo{
+			URL:  "git@github.com:user/repo",
+			Path: "/tmp/git1",
+		}},
+		{`git git@github.com:user/repo {
+			path /tmp/git1
+			admin :8054
+		}`, true, nil},
+		{`git git@github.com:user/repo {
+			path /tmp/git1
+			admin :8054 secret
+		}`, false, &Repo{
+			URL:  "git@github.com:user/repo",
+			Path: "/tmp/git1",
+		}},
+		{`git {$GIT_TEST_REPO} {
+			path {$GIT_TEST_PATH}
+		}`, false, &Repo{
+			URL:  "https://github.com/user/repo",
+			Path: "/tmp/git1",
+		}},
+		{`git https://github.com/user/repo {
+			name zones
+			path /tmp/git1
+		}`, false, &Repo{
+			Name: "zones",
+			URL:  "https://github.com/user/repo",
+			Path: "/tmp/git1",
+		}},
+		{`git https://github.com/user/repo /tmp/git1 {
+			name zones
+		}
+		git https://github.com/user/repo /tmp/git2 {
+			name zones
+		}`, true, nil},
+		{`git https://github.com/user/repo1 /tmp/git1
+		git https://github.com/user/repo2 /tmp/git1`, true, nil},
+		{`git https://github.com/user/repo1 /tmp/git1
+		git https://github.com/user/repo2 /tmp/git1/sub`, true, nil},
+		{`git https://github.com/user/repo1 /tmp/git1 {
+			map zones /tmp/git1/zones
+		}`, true, nil},
+		{`git https://github.com/user/repo1 /tmp/git1 {
+			map prod /tmp/git2
+		}
+		git https://github.com/user/repo2 /tmp/git2`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			interval 300
+		}`, false, &Repo{
+			URL:      "https://github.com/user/repo",
+			Interval: 300 * time.Second,
+		}},
+		{`git https://github.com/user/repo /tmp/git1 {
+			interval -1
+		}`, false, &Repo{
+			URL:      "https://github.com/user/repo",
+			Interval: -1,
+		}},
+		{`git https://github.com/user/repo /tmp/git1 {
+			interval 5m
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			interval 0
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			interval 2
+		}`, true, nil},
+		{`git https://github.com/user/zones.git {
+			path /tmp/git-{repo}-{branch}
+			branch release/v1
+		}`, false, &Repo{
+			URL:  "https://github.com/user/zones.git",
+			Path: "/tmp/git-zones-release-v1",
+		}},
+		{`git https://github.com/user/repo git-relative {
+			relative_to temp
+		}`, false, &Repo{
+			URL:  "https://github.com/user/repo",
+			Path: filepath.Join(os.TempDir(), "git-relative"),
+		}},
+		{`git https://github.com/user/repo {
+			path git-relative
+			relative_to /tmp/git-base
+		}`, false, &Repo{
+			URL:  "https://github.com/user/repo",
+			Path: "/tmp/git-base/git-relative",
+		}},
+		{`git https://github.com/user/repo {
+			relative_to /tmp/git-base2
+		}`, false, &Repo{
+			URL:  "https://github.com/user/repo",
+			Path: "/tmp/git-base2/repo",
+		}},
+		// without root, the default path would be in the working directory
+		{`git https://github.com/user/repo`, true, nil},
+		{`git https://github.com/user/repo {
+			relative_to root
+		}`, true, nil},
+		{`git https://github.com/user/zones.git {
+			relative_to /tmp/git-base2
+		}
+		git https://github.com/user/records.git {
+			relative_to /tmp/git-base2
+		}`, false, &Repo{
+			URL:  "https://github.com/user/zones.git",
+			Path: "/tmp/git-base2/zones",
+		}},
+		{`git https://github.com/user/repo /tmp/git1 {
+			relative_to somewhere
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			bundle_mirror s3://bucket/zones sometimes
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			bundle_mirror gs://bucket/zones
+			backend go-git
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			backend raw
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			allow_hosts gitlab.com *.corp.example
+		}`, true, nil},
+		{`git git@github.com:user/repo /tmp/git1 {
+			allow_schemes https
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			allow_hosts [github.com
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			max_size 10MB
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			symlinks copy
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			symlinks follow
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			publish_only *.zone
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			subpath zones
+			render ../values.yaml
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			subpath zones
+			render_var ip 192.0.2.1
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			records
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			normalize_eol
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			strip_modes 0111
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push main extra
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			dynamic_update example.org db.example.org
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			drift_check 10
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			commit_author dns@corp
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			commit_author "DNS <dns@corp>"
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			commit_message deploy "{{.Zone}}"
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			commit_message update "{{.Zone"
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			sign_commits /etc/coredns/signing ssh
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			pull_request
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push dns
+			pull_request now
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			forge gitea
+		}`, true, nil},
+		{`git /srv/git/zones.git /tmp/git1 {
+			commit_status
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			commit_status coredns prod
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			early_pull
+			async_start
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			resolver dns.corp
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			resolve github.com
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			resolve github.com=10.0.0.1
+			backend go-git
+		}`, true, nil},
+		{`git git@github.com:user/repo /tmp/git1 {
+			resolve github.com=10.0.0.1
+			no_shell
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			http_header X-Proxy-Token
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			http_header "X Proxy" secret
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			user_agent coredns/1.0
+			backend go-git
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			follow_redirects sometimes
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			depth 0
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			fetch_jobs many
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			fetch_jobs 8
+			backend hg
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			depth 1
+			backend go-git
+		}`, true, nil},
+		{`git git@github.com:user/repo /tmp/git1 {
+			ssh_keepalive 500ms
+		}`, true, nil},
+		{`git git@github.com:user/repo /tmp/git1 {
+			ssh_keepalive 30s 0
+		}`, true, nil},
+		{`git git@github.com:user/repo /tmp/git1 {
+			ssh_multiplex 5m 1m
+		}`, true, nil},
+		{`git git@github.com:user/repo /tmp/git1 {
+			ssh_multiplex
+			backend go-git
+		}`, true, nil},
+		{`git git@github.com:user/repo /tmp/git1 {
+			ssh_keepalive 30s
+			no_shell
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			no_shell yes
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			max_bandwidth fast
+		}`, true, nil},
+		{`git git@github.com:user/repo /tmp/git1 {
+			max_bandwidth 5MB/s
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			max_bandwidth 5MB/s
+			backend go-git
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			max_bandwidth 5MB/s
+			resolve github.com=10.0.0.1
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			follow_redirects false
+			backend hg
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			control_zone
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			notify
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			notify example..org
+		}`, true, nil},
+		{`git https://gitlab.com/user/repo /tmp/git1 {
+			deployment
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			on_failure ftp://hooks.corp/failed
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			on_failure https://hooks.corp/failed 0
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			forge gitlab git.corp/api/v4
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			sign_commits ABCDEF0123456789 pgp
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			backup /var/lib/coredns/secondary zones
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			backup /var/lib/coredns/secondary .
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			backup /var/lib/coredns/secondary zones 1
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			on_drift merge
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			on_drift commit
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			on_drift reset
+			dry_run
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			drift_check
+			backend archive
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			dynamic_update example.org ../db.example.org
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			dynamic_update example.org
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			backend go-git
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			push
+			subpath zones
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			subpath zones
+			normalize_eol crlf
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			overlay
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			overlay ../prod
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			subpath zones
+			overlay prod
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			subpath zones
+			flatten_includes all
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			assemble example.org db.example.org
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			subpath zones
+			decrypt /etc/coredns/age.key extra
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			assemble example.org ../db.example.org base.zone
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			xattr origin zones
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			selinux_context
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			subpath zones
+			publish_only [
+		}`, true, nil},
+		{`git https://github.com/user/repo /tmp/git1 {
+			symlinks ignore
+			backend go-git
+		}`, true, nil},
 	}
 
 	for i, test := range tests {
@@ -62,11 +498,134 @@ func reposEqual(expected, repo *Repo) bool {
 	if expected.Path != "" && expected.Path != repo.Path {
 		return false
 	}
+	if expected.Name != "" && expected.Name != repo.Name {
+		return false
+	}
 	if expected.URL != "" && expected.URL != repo.URL {
 		return false
 	}
+	if expected.MaxAge != 0 && expected.MaxAge != repo.MaxAge {
+		return false
+	}
+	if expected.Breaker != repo.Breaker {
+		return false
+	}
 	if fmt.Sprint(expected.CloneArgs) != fmt.Sprint(repo.CloneArgs) {
 		return false
 	}
 	return true
 }
+
+func TestGitParseValidateOnly(t *testing.T) {
+	ValidateOnly = true
+	defer func() { ValidateOnly = false }()
+
+	dir := filepath.Join(t.TempDir(), "zones")
+	c := caddy.NewTestController("dns", `git https://x:{file:/run/secrets/missing}@github.com/user/repo `+dir)
+	git, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if len(git) != 1 {
+		t.Fatalf("Expected 1 repo, found %v", len(git))
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("Expected %v not to be created, found %v", dir, err)
+	}
+}
+
+func TestGitParseReload(t *testing.T) {
+	dir := t.TempDir()
+	input := `git https://github.com/user/repo ` + dir + ` {
+		interval 300
+	}`
+
+	git, err := parse(caddy.NewTestController("dns", input))
+	if err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	running := git.Repo(0)
+	registry.add(running)
+	defer registry.remove(running)
+
+	// an unchanged repository keeps running across a reload
+	git, err = parse(caddy.NewTestController("dns", input))
+	if err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if git.Repo(0) != running {
+		t.Error("Expected unchanged repo to be reused")
+	}
+
+	git, err = parse(caddy.NewTestController("dns", `git https://github.com/user/repo `+dir+` {
+		interval 600
+	}`))
+	if err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	changed := git.Repo(0)
+	if changed == running {
+		t.Fatal("Expected changed repo not to be reused")
+	}
+	if changed.prev != running {
+		t.Fatal("Expected changed repo to take the checkout over")
+	}
+
+	running.setLastPull(time.Now())
+	running.lastCommit = "abc"
+	changed.adopt(running)
+	if changed.Commit() != "abc" || changed.LastPull().IsZero() {
+		t.Errorf("Expected state of running repo to be taken over, found commit %q", changed.Commit())
+	}
+	if changed.Interval != 600*time.Second {
+		t.Errorf("Expected new interval to apply, found %v", changed.Interval)
+	}
+
+	git, err = parse(caddy.NewTestController("dns", `git https://github.com/user/repo `+dir+` {
+		branch main
+	}`))
+	if err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if git.Repo(0).prev != nil {
+		t.Error("Expected repo with another branch not to take the checkout over")
+	}
+
+	git, err = parse(caddy.NewTestController("dns", `git https://github.com/user/repo `+dir+` {
+		interval 300
+		backend go-git
+	}`))
+	if err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if git.Repo(0).prev != nil {
+		t.Error("Expected repo with another backend not to take the checkout over")
+	}
+}
+
+func TestGitParseEarlyPull(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	work := newOrigin(t, map[string]string{"db.example.org": "v1"})
+
+	git, err := parse(caddy.NewTestController("dns", `git `+work+` `+filepath.Join(dir, "zones")+` {
+		branch master
+		early_pull
+	}`))
+	if err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	// pulled when parsed, before the zones of the next plugins are loaded
+	if b, err := os.ReadFile(filepath.Join(dir, "zones", "db.example.org")); err != nil || string(b) != "v1" || git.Repo(0).LastPull().IsZero() {
+		t.Errorf("Expected the checkout to be pulled, found %q (%v)", b, err)
+	}
+
+	_, err = parse(caddy.NewTestController("dns", `git `+filepath.Join(dir, "missing")+` `+filepath.Join(dir, "other")+` {
+		early_pull
+	}`))
+	if err == nil {
+		t.Error("Expected a failed early pull to fail the setup")
+	}
+}
@@ -0,0 +1,66 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultDeepen is the number of commits a shallow clone is deepened
+	// by at once, unless Depth is set.
+	defaultDeepen = 50
+
+	// maxDeepens is the number of times a shallow clone is deepened for
+	// the history a command needs before the command fails.
+	maxDeepens = 10
+)
+
+// shallow reports whether the checkout at dir is a shallow clone, cloned
+// with Depth or --depth in CloneArgs.
+func shallow(ctx context.Context, dir string) bool {
+	out, err := runCmdOutput(ctx, "git", []string{"rev-parse", "--is-shallow-repository"}, dir)
+	return err == nil && out == "true"
+}
+
+// missingHistory reports whether err is the error of a git command which
+// needed commits a shallow clone does not have, such as the merge base of
+// a pull.
+func missingHistory(err error) bool {
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{"unrelated histories", "shallow", "no merge base", "bad object", "could not parse object", "unknown revision", "not a valid object name"} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// deepen fetches Depth, or defaultDeepen, more commits of the history of
+// the branch of the shallow clone at dir.
+func (r *Repo) deepen(ctx context.Context, dir string) error {
+	step := r.Depth
+	if step == 0 {
+		step = defaultDeepen
+	}
+	params := []string{"fetch", "--progress", "--deepen=" + strconv.Itoa(step), r.fetchRemote()}
+	if r.Branch != latestTag {
+		params = append(params, r.Branch)
+	}
+	log.Infof("deepening the shallow clone of %v by %d commits", r, step)
+	return r.gitCmd(ctx, params, dir)
+}
+
+// withHistory runs the git command params in dir, deepening the shallow
+// clone at dir and running it again while it fails for missing history.
+func (r *Repo) withHistory(ctx context.Context, params []string, dir string) error {
+	err := r.gitCmd(ctx, params, dir)
+	for i := 0; err != nil && i < maxDeepens && missingHistory(err) && shallow(ctx, dir); i++ {
+		if derr := r.deepen(ctx, dir); derr != nil {
+			return fmt.Errorf("%s, and cannot deepen the shallow clone: %s", err, derr)
+		}
+		err = r.gitCmd(ctx, params, dir)
+	}
+	return err
+}
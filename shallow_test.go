@@ -0,0 +1,64 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestMissingHistory(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{errors.New("git pull origin master: exit status 128: fatal: refusing to merge unrelated histories"), true},
+		{errors.New("git reset --hard 1234: exit status 128: fatal: Could not parse object '1234'"), true},
+		{errors.New("git reset --hard 1234: exit status 128: fatal: ambiguous argument '1234': unknown revision or path not in the working tree."), true},
+		{errors.New("git pull origin master: exit status 1: fatal: unable to access 'https://example.org/zones.git/'"), false},
+	}
+	for i, test := range tests {
+		if missing := missingHistory(test.err); missing != test.expected {
+			t.Errorf("Test %d: expected %v for %v, found %v", i, test.expected, test.err, missing)
+		}
+	}
+}
+
+func TestDeepen(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	src := newOrigin(t, map[string]string{"db.example.org": "v1"})
+	git := func(dir string, args ...string) { runGit(t, dir, args...) }
+	first, _ := runCmdOutput(context.Background(), "git", []string{"rev-parse", "HEAD"}, src)
+	for _, v := range []string{"v2", "v3", "v4"} {
+		writeFiles(t, src, map[string]string{"db.example.org": v})
+		git(src, "commit", "-q", "-am", v)
+	}
+
+	// local clones are only shallow from file:// URLs
+	repo := &Repo{URL: "file://" + filepath.ToSlash(src), Path: filepath.Join(dir, "zones"), Branch: "master", Depth: 1}
+	if err := repo.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !shallow(context.Background(), repo.Path) {
+		t.Fatal("Expected a shallow clone")
+	}
+
+	// resetting to a commit the clone does not have deepens it
+	if err := (execBackend{}).Reset(context.Background(), repo, repo.Path, first); err != nil {
+		t.Fatalf("Expected reset to deepen the clone, found %v", err)
+	}
+	if head, _ := runCmdOutput(context.Background(), "git", []string{"rev-parse", "HEAD"}, repo.Path); head != first {
+		t.Errorf("Expected checkout at %v, found %v", first, head)
+	}
+	count, _ := runCmdOutput(context.Background(), "git", []string{"rev-list", "--count", "origin/master"}, repo.Path)
+	if count != "4" {
+		t.Errorf("Expected the whole history to be fetched, found %v commits", count)
+	}
+}
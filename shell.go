@@ -0,0 +1,94 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// noShellConfig is the configuration of git commands with NoShell: git
+// runs no hook, credential helper or ext:: transport, which it would run
+// through a shell, and runs ssh directly, a command without shell
+// metacharacters being executed without a shell.
+var noShellConfig = []string{
+	"-c", "core.hooksPath=" + os.DevNull,
+	"-c", "core.sshCommand=ssh",
+	"-c", "core.fsmonitor=false",
+	"-c", "credential.helper=",
+	"-c", "protocol.ext.allow=never",
+}
+
+// gitParams returns the parameters of git for params. With permissions
+// set by the plugin, git ignores the executable bits of the checkout.
+func (r *Repo) gitParams(params []string) []string {
+	var config []string
+	if r.NoShell {
+		config = append(config, noShellConfig...)
+	}
+	if r.FileMode != 0 || r.Umask != 0 || r.StripModes {
+		config = append(config, "-c", "core.fileMode=false")
+	}
+	config = append(config, r.pinConfig()...)
+	config = append(config, r.sshConfig()...)
+	config = append(config, r.httpConfig()...)
+	config = append(config, r.throttleConfig()...)
+	config = append(config, r.fetchConfig()...)
+	if r.Push || r.OnDrift == driftStash {
+		config = append(config, r.commitConfig()...)
+	}
+	return append(config, params...)
+}
+
+// gitEnv returns the environment of git for the commands of r, passing
+// the configuration holding secrets, the password of URL sent with the
+// requests to URL and Headers, out of the command line any local user can
+// read.
+func (r *Repo) gitEnv() []string {
+	return configEnv(append(r.credentialConfig(), r.headerConfig()...))
+}
+
+// configEnv returns the environment passing config, options as names and
+// values, to git as -c would, after the options the environment of this
+// process passes already. Git reads them since version 2.31.
+func configEnv(config [][2]string) []string {
+	if len(config) == 0 {
+		return nil
+	}
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	var env []string
+	for _, c := range config {
+		env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n, c[0]), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, c[1]))
+		n++
+	}
+	return append(env, "GIT_CONFIG_COUNT="+strconv.Itoa(n))
+}
+
+// validNoShell checks r doesn't need a shell to be pulled with NoShell.
+func validNoShell(r *Repo) error {
+	if r.Backend != "" && r.Backend != backendGoGit && r.Backend != backendArchive && r.Backend != backendRaw {
+		return fmt.Errorf("the %s backend may run commands through a shell", r.Backend)
+	}
+	if os.Getenv("GIT_SSH_COMMAND") != "" {
+		return fmt.Errorf("GIT_SSH_COMMAND is run through a shell")
+	}
+	for _, u := range append([]string{r.URL}, r.Mirrors...) {
+		if strings.HasPrefix(u, "ext::") {
+			return fmt.Errorf("ext:: URLs are run through a shell: %s", redact(u))
+		}
+		// ssh is given options through a shell
+		scheme, host := remoteOf(u)
+		if _, pinned := r.address(host); scheme == "ssh" && (r.Resolver != "" || pinned || r.KeepAlive > 0 || r.Multiplex > 0) {
+			return fmt.Errorf("SSH URLs are run through a shell with resolver, resolve, ssh_keepalive and ssh_multiplex: %s", redact(u))
+		}
+	}
+	for _, arg := range append(append([]string{}, r.CloneArgs...), r.PullArgs...) {
+		name, _, _ := strings.Cut(arg, "=")
+		switch {
+		case name == "--config", name == "--upload-pack", name == "--template",
+			strings.HasPrefix(arg, "-c"), strings.HasPrefix(arg, "-u"):
+			return fmt.Errorf("%s may make git run commands through a shell", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNoShell(t *testing.T) {
+	runner := &recordingRunner{}
+	CommandRunner = runner
+	defer func() { CommandRunner = ExecRunner{} }()
+
+	repo := &Repo{URL: "git@github.com:user/zones", Path: "/tmp/zones", Branch: "master", NoShell: true}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	for _, command := range runner.commands {
+		if !strings.HasPrefix(command, "git "+strings.Join(noShellConfig, " ")+" ") {
+			t.Errorf("Expected shell-free configuration, found %q", command)
+		}
+	}
+
+	tests := []struct {
+		repo      *Repo
+		shouldErr bool
+	}{
+		{&Repo{URL: "git@github.com:user/zones", CloneArgs: []string{"--depth", "1"}}, false},
+		{&Repo{URL: "https://github.com/user/zones", Backend: backendGoGit}, false},
+		{&Repo{URL: "ext::ssh -i key example.org %S 'zones'"}, true},
+		{&Repo{URL: "https://github.com/user/zones", Mirrors: []string{"ext::sh -c true"}}, true},
+		{&Repo{URL: "https://github.com/user/zones", CloneArgs: []string{"--upload-pack=sh -c true"}}, true},
+		{&Repo{URL: "https://github.com/user/zones", PullArgs: []string{"-c", "core.sshCommand=sh"}}, true},
+		{&Repo{URL: "https://github.com/user/zones", Backend: backendHg}, true},
+	}
+	for i, test := range tests {
+		err := validNoShell(test.repo)
+		if test.shouldErr != (err != nil) {
+			t.Errorf("Test %d: expected error %v, found %v", i, test.shouldErr, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// signalWatcher pulls the repositories configured with its signal every
+// time the process receives it.
+type signalWatcher struct {
+	name string
+	ch   chan os.Signal
+	done chan struct{}
+}
+
+// startSignal starts watching the signal called name, or adds a reference
+// to the watcher if it is already running.
+func startSignal(name string) error {
+	return listeners.acquire("signal "+name, func() (io.Closer, error) {
+		sig, err := parseSignal(name)
+		if err != nil {
+			return nil, err
+		}
+		w := &signalWatcher{name: name, ch: make(chan os.Signal, 1), done: make(chan struct{})}
+		signal.Notify(w.ch, sig)
+		go w.run()
+		return w, nil
+	})
+}
+
+// stopSignal removes a reference to the watcher of the signal called name
+// and stops it once it is no longer used.
+func stopSignal(name string) error { return listeners.release("signal " + name) }
+
+func (w *signalWatcher) run() {
+	for {
+		select {
+		case <-w.ch:
+			log.Infof("Received SIG%s, pulling", w.name)
+			var wg sync.WaitGroup
+			for _, r := range registry.all() {
+				if r.PullSignal != w.name {
+					continue
+				}
+				wg.Add(1)
+				go func(r *Repo) {
+					defer wg.Done()
+					r.pullFrom(r.lifetime(), sourceSignal)
+				}(r)
+			}
+			wg.Wait()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Close implements io.Closer.
+func (w *signalWatcher) Close() error {
+	signal.Stop(w.ch)
+	close(w.done)
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package git
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// parseSignal returns the signal called name, without the SIG prefix.
+// Signals CoreDNS already acts upon are refused.
+func parseSignal(name string) (os.Signal, error) {
+	switch name {
+	case "HUP":
+		return syscall.SIGHUP, nil
+	case "WINCH":
+		return syscall.SIGWINCH, nil
+	case "USR1", "USR2", "TERM", "QUIT", "INT":
+		return nil, fmt.Errorf("signal SIG%s is reserved by CoreDNS", name)
+	}
+	return nil, fmt.Errorf("unsupported signal: %s", name)
+}
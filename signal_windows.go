@@ -0,0 +1,13 @@
+//go:build windows
+
+package git
+
+import (
+	"errors"
+	"os"
+)
+
+// parseSignal returns an error, as signals are not supported on Windows.
+func parseSignal(name string) (os.Signal, error) {
+	return nil, errors.New("pull_signal is not supported on Windows")
+}
@@ -0,0 +1,79 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultAliveCount is the number of keep-alive messages of ssh left
+	// unanswered before it drops a connection by default.
+	defaultAliveCount = 3
+
+	// defaultMultiplex is how long ssh keeps a connection open for the
+	// next commands by default.
+	defaultMultiplex = 10 * time.Minute
+)
+
+// sshConfig returns the configuration of git running ssh with the options
+// of r: connecting to the host of the SSH remote being pulled from at the
+// address it is pinned to, checking the key of the host as usual, sending
+// keep-alive messages every KeepAlive, and sharing connections for
+// Multiplex once the control directory is made.
+func (r *Repo) sshConfig() []string {
+	var opts []string
+	if scheme, host := remoteOf(r.remoteURL()); scheme == "ssh" {
+		if addr, ok := r.pinnedAddress(host); ok {
+			opts = append(opts, "-o", "HostName="+addr, "-o", "HostKeyAlias="+host)
+		}
+	}
+	if r.KeepAlive > 0 {
+		opts = append(opts,
+			"-o", "ServerAliveInterval="+seconds(r.KeepAlive),
+			"-o", "ServerAliveCountMax="+strconv.Itoa(r.AliveCount),
+		)
+	}
+	if r.Multiplex > 0 && r.controlDir != "" {
+		opts = append(opts,
+			"-o", "ControlMaster=auto",
+			"-o", "ControlPath="+filepath.Join(r.controlDir, "%C"),
+			"-o", "ControlPersist="+seconds(r.Multiplex),
+		)
+	}
+	if len(opts) == 0 {
+		return nil
+	}
+	return []string{"-c", "core.sshCommand=ssh " + strings.Join(opts, " ")}
+}
+
+// seconds returns d as a whole number of seconds, as ssh options take.
+func seconds(d time.Duration) string { return strconv.FormatInt(int64(d/time.Second), 10) }
+
+// makeControlDir makes the private directory of the control sockets of
+// the connections ssh shares with Multiplex, unless it is made already.
+// It is removed once r is stopped, its connections being closed once they
+// have been idle for Multiplex.
+func (r *Repo) makeControlDir() error {
+	if r.Multiplex == 0 || r.controlDir != "" {
+		return nil
+	}
+	// sockets have short paths: the temporary directory, not the checkout
+	dir, err := os.MkdirTemp("", "coredns-git-ssh")
+	if err != nil {
+		return fmt.Errorf("cannot make the ssh control directory of %v: %s", r, err)
+	}
+	if err := r.RunAs.chown(dir); err != nil {
+		os.Remove(dir)
+		return fmt.Errorf("cannot make the ssh control directory of %v: %s", r, err)
+	}
+	go func() {
+		<-r.lifetime().Done()
+		os.RemoveAll(dir)
+	}()
+	r.controlDir = dir
+	return nil
+}
@@ -0,0 +1,48 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSSHConfig(t *testing.T) {
+	if config := (&Repo{URL: "git@github.com:user/zones"}).sshConfig(); config != nil {
+		t.Errorf("Expected no ssh configuration by default, found %q", config)
+	}
+
+	repo := &Repo{
+		URL:        "git@git.corp:dns/zones",
+		Addresses:  []string{"git.corp=10.1.2.3"},
+		KeepAlive:  15 * time.Second,
+		AliveCount: 4,
+		Multiplex:  10 * time.Minute,
+	}
+	expected := []string{"-c", "core.sshCommand=ssh -o HostName=10.1.2.3 -o HostKeyAlias=git.corp -o ServerAliveInterval=15 -o ServerAliveCountMax=4"}
+	if config := repo.sshConfig(); !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %q before the control directory is made, found %q", expected, config)
+	}
+
+	if err := repo.makeControlDir(); err != nil {
+		t.Fatal(err)
+	}
+	dir := repo.controlDir
+	if fi, err := os.Stat(dir); err != nil || fi.Mode().Perm()&0077 != 0 {
+		t.Fatalf("Expected a private control directory, found %v (%v)", fi, err)
+	}
+	expected[1] += " -o ControlMaster=auto -o ControlPath=" + filepath.Join(dir, "%C") + " -o ControlPersist=600"
+	if config := repo.sshConfig(); !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %q, found %q", expected, config)
+	}
+
+	repo.stop()
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected the control directory to be removed once the repository is stopped")
+}
@@ -0,0 +1,31 @@
+package git
+
+import (
+	"hash/fnv"
+	"os"
+	"time"
+)
+
+// staggerKey identifies this resolver in the fleet, its hostname, which
+// is unique per pod of a StatefulSet or a Deployment.
+var staggerKey, _ = os.Hostname()
+
+// firstDelay returns the delay before the first periodic pull. With
+// Stagger, pulls are scheduled at a fixed offset of each window of
+// Interval, from the Unix epoch: the offset is derived from the hostname
+// and the repository, so resolvers started together spread their pulls
+// across Stagger deterministically. Otherwise it is Interval.
+func (r *Repo) firstDelay(now time.Time) time.Duration {
+	if r.Stagger <= 0 || r.Interval <= 0 {
+		return r.Interval
+	}
+	h := fnv.New64a()
+	h.Write([]byte(staggerKey + "/" + r.String()))
+	offset := time.Duration(h.Sum64()%uint64(r.Stagger)) % r.Interval
+
+	d := (offset - time.Duration(now.UnixNano())%r.Interval) % r.Interval
+	if d <= 0 {
+		d += r.Interval
+	}
+	return d
+}
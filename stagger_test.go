@@ -0,0 +1,40 @@
+package git
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFirstDelay(t *testing.T) {
+	repo := &Repo{URL: "https://github.com/user/zones", Interval: time.Hour}
+	now := time.Unix(1700000000, 0)
+	if d := repo.firstDelay(now); d != time.Hour {
+		t.Errorf("Expected first pull after the interval without stagger, found %v", d)
+	}
+
+	repo.Stagger = 10 * time.Minute
+	first := repo.firstDelay(now)
+	if first <= 0 || first > time.Hour {
+		t.Fatalf("Expected first pull within the interval, found %v", first)
+	}
+	offset := time.Duration(now.Add(first).UnixNano()) % time.Hour
+	if offset >= repo.Stagger {
+		t.Errorf("Expected pull at an offset within the stagger, found %v", offset)
+	}
+	// the schedule doesn't depend on the start time
+	if d := repo.firstDelay(now.Add(7 * time.Minute)); now.Add(7*time.Minute+d) != now.Add(first) && now.Add(7*time.Minute+d) != now.Add(first+time.Hour) {
+		t.Errorf("Expected the same schedule, found first pull after %v", d)
+	}
+
+	// resolvers are spread
+	host := staggerKey
+	defer func() { staggerKey = host }()
+	offsets := map[time.Duration]bool{}
+	for _, host := range []string{"coredns-0", "coredns-1", "coredns-2", "coredns-3"} {
+		staggerKey = host
+		offsets[time.Duration(now.Add(repo.firstDelay(now)).UnixNano())%time.Hour] = true
+	}
+	if len(offsets) < 2 {
+		t.Errorf("Expected resolvers to pull at different offsets, found %v", offsets)
+	}
+}
@@ -0,0 +1,85 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
+)
+
+// statusZone returns the status zone qname belongs to, or "" if it does
+// not belong to the status zone of any repository.
+func (h Handler) statusZone(qname string) string {
+	var zones []string
+	for _, r := range h.Repos {
+		if r.StatusZone != "" {
+			zones = append(zones, r.StatusZone)
+		}
+	}
+	return plugin.Zones(zones).Matches(qname)
+}
+
+// statusTXT returns a TXT record for every repository serving its status at zone.
+func (h Handler) statusTXT(zone string) []dns.RR {
+	var rrs []dns.RR
+	for _, r := range h.Repos {
+		if r.StatusZone != zone {
+			continue
+		}
+		rrs = append(rrs, &dns.TXT{
+			Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 0},
+			Txt: []string{
+				"name=" + r.Name,
+				"repo=" + redact(r.URL),
+				"branch=" + r.Branch,
+				"commit=" + r.Commit(),
+				"pulled=" + formatTime(r.LastPull()),
+				"healthy=" + strconv.FormatBool(r.Healthy()),
+			},
+		})
+	}
+	return rrs
+}
+
+// statusSOA returns the SOA record of the status zone zone. Like the TXT
+// records, it is not to be cached, as the status changes with every pull.
+func statusSOA(zone string) dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 0},
+		Ns:      zone,
+		Mbox:    "hostmaster." + zone,
+		Serial:  1,
+		Refresh: 7200,
+		Retry:   1800,
+		Expire:  86400,
+		Minttl:  0,
+	}
+}
+
+// serveStatus answers a query for the status zone zone.
+func (h Handler) serveStatus(w dns.ResponseWriter, r *dns.Msg, zone string) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+
+	q := r.Question[0]
+	switch {
+	case !dns.IsSubDomain(zone, q.Name) || dns.CountLabel(q.Name) != dns.CountLabel(zone):
+		m.Rcode = dns.RcodeNameError
+	case q.Qtype == dns.TypeTXT || q.Qtype == dns.TypeANY:
+		m.Answer = h.statusTXT(zone)
+	case q.Qtype == dns.TypeSOA:
+		m.Answer = []dns.RR{statusSOA(zone)}
+	}
+	// negative answers hold the SOA record, to be cached
+	if len(m.Answer) == 0 {
+		m.Ns = []dns.RR{statusSOA(zone)}
+	}
+
+	if err := w.WriteMsg(m); err != nil {
+		return dns.RcodeServerFailure, fmt.Errorf("writing status response: %s", err)
+	}
+	return m.Rcode, nil
+}
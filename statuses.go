@@ -0,0 +1,59 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// maxStatusDescription is the max length of the description of a commit
+// status on GitHub.
+const maxStatusDescription = 140
+
+// reportStatus reports the outcome err of a pull, which checked out prev
+// before, as a commit status named StatusName on the forge: success on the
+// commit deployed, or failure on the commit rejected. Statuses are only
+// reported once per commit and outcome, and failing to report them is
+// only logged.
+func (r *Repo) reportStatus(ctx context.Context, prev string, err error) {
+	if r.StatusName == "" {
+		return
+	}
+	host, _ := os.Hostname()
+	commit, state, desc := r.lastCommit, "success", "Deployed to "+host
+	if err != nil {
+		// pulled but not published
+		commit = r.rejected
+		if commit == "" && r.lastCommit != prev {
+			commit = r.lastCommit
+		}
+		state, desc = "failure", "Rejected by "+host+": "+err.Error()
+	}
+	if commit == "" || commit+" "+state == r.reported {
+		return
+	}
+	if len(desc) > maxStatusDescription {
+		desc = strings.ToValidUTF8(desc[:maxStatusDescription-3], "") + "..."
+	}
+
+	forge, _ := r.forge()
+	project := r.forgeProject()
+	var rerr error
+	switch forge {
+	case forgeGitLab:
+		if state == "failure" {
+			state = "failed"
+		}
+		in := map[string]string{"state": state, "name": r.StatusName, "description": desc}
+		rerr = r.forgeRequest(ctx, http.MethodPost, "/projects/"+project+"/statuses/"+commit, in, nil)
+	default:
+		in := map[string]string{"state": state, "context": r.StatusName, "description": desc}
+		rerr = r.forgeRequest(ctx, http.MethodPost, "/repos/"+project+"/statuses/"+commit, in, nil)
+	}
+	if rerr != nil {
+		log.Warningf("cannot report the status of %s of %v: %s", commit, r, rerr)
+		return
+	}
+	r.reported = commit + " " + state
+}
@@ -0,0 +1,50 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Policies of the symbolic links of repositories, set with the symlinks
+// option.
+const (
+	symlinksIgnore = "ignore" // removed from the checkout
+	symlinksFollow = "follow" // their targets are published instead
+	symlinksReject = "reject" // fail the pull
+)
+
+// applySymlinks enforces the Symlinks policy on the checkout at dir,
+// removing its symbolic links or failing if it has one.
+func (r *Repo) applySymlinks(dir string) error {
+	if r.Symlinks != symlinksIgnore && r.Symlinks != symlinksReject {
+		return nil
+	}
+	var links []string
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() && (fi.Name() == ".git" || fi.Name() == ".hg" || fi.Name() == archiveMeta) {
+			return filepath.SkipDir
+		}
+		if fi.Mode()&os.ModeSymlink != 0 {
+			links = append(links, path)
+		}
+		return nil
+	})
+	if err != nil || len(links) == 0 {
+		return err
+	}
+	if r.Symlinks == symlinksReject {
+		rel, _ := filepath.Rel(dir, links[0])
+		return fmt.Errorf("repo %v has symbolic link %v, rejected by its symlinks policy", r, rel)
+	}
+	// git restores them before the next pull updates them
+	for _, link := range links {
+		if err := os.Remove(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
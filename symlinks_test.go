@@ -0,0 +1,101 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncTreeFollow(t *testing.T) {
+	root := t.TempDir()
+	src, dst := filepath.Join(root, "prod"), filepath.Join(t.TempDir(), "zones")
+	writeFiles(t, root, map[string]string{
+		"prod/db.example.org":   "example.org",
+		"shared/db.example.net": "example.net",
+	})
+	if err := os.Symlink("db.example.org", filepath.Join(src, "db.example.com")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	if err := os.Symlink(filepath.Join("..", "shared"), filepath.Join(src, "shared")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncTree(src, dst, syncOptions{root: root}); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"db.example.org":        "example.org",
+		"db.example.com":        "example.org",
+		"shared/db.example.net": "example.net",
+	} {
+		path := filepath.Join(dst, name)
+		if fi, err := os.Lstat(path); err != nil || !fi.Mode().IsRegular() {
+			t.Errorf("Expected %v to be a regular file, found %v", name, err)
+		}
+		if b, _ := os.ReadFile(path); string(b) != content {
+			t.Errorf("Expected %v to hold %q, found %q", name, content, b)
+		}
+	}
+
+	// a link out of the repository or to a directory containing it fails
+	if err := os.Symlink(filepath.Join("..", "..", ".."), filepath.Join(src, "up")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncTree(src, dst, syncOptions{root: root}); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("Expected a link outside of the repository to fail, found %v", err)
+	}
+	os.Remove(filepath.Join(src, "up"))
+	if err := os.Symlink(".", filepath.Join(src, "self")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncTree(src, dst, syncOptions{root: root}); err == nil || !strings.Contains(err.Error(), "containing") {
+		t.Errorf("Expected a link to its directory to fail, found %v", err)
+	}
+}
+
+func TestApplySymlinks(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	src := newOrigin(t, map[string]string{"db.example.org": "v1"})
+	if err := os.Symlink("db.example.org", filepath.Join(src, "db.example.com")); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	runGit(t, src, "add", ".")
+	runGit(t, src, "commit", "-q", "-m", "link")
+
+	// ignored links are removed after every pull
+	repo := &Repo{URL: src, Path: filepath.Join(dir, "ignore"), Branch: "master", Symlinks: symlinksIgnore}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected no error, found %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(repo.Path, "db.example.com")); !os.IsNotExist(err) {
+		t.Errorf("Expected the link to be removed, found %v", err)
+	}
+	os.Remove(filepath.Join(src, "db.example.com"))
+	if err := os.Symlink("db.example.net", filepath.Join(src, "db.example.com")); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, src, "commit", "-q", "-am", "v2")
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatalf("Expected a changed link to be pulled, found %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(repo.Path, "db.example.com")); !os.IsNotExist(err) {
+		t.Errorf("Expected the link to be removed, found %v", err)
+	}
+
+	// a clone with links is rejected and removed
+	repo = &Repo{URL: src, Path: filepath.Join(dir, "reject"), Branch: "master", Symlinks: symlinksReject}
+	if err := os.MkdirAll(repo.Path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.pull(context.Background()); err == nil || !strings.Contains(err.Error(), "db.example.com") {
+		t.Fatalf("Expected the link to be rejected, found %v", err)
+	}
+	if entries, _ := os.ReadDir(repo.Path); len(entries) != 0 || repo.pulled {
+		t.Errorf("Expected the rejected clone to be removed, found %v", entries)
+	}
+}
@@ -0,0 +1,63 @@
+package git
+
+import (
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/trace"
+
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// tracerFor returns the tracer of the trace plugin configured for the
+// server block of config, or a no-op tracer if tracing is disabled.
+func tracerFor(config *dnsserver.Config) ot.Tracer {
+	if t, ok := config.Handler("trace").(trace.Trace); ok {
+		return t.Tracer()
+	}
+	return ot.NoopTracer{}
+}
+
+// startPullSpan starts the root span of a pull. The caller must hold the lock.
+func (r *Repo) startPullSpan(source string) ot.Span {
+	if r.tracer == nil {
+		r.tracer = ot.NoopTracer{}
+	}
+	r.span = r.tracer.StartSpan("git.pull")
+	r.span.SetTag("git.repo", r.String())
+	r.span.SetTag("git.branch", r.Branch)
+	r.span.SetTag("git.trigger", source)
+	return r.span
+}
+
+// startSpan starts a span for operation op, child of the running pull.
+// The caller must hold the lock.
+func (r *Repo) startSpan(op string) ot.Span {
+	if r.span == nil {
+		return ot.NoopTracer{}.StartSpan(op)
+	}
+	return r.tracer.StartSpan("git."+op, ot.ChildOf(r.span.Context()))
+}
+
+// startPhase starts a span for the phase op of the running pull, parent of
+// the spans started until it is finished by calling the returned function
+// with the error of the phase. The caller must hold the lock.
+func (r *Repo) startPhase(op string) func(error) {
+	parent := r.span
+	span := r.startSpan(op)
+	if parent != nil {
+		r.span = span
+	}
+	return func(err error) {
+		finishSpan(span, err)
+		r.span = parent
+	}
+}
+
+// finishSpan marks span as failed if err is not nil and finishes it.
+func finishSpan(span ot.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("error", redact(err.Error()))
+	}
+	span.Finish()
+}
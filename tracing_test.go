@@ -0,0 +1,42 @@
+package git
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+)
+
+func TestPullSpans(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	origin := newOrigin(t, map[string]string{"db.example.org": "v1"})
+	tracer := mocktracer.New()
+	repo := &Repo{URL: origin, Path: filepath.Join(t.TempDir(), "zones"), Branch: "master", tracer: tracer}
+	if err := repo.pullFrom(context.Background(), sourceManual); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := map[string]*mocktracer.MockSpan{}
+	for _, span := range tracer.FinishedSpans() {
+		spans[span.OperationName] = span
+	}
+	root := spans["git.pull"]
+	if root == nil || root.ParentID != 0 || root.Tag("git.trigger") != sourceManual {
+		t.Fatalf("Expected a git.pull root span, found %v", tracer.FinishedSpans())
+	}
+	for name, parent := range map[string]string{
+		"git.backend":  "git.pull",
+		"git.validate": "git.pull",
+		"git.hooks":    "git.pull",
+		"git.clone":    "git.backend",
+	} {
+		span, p := spans[name], spans[parent]
+		if span == nil || span.ParentID != p.SpanContext.SpanID {
+			t.Errorf("Expected a %v span, child of %v, found %v", name, parent, tracer.FinishedSpans())
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package git
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// transferStats matches the statistics git prints, with --progress, once
+// it received the objects of a clone or fetch: their number, size and
+// the throughput of the transfer.
+var transferStats = regexp.MustCompile(`(?:Receiving|Unpacking) objects: 100% \(\d+/\d+\), ([\d.]+) (bytes|KiB|MiB|GiB)(?: \| [^,\r\n]*)?, done\.`)
+
+// transferUnits are the multiples of the sizes git prints.
+var transferUnits = map[string]float64{"bytes": 1, "KiB": 1 << 10, "MiB": 1 << 20, "GiB": 1 << 30}
+
+// fetchedBytes returns the number of bytes git received from remotes, as
+// of the transfer statistics in its output.
+func fetchedBytes(output []byte) int64 {
+	var total float64
+	for _, m := range transferStats.FindAllSubmatch(output, -1) {
+		if size, err := strconv.ParseFloat(string(m[1]), 64); err == nil {
+			total += size * transferUnits[string(m[2])]
+		}
+	}
+	return int64(total)
+}
+
+// countFetched adds n bytes to the bytes fetched for r.
+func (r *Repo) countFetched(n int64) {
+	if n > 0 {
+		bytesFetched.WithLabelValues(r.String()).Add(float64(n))
+	}
+}
+
+// countedBody is the body of a response counting the bytes read from it.
+type countedBody struct {
+	io.ReadCloser
+	r *Repo
+}
+
+func (b countedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.r.countFetched(int64(n))
+	return n, err
+}
+
+// countBody makes the bytes read from the body of resp counted as fetched
+// for r.
+func (r *Repo) countBody(resp *http.Response) {
+	resp.Body = countedBody{resp.Body, r}
+}
@@ -0,0 +1,22 @@
+package git
+
+import "testing"
+
+func TestFetchedBytes(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected int64
+	}{
+		{"Receiving objects:  50% (1/2)\rReceiving objects: 100% (2/2), 1.50 KiB | 1.50 MiB/s, done.\n", 1536},
+		{"Receiving objects: 100% (122/122), 4.38 KiB | 4.38 MiB/s, done.\nResolving deltas: 100% (3/3), done.\n", 4485},
+		{"Unpacking objects: 100% (3/3), 252 bytes | 252.00 KiB/s, done.\n", 252},
+		{"Receiving objects: 100% (9/9), 2.00 MiB, done.\nUnpacking objects: 100% (3/3), 1 bytes | 1 bytes/s, done.\n", 2<<20 + 1},
+		{"Receiving objects:  50% (1/2), 1.50 KiB | 1.50 MiB/s\n", 0},
+		{"Already up to date.\n", 0},
+	}
+	for _, test := range tests {
+		if n := fetchedBytes([]byte(test.output)); n != test.expected {
+			t.Errorf("Expected %d bytes fetched for %q, found %d", test.expected, test.output, n)
+		}
+	}
+}
@@ -0,0 +1,565 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// Update is a zone whose dynamic updates (RFC 2136) are written to a zone
+// file of the repository, then committed and pushed.
+type Update struct {
+	Zone string   // Zone updated, fully qualified
+	File string   // Zone file of the zone, relative to the checkout
+	Keys []string // Names of the TSIG keys allowed to update the zone, any if empty
+}
+
+// tsigKey returns the name of the TSIG key r is signed with, if its
+// signature is valid. CoreDNS verifies the signatures of the keys of the
+// tsig plugin on UDP, TCP and TLS, but its DNS over HTTPS, QUIC and gRPC
+// servers report any signature as valid: the signature is verified again
+// against the secrets of the tsig plugin. It covers r as sent, packed
+// again with and without compression of names.
+func (h Handler) tsigKey(w dns.ResponseWriter, r *dns.Msg) (string, bool) {
+	t := r.IsTsig()
+	if t == nil || w.TsigStatus() != nil || h.Config == nil {
+		return "", false
+	}
+	key := strings.ToLower(t.Hdr.Name)
+	secret, ok := h.Config.TsigSecret[key]
+	if !ok {
+		return "", false
+	}
+	for _, compress := range []bool{false, true} {
+		m := r.Copy()
+		m.Compress = compress
+		if buf, err := m.Pack(); err == nil && dns.TsigVerify(buf, secret, "", false) == nil {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// updateRepo returns the repository zone is updated in, and its Update,
+// or nil if the updates of zone are not persisted.
+func (h Handler) updateRepo(zone string) (*Repo, *Update) {
+	for _, r := range h.Repos {
+		for i := range r.Updates {
+			if strings.EqualFold(r.Updates[i].Zone, zone) {
+				return r, &r.Updates[i]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// serveUpdate answers the dynamic update r of the zone of u, persisted in
+// repo. Only updates signed with an allowed TSIG key are accepted.
+func (h Handler) serveUpdate(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, repo *Repo, u *Update) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	key, ok := h.tsigKey(w, r)
+	if ok && len(u.Keys) > 0 {
+		ok = contains(u.Keys, key)
+	}
+	if !ok {
+		m.Rcode = dns.RcodeRefused
+	} else {
+		var err error
+		if m.Rcode, err = repo.update(ctx, u, r, key, w.RemoteAddr().String()); err != nil {
+			log.Errorf("cannot update %v in %v: %s", u.Zone, repo, err)
+		}
+	}
+	if t := r.IsTsig(); t != nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, t.Fudge, time.Now().Unix())
+	}
+
+	if err := w.WriteMsg(m); err != nil {
+		return dns.RcodeServerFailure, fmt.Errorf("writing update response: %s", err)
+	}
+	return m.Rcode, nil
+}
+
+// update applies the dynamic update msg, signed with key by client, to the
+// zone file of u, then commits it with the changes as message, pushes it
+// and publishes it. It returns the rcode of the response.
+func (r *Repo) update(ctx context.Context, u *Update, msg *dns.Msg, key, client string) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	ctx = r.commandContext(ctx)
+
+	unlock, err := r.lockCheckout(ctx)
+	if err != nil {
+		return dns.RcodeServerFailure, fmt.Errorf("cannot lock the checkout: %s", err)
+	}
+	defer unlock()
+
+	dir := r.workDir()
+	file := filepath.Join(dir, u.File)
+	zf, err := readZone(file, u.Zone)
+	if err != nil {
+		return dns.RcodeServerFailure, err
+	}
+	rrs := zf.rrs()
+	if rcode := checkPrereqs(rrs, msg.Answer, u.Zone); rcode != dns.RcodeSuccess {
+		return rcode, nil
+	}
+	rrs, changes, rcode := applyUpdates(rrs, msg.Ns, u.Zone)
+	if rcode != dns.RcodeSuccess || len(changes) == 0 {
+		return rcode, nil
+	}
+	if err := writeZone(file, zf, rrs); err != nil {
+		return dns.RcodeServerFailure, err
+	}
+
+	commitMsg, err := r.commitMessage(messageUpdate, commitData{Zone: u.Zone, Key: key, Client: client, Changes: strings.Join(changes, "\n")})
+	if err != nil {
+		return dns.RcodeServerFailure, err
+	}
+	if err := r.gitCmd(ctx, []string{"commit", "--quiet", "-m", commitMsg, "--", u.File}, dir); err != nil {
+		return dns.RcodeServerFailure, err
+	}
+	log.Infof("committed dynamic update of %v in %v by %v", u.Zone, r, key)
+	return dns.RcodeSuccess, r.committed(ctx, dir)
+}
+
+// zoneFile is a zone file read for an update, with the text of each of
+// its records, so writing it back only changes the lines of the records
+// the update changed.
+type zoneFile struct {
+	records []zoneRecord
+	tail    string // lines after the last record
+}
+
+// zoneRecord is a record of a zone file.
+type zoneRecord struct {
+	rr     dns.RR
+	serial uint32 // serial of rr as read, if it is a SOA record
+	before string // comments, blank lines and directives before the record
+	text   string // lines of the record
+}
+
+// readZone reads the zone file of zone at path. Files with $INCLUDE or
+// $GENERATE directives are refused, as their records can't be updated in
+// place.
+func readZone(path, zone string) (*zoneFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	parts, tail, err := splitZone(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("cannot update %s: %s", path, err)
+	}
+
+	z := &zoneFile{tail: tail}
+	zp := dns.NewZoneParser(bytes.NewReader(b), zone, path)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if len(z.records) == len(parts) {
+			break
+		}
+		zr := zoneRecord{rr: rr, before: parts[len(z.records)][0], text: parts[len(z.records)][1]}
+		if soa, ok := rr.(*dns.SOA); ok {
+			zr.serial = soa.Serial
+		}
+		z.records = append(z.records, zr)
+	}
+	if err := zp.Err(); err != nil {
+		return nil, err
+	}
+	if len(z.records) != len(parts) {
+		return nil, fmt.Errorf("cannot update %s: cannot match its records to its lines", path)
+	}
+	return z, nil
+}
+
+// splitZone splits the text of a zone file into the lines of each of its
+// records, preceded by the lines before it, and returns the lines after
+// the last record.
+func splitZone(text string) ([][2]string, string, error) {
+	var (
+		parts       [][2]string
+		before, rec strings.Builder
+		depth       int
+	)
+	for _, line := range strings.SplitAfter(text, "\n") {
+		content, parens := zoneLine(line)
+		if depth == 0 {
+			fields := strings.Fields(content)
+			if len(fields) == 0 {
+				before.WriteString(line)
+				continue
+			}
+			if strings.HasPrefix(fields[0], "$") {
+				if d := strings.ToUpper(fields[0]); d != "$ORIGIN" && d != "$TTL" {
+					return nil, "", fmt.Errorf("%s is not supported", fields[0])
+				}
+				before.WriteString(line)
+				continue
+			}
+		}
+		rec.WriteString(line)
+		if depth += parens; depth <= 0 {
+			parts = append(parts, [2]string{before.String(), rec.String()})
+			before.Reset()
+			rec.Reset()
+			depth = 0
+		}
+	}
+	if rec.Len() > 0 {
+		return nil, "", fmt.Errorf("unbalanced parentheses")
+	}
+	return parts, before.String(), nil
+}
+
+// zoneLine returns the line of a zone file without its comment, and the
+// number of parentheses it opens less those it closes.
+func zoneLine(line string) (string, int) {
+	quoted, escaped, parens := false, false, 0
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == ';':
+			return line[:i], parens
+		case c == '(':
+			parens++
+		case c == ')':
+			parens--
+		}
+	}
+	return line, parens
+}
+
+// rrs returns the records of z.
+func (z *zoneFile) rrs() []dns.RR {
+	rrs := make([]dns.RR, len(z.records))
+	for i, zr := range z.records {
+		rrs[i] = zr.rr
+	}
+	return rrs
+}
+
+// format returns the text of z with the records rrs, the records of z
+// after an update. The lines of the records kept are kept as is, but for
+// the serial of the SOA record. A record replacing another one takes its
+// place, other new records are added at the end.
+func (z *zoneFile) format(rrs []dns.RR) string {
+	kept := map[dns.RR]bool{}
+	for _, rr := range rrs {
+		kept[rr] = true
+	}
+	read := map[dns.RR]bool{}
+	for _, zr := range z.records {
+		read[zr.rr] = true
+	}
+	var added []dns.RR
+	for _, rr := range rrs {
+		if !read[rr] {
+			added = append(added, rr)
+		}
+	}
+
+	var b strings.Builder
+	owner := ""
+	for _, zr := range z.records {
+		b.WriteString(zr.before)
+		if !kept[zr.rr] {
+			for i, rr := range added {
+				if replaces(rr, zr.rr) {
+					b.WriteString(rr.String() + "\n")
+					owner = rr.Header().Name
+					added = append(added[:i], added[i+1:]...)
+					break
+				}
+			}
+			continue
+		}
+		text := zr.text
+		if soa, ok := zr.rr.(*dns.SOA); ok && soa.Serial != zr.serial {
+			if text = replaceSerial(text, zr.serial, soa.Serial); text == "" {
+				text = soa.String() + "\n"
+			}
+		}
+		// the record has the owner of the previous one, which may have
+		// been removed
+		name := zr.rr.Header().Name
+		if (text[0] == ' ' || text[0] == '\t') && !strings.EqualFold(owner, name) {
+			text = name + text
+		}
+		b.WriteString(text)
+		owner = name
+	}
+	b.WriteString(z.tail)
+	if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+		b.WriteByte('\n')
+	}
+	for _, rr := range added {
+		b.WriteString(rr.String() + "\n")
+	}
+	return b.String()
+}
+
+// replaces reports whether the record rr added by an update replaces the
+// record old, as of addRR.
+func replaces(rr, old dns.RR) bool {
+	h, oh := rr.Header(), old.Header()
+	if !strings.EqualFold(h.Name, oh.Name) || h.Rrtype != oh.Rrtype {
+		return false
+	}
+	return h.Rrtype == dns.TypeSOA || h.Rrtype == dns.TypeCNAME || dns.IsDuplicate(rr, old)
+}
+
+// replaceSerial returns text, the lines of a SOA record, with its serial
+// old replaced with serial, or "" if it is not found.
+func replaceSerial(text string, old, serial uint32) string {
+	var fields [][2]int
+	start, comment := -1, false
+	for i := 0; i <= len(text); i++ {
+		c := byte('\n')
+		if i < len(text) {
+			c = text[i]
+		}
+		if comment {
+			comment = c != '\n'
+			continue
+		}
+		if strings.IndexByte(" \t\r\n();", c) < 0 {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			fields = append(fields, [2]int{start, i})
+			start = -1
+		}
+		comment = c == ';'
+	}
+	// the serial follows the type, the primary server and the mailbox
+	for i, f := range fields {
+		if !strings.EqualFold(text[f[0]:f[1]], "SOA") || i+3 >= len(fields) {
+			continue
+		}
+		f = fields[i+3]
+		if text[f[0]:f[1]] != strconv.FormatUint(uint64(old), 10) {
+			return ""
+		}
+		return text[:f[0]] + strconv.FormatUint(uint64(serial), 10) + text[f[1]:]
+	}
+	return ""
+}
+
+// writeZone atomically replaces the zone file at path, read as z, with
+// rrs, the records of z after an update.
+func writeZone(path string, z *zoneFile, rrs []dns.RR) error {
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		perm = fi.Mode().Perm()
+	}
+	return replaceFile(strings.NewReader(z.format(rrs)), path, perm)
+}
+
+// rrset returns the records of rrs named name of type t, or of any type
+// but RRSIG if t is ANY.
+func rrset(rrs []dns.RR, name string, t uint16) []dns.RR {
+	var set []dns.RR
+	for _, rr := range rrs {
+		h := rr.Header()
+		if strings.EqualFold(h.Name, name) && (h.Rrtype == t || t == dns.TypeANY && h.Rrtype != dns.TypeRRSIG) {
+			set = append(set, rr)
+		}
+	}
+	return set
+}
+
+// checkPrereqs checks the prerequisites of an update of zone, with the
+// records rrs, as of RFC 2136 section 3.2. It returns the rcode of the
+// first one failing, or success.
+func checkPrereqs(rrs, prereqs []dns.RR, zone string) int {
+	// value dependent prerequisites, by name and type
+	type key struct {
+		name string
+		t    uint16
+	}
+	sets := map[key][]dns.RR{}
+	for _, p := range prereqs {
+		h := p.Header()
+		if h.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		if !dns.IsSubDomain(zone, h.Name) {
+			return dns.RcodeNotZone
+		}
+		switch h.Class {
+		case dns.ClassANY:
+			if h.Rrtype == dns.TypeANY && len(rrset(rrs, h.Name, dns.TypeANY)) == 0 {
+				return dns.RcodeNameError
+			}
+			if h.Rrtype != dns.TypeANY && len(rrset(rrs, h.Name, h.Rrtype)) == 0 {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if h.Rrtype == dns.TypeANY && len(rrset(rrs, h.Name, dns.TypeANY)) != 0 {
+				return dns.RcodeYXDomain
+			}
+			if h.Rrtype != dns.TypeANY && len(rrset(rrs, h.Name, h.Rrtype)) != 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			k := key{strings.ToLower(h.Name), h.Rrtype}
+			sets[k] = append(sets[k], p)
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+	for k, set := range sets {
+		if !sameRRset(set, rrset(rrs, k.name, k.t)) {
+			return dns.RcodeNXRrset
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// sameRRset reports whether a and b hold the same records, TTLs aside.
+func sameRRset(a, b []dns.RR) bool {
+	covers := func(a, b []dns.RR) bool {
+		for _, x := range a {
+			found := false
+			for _, y := range b {
+				if dns.IsDuplicate(x, y) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+		return true
+	}
+	return covers(a, b) && covers(b, a)
+}
+
+// applyUpdates applies the updates of zone to its records rrs, as of RFC
+// 2136 section 3.4. It returns the updated records, the changes made and
+// the rcode of the response. The serial of the SOA record is incremented
+// if any change is made, unless the SOA record is updated itself.
+func applyUpdates(rrs, updates []dns.RR, zone string) ([]dns.RR, []string, int) {
+	// prescan, before changing anything
+	for _, u := range updates {
+		h := u.Header()
+		if !dns.IsSubDomain(zone, h.Name) {
+			return nil, nil, dns.RcodeNotZone
+		}
+		switch h.Class {
+		case dns.ClassINET:
+			if h.Rrtype == dns.TypeANY || h.Rrtype == dns.TypeAXFR || h.Rrtype == dns.TypeIXFR {
+				return nil, nil, dns.RcodeFormatError
+			}
+		case dns.ClassANY, dns.ClassNONE:
+			if h.Ttl != 0 || h.Rrtype == dns.TypeAXFR || h.Rrtype == dns.TypeIXFR || h.Class == dns.ClassNONE && h.Rrtype == dns.TypeANY {
+				return nil, nil, dns.RcodeFormatError
+			}
+		default:
+			return nil, nil, dns.RcodeFormatError
+		}
+	}
+
+	// the SOA and NS records of the apex are never deleted with their name
+	apex := func(rr dns.RR) bool {
+		h := rr.Header()
+		return strings.EqualFold(h.Name, zone) && (h.Rrtype == dns.TypeSOA || h.Rrtype == dns.TypeNS)
+	}
+	var changes []string
+	soaUpdated := false
+	remove := func(match func(dns.RR) bool) {
+		kept := rrs[:0]
+		for _, rr := range rrs {
+			if match(rr) {
+				changes = append(changes, "- "+rr.String())
+				continue
+			}
+			kept = append(kept, rr)
+		}
+		rrs = kept
+	}
+
+	for _, u := range updates {
+		h := u.Header()
+		switch h.Class {
+		case dns.ClassINET:
+			n := len(changes)
+			rrs = addRR(rrs, u, &changes)
+			soaUpdated = soaUpdated || h.Rrtype == dns.TypeSOA && len(changes) > n
+		case dns.ClassANY:
+			remove(func(rr dns.RR) bool {
+				rh := rr.Header()
+				return strings.EqualFold(rh.Name, h.Name) && (h.Rrtype == dns.TypeANY || rh.Rrtype == h.Rrtype) && !apex(rr)
+			})
+		case dns.ClassNONE:
+			if h.Rrtype == dns.TypeSOA || h.Rrtype == dns.TypeNS && strings.EqualFold(h.Name, zone) && len(rrset(rrs, zone, dns.TypeNS)) <= 1 {
+				continue
+			}
+			deleted := dns.Copy(u)
+			deleted.Header().Class = dns.ClassINET
+			remove(func(rr dns.RR) bool { return dns.IsDuplicate(rr, deleted) })
+		}
+	}
+
+	if len(changes) > 0 && !soaUpdated {
+		for _, rr := range rrs {
+			if soa, ok := rr.(*dns.SOA); ok && strings.EqualFold(soa.Hdr.Name, zone) {
+				soa.Serial++
+			}
+		}
+	}
+	return rrs, changes, dns.RcodeSuccess
+}
+
+// addRR adds rr to the records rrs of a zone, recording the change in
+// changes. A CNAME record is not added to a name with other records, nor
+// other records to a name with a CNAME record. The SOA record of the
+// zone is replaced.
+func addRR(rrs []dns.RR, rr dns.RR, changes *[]string) []dns.RR {
+	h := rr.Header()
+	set := rrset(rrs, h.Name, dns.TypeANY)
+	for _, other := range set {
+		oh := other.Header()
+		if (h.Rrtype == dns.TypeCNAME) != (oh.Rrtype == dns.TypeCNAME) {
+			return rrs
+		}
+	}
+	for i, other := range rrs {
+		oh := other.Header()
+		replaced := h.Rrtype == dns.TypeSOA && oh.Rrtype == dns.TypeSOA || h.Rrtype == dns.TypeCNAME && oh.Rrtype == dns.TypeCNAME
+		if !strings.EqualFold(oh.Name, h.Name) || !replaced && !dns.IsDuplicate(other, rr) {
+			continue
+		}
+		if other.String() == rr.String() {
+			return rrs
+		}
+		*changes = append(*changes, "- "+other.String(), "+ "+rr.String())
+		rrs[i] = rr
+		return rrs
+	}
+	if h.Rrtype == dns.TypeSOA {
+		// a zone has a single SOA record, at its apex
+		return rrs
+	}
+	*changes = append(*changes, "+ "+rr.String())
+	return append(rrs, rr)
+}
@@ -0,0 +1,298 @@
+package git
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+const updateZone = `$ORIGIN example.org.
+$TTL 3600
+; example.org, updated dynamically
+@ IN SOA ns1 hostmaster (
+	1 ; serial
+	7200 3600 1209600 3600 )
+  IN NS ns1
+www 300 IN A 192.0.2.1 ; web server
+`
+
+// testSecret is the secret of the TSIG keys of the tests.
+const testSecret = "c2VjcmV0c2VjcmV0c2VjcmV0c2VjcmV0"
+
+// tsigConfig returns the configuration of a server block with the TSIG
+// keys names, of testSecret.
+func tsigConfig(names ...string) *dnsserver.Config {
+	secrets := map[string]string{}
+	for _, name := range names {
+		secrets[name] = testSecret
+	}
+	return &dnsserver.Config{TsigSecret: secrets}
+}
+
+// sign returns m signed with key and secret, as a server receives it.
+func sign(t *testing.T, m *dns.Msg, key, secret string) *dns.Msg {
+	t.Helper()
+	m.SetTsig(key, dns.HmacSHA256, 300, time.Now().Unix())
+	buf, _, err := dns.TsigGenerate(m, secret, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := new(dns.Msg)
+	if err := signed.Unpack(buf); err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// mustRR parses the record s, which has no data if it only has a name,
+// TTL, class and type.
+func mustRR(t *testing.T, s string) dns.RR {
+	t.Helper()
+	if f := strings.Fields(s); len(f) == 4 {
+		return &dns.ANY{Hdr: dns.RR_Header{Name: f[0], Rrtype: dns.StringToType[f[3]], Class: dns.StringToClass[f[2]]}}
+	}
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rr
+}
+
+func TestApplyUpdates(t *testing.T) {
+	zone := "example.org."
+
+	tests := []struct {
+		prereqs []string
+		updates []string
+		rcode   int
+		serial  uint32
+		records []string
+	}{
+		// add, value independent prerequisite
+		{[]string{"www.example.org. 0 ANY A"}, []string{"mail.example.org. 300 IN A 192.0.2.2"}, dns.RcodeSuccess, 2,
+			[]string{"www.example.org.\t300\tIN\tA\t192.0.2.1", "mail.example.org.\t300\tIN\tA\t192.0.2.2"}},
+		// delete a record, value dependent prerequisite
+		{[]string{"www.example.org. 0 IN A 192.0.2.1"}, []string{"www.example.org. 0 NONE A 192.0.2.1"}, dns.RcodeSuccess, 2, nil},
+		// delete a name, keeping the SOA and NS of the apex
+		{nil, []string{"example.org. 0 ANY ANY", "www.example.org. 0 ANY ANY"}, dns.RcodeSuccess, 2, nil},
+		// failing prerequisites
+		{[]string{"mail.example.org. 0 ANY ANY"}, nil, dns.RcodeNameError, 1, nil},
+		{[]string{"www.example.org. 0 NONE ANY"}, nil, dns.RcodeYXDomain, 1, nil},
+		{[]string{"www.example.org. 0 NONE A"}, nil, dns.RcodeYXRrset, 1, nil},
+		{[]string{"www.example.org. 0 IN A 192.0.2.9"}, nil, dns.RcodeNXRrset, 1, nil},
+		{[]string{"www.example.net. 0 ANY A"}, nil, dns.RcodeNotZone, 1, nil},
+		// invalid updates
+		{nil, []string{"www.example.net. 300 IN A 192.0.2.2"}, dns.RcodeNotZone, 1, nil},
+		{nil, []string{"www.example.org. 0 NONE ANY"}, dns.RcodeFormatError, 1, nil},
+		// no change
+		{nil, []string{"www.example.org. 300 IN A 192.0.2.1", "www.example.org. 300 IN CNAME example.org."}, dns.RcodeSuccess, 1,
+			[]string{"www.example.org.\t300\tIN\tA\t192.0.2.1"}},
+	}
+
+	for i, tc := range tests {
+		var rrs, prereqs, updates []dns.RR
+		zp := dns.NewZoneParser(strings.NewReader(updateZone), zone, "")
+		for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+			rrs = append(rrs, rr)
+		}
+		for _, s := range tc.prereqs {
+			prereqs = append(prereqs, mustRR(t, s))
+		}
+		for _, s := range tc.updates {
+			updates = append(updates, mustRR(t, s))
+		}
+
+		rcode := checkPrereqs(rrs, prereqs, zone)
+		if rcode == dns.RcodeSuccess {
+			rrs, _, rcode = applyUpdates(rrs, updates, zone)
+		}
+		if rcode != tc.rcode {
+			t.Errorf("Test %v expects rcode %v but found %v", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+			continue
+		}
+		if rcode != dns.RcodeSuccess {
+			continue
+		}
+		if soa := rrset(rrs, zone, dns.TypeSOA); len(soa) != 1 || soa[0].(*dns.SOA).Serial != tc.serial {
+			t.Errorf("Test %v expects serial %v, found %v", i, tc.serial, soa)
+		}
+		if len(rrset(rrs, zone, dns.TypeNS)) != 1 {
+			t.Errorf("Test %v expects the NS record of the apex to be kept", i)
+		}
+		var records []string
+		for _, rr := range rrs {
+			if !strings.EqualFold(rr.Header().Name, zone) {
+				records = append(records, rr.String())
+			}
+		}
+		if strings.Join(records, "\n") != strings.Join(tc.records, "\n") {
+			t.Errorf("Test %v expects records %q, found %q", i, tc.records, records)
+		}
+	}
+}
+
+func TestServeUpdate(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	work := newOrigin(t, map[string]string{"db.example.org": updateZone})
+	origin := filepath.Join(dir, "origin.git")
+	runGit(t, dir, "clone", "-q", "--bare", work, origin)
+
+	repo := &Repo{
+		URL: origin, Path: filepath.Join(dir, "zones"), Branch: "master", Push: true,
+		Updates: []Update{{Zone: "example.org.", File: "db.example.org", Keys: []string{"update."}}},
+	}
+	if err := repo.pull(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	h := Handler{Repos: Git{repo}, Next: test.ErrorHandler(), Config: tsigConfig("update.", "other.")}
+
+	update := func(key, secret string) int {
+		m := new(dns.Msg)
+		m.SetUpdate("example.org.")
+		m.Insert([]dns.RR{mustRR(t, "mail.example.org. 300 IN A 192.0.2.2")})
+		if key != "" {
+			m = sign(t, m, key, secret)
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := h.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Rcode
+	}
+
+	for _, key := range []string{"", "other.", "unknown."} {
+		if rcode := update(key, testSecret); rcode != dns.RcodeRefused {
+			t.Errorf("Expected update signed with %q to be refused, found %v", key, dns.RcodeToString[rcode])
+		}
+	}
+	// forged, test.ResponseWriter reporting any signature as valid as
+	// the writers of DNS over HTTPS do
+	if rcode := update("update.", "Zm9yZ2Vk"); rcode != dns.RcodeRefused {
+		t.Errorf("Expected update with a forged signature to be refused, found %v", dns.RcodeToString[rcode])
+	}
+	if rcode := update("update.", testSecret); rcode != dns.RcodeSuccess {
+		t.Fatalf("Expected update to succeed, found %v", dns.RcodeToString[rcode])
+	}
+
+	b, _ := os.ReadFile(filepath.Join(repo.Path, "db.example.org"))
+	expected := strings.Replace(updateZone, "1 ; serial", "2 ; serial", 1) + "mail.example.org.\t300\tIN\tA\t192.0.2.2\n"
+	if string(b) != expected {
+		t.Errorf("Expected the update in the zone file, found %q", b)
+	}
+	out, _ := exec.Command("git", "--git-dir", origin, "log", "--format=%B", "-n", "1", "master").Output()
+	if !strings.HasPrefix(string(out), "Dynamic update of example.org.\n\nKey: update.\n") || !strings.Contains(string(out), "+ mail.example.org.") {
+		t.Errorf("Expected the update to be pushed, found %q", out)
+	}
+	if repo.Commit() == "" || repo.unpushed {
+		t.Errorf("Expected the update to be checked out and pushed")
+	}
+}
+
+func TestZoneFile(t *testing.T) {
+	zone := "example.org."
+	tests := []struct {
+		updates  []string
+		expected string
+	}{
+		// the next record takes the owner of a removed one
+		{[]string{"www.example.org. 0 NONE A 192.0.2.1"}, `$ORIGIN example.org.
+$TTL 3600
+@ IN SOA ns1 hostmaster 2 7200 3600 1209600 3600 ; apex
+  IN NS ns1
+
+; web
+www.example.org.    IN AAAA 2001:db8::1
+alias IN CNAME www
+$TTL 300
+`},
+		// a replaced record keeps its place, a new one is added at the end
+		{[]string{"alias.example.org. 300 IN CNAME web.example.org.", "mail.example.org. 300 IN A 192.0.2.2"}, `$ORIGIN example.org.
+$TTL 3600
+@ IN SOA ns1 hostmaster 2 7200 3600 1209600 3600 ; apex
+  IN NS ns1
+
+; web
+www IN A 192.0.2.1
+    IN AAAA 2001:db8::1
+alias.example.org.	300	IN	CNAME	web.example.org.
+$TTL 300
+mail.example.org.	300	IN	A	192.0.2.2
+`},
+	}
+	for i, tc := range tests {
+		path := filepath.Join(t.TempDir(), "db.example.org")
+		writeFiles(t, filepath.Dir(path), map[string]string{"db.example.org": `$ORIGIN example.org.
+$TTL 3600
+@ IN SOA ns1 hostmaster 1 7200 3600 1209600 3600 ; apex
+  IN NS ns1
+
+; web
+www IN A 192.0.2.1
+    IN AAAA 2001:db8::1
+alias IN CNAME www
+$TTL 300
+`})
+		z, err := readZone(path, zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var updates []dns.RR
+		for _, u := range tc.updates {
+			updates = append(updates, mustRR(t, u))
+		}
+		rrs, _, rcode := applyUpdates(z.rrs(), updates, zone)
+		if rcode != dns.RcodeSuccess {
+			t.Fatalf("Test %v: expected the update to succeed, found %v", i, dns.RcodeToString[rcode])
+		}
+		if err := writeZone(path, z, rrs); err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := os.ReadFile(path); string(b) != tc.expected {
+			t.Errorf("Test %v: expected %q, found %q", i, tc.expected, b)
+		}
+		// the file holds the updated records
+		if z, err = readZone(path, zone); err != nil || len(z.records) != len(rrs) {
+			t.Errorf("Test %v: expected %v records read back, found %v", i, len(rrs), err)
+		}
+	}
+
+	// included or generated records can't be updated in place
+	for _, directive := range []string{"$INCLUDE db.other", "$GENERATE 1-2 host$ A 192.0.2.$"} {
+		path := filepath.Join(t.TempDir(), "db.example.org")
+		writeFiles(t, filepath.Dir(path), map[string]string{"db.example.org": updateZone + directive + "\n"})
+		if _, err := readZone(path, zone); err == nil || !strings.Contains(err.Error(), "not supported") {
+			t.Errorf("Expected %v to be refused, found %v", directive, err)
+		}
+	}
+}
+
+func TestTsigKey(t *testing.T) {
+	h := Handler{Config: tsigConfig("update.")}
+	for _, compress := range []bool{false, true} {
+		m := new(dns.Msg)
+		m.SetUpdate("example.org.")
+		m.Insert([]dns.RR{mustRR(t, "www.example.org. 300 IN A 192.0.2.2"), mustRR(t, "mail.example.org. 300 IN A 192.0.2.3")})
+		m.Compress = compress
+		if key, ok := h.tsigKey(&test.ResponseWriter{}, sign(t, m, "Update.", testSecret)); !ok || key != "update." {
+			t.Errorf("Expected the key of the message compressed %v, found %q, %v", compress, key, ok)
+		}
+	}
+	m := new(dns.Msg)
+	m.SetUpdate("example.org.")
+	if _, ok := (Handler{}).tsigKey(&test.ResponseWriter{}, sign(t, m, "update.", testSecret)); ok {
+		t.Error("Expected no key without the tsig plugin")
+	}
+}
@@ -0,0 +1,94 @@
+package git
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+// repoName returns the name of the repository at url, its last path
+// element without the .git suffix, e.g. "zones" for
+// git@github.com:user/zones.git.
+func repoName(url string) string {
+	url = strings.TrimSuffix(strings.TrimRight(url, "/"), ".git")
+	if i := strings.LastIndexAny(url, "/:"); i >= 0 {
+		url = url[i+1:]
+	}
+	return url
+}
+
+// remoteOf returns the scheme and host of the remote at url: ssh for the
+// scp-like git@host:path syntax, file without a host for local paths.
+func remoteOf(url string) (scheme, host string) {
+	if i := strings.Index(url, "://"); i > 0 {
+		rest := url[i+3:]
+		if j := strings.IndexAny(rest, "/?#"); j >= 0 {
+			rest = rest[:j]
+		}
+		if j := strings.LastIndex(rest, "@"); j >= 0 {
+			rest = rest[j+1:]
+		}
+		if h, _, err := net.SplitHostPort(rest); err == nil {
+			rest = h
+		}
+		return strings.ToLower(url[:i]), strings.ToLower(strings.Trim(rest, "[]"))
+	}
+	if strings.HasPrefix(url, "ext::") {
+		return "ext", ""
+	}
+	// git@host:path, unless the colon is after a slash, as in local paths
+	colon := scpColon(url)
+	if colon < 0 || strings.ContainsAny(url[:colon], `/\`) || colon == 1 && filepath.VolumeName(url) != "" {
+		return "file", ""
+	}
+	host = url[:colon]
+	if j := strings.LastIndex(host, "@"); j >= 0 {
+		host = host[j+1:]
+	}
+	return "ssh", strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// scpColon returns the index of the colon separating the host from the
+// path in the scp-like syntax, after the brackets of an IPv6 address as in
+// git@[2001:db8::1]:zones.git, or -1 if url has no colon.
+func scpColon(url string) int {
+	colon := strings.Index(url, ":")
+	if i := strings.Index(url, "["); i >= 0 && i < colon && (i == 0 || url[i-1] == '@') {
+		if j := strings.Index(url[i:], "]:"); j >= 0 {
+			return i + j + 1
+		}
+	}
+	return colon
+}
+
+// hostURL returns host as written in URLs, in brackets if it is an IPv6
+// address.
+func hostURL(host string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// projectOf returns the path of the project of the remote at url on its
+// host, without the .git suffix, e.g. "user/zones" for
+// git@github.com:user/zones.git, or "" for local paths.
+func projectOf(url string) string {
+	scheme, host := remoteOf(url)
+	if host == "" {
+		return ""
+	}
+	var path string
+	if scheme == "ssh" && !strings.Contains(url, "://") {
+		path = url[scpColon(url)+1:]
+	} else {
+		rest := url[strings.Index(url, "://")+3:]
+		if i := strings.Index(rest, "/"); i >= 0 {
+			path = rest[i:]
+		}
+		if i := strings.IndexAny(path, "?#"); i >= 0 {
+			path = path[:i]
+		}
+	}
+	return strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+}
@@ -0,0 +1,34 @@
+package git
+
+import "fmt"
+
+// Xattr is an extended attribute of files.
+type Xattr struct {
+	Name  string // Name of the attribute, with its namespace, e.g. user.origin
+	Value string // Value of the attribute
+}
+
+// selinuxXattr is the extended attribute holding the SELinux context of
+// files.
+const selinuxXattr = "security.selinux"
+
+// labelCheckout sets the Xattrs of the files of the checkout and of the
+// targets it is published to, e.g. their SELinux context for CoreDNS to be
+// allowed to read them. Git metadata is left untouched.
+func (r *Repo) labelCheckout() error {
+	if len(r.Xattrs) == 0 {
+		return nil
+	}
+	err := r.walkServed(func(path string) error {
+		for _, x := range r.Xattrs {
+			if err := setXattr(path, x.Name, x.Value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot label the checkout of %v: %s", r, err)
+	}
+	return nil
+}
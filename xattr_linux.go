@@ -0,0 +1,24 @@
+package git
+
+import (
+	"bytes"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// xattrSupported reports whether extended attributes can be set.
+const xattrSupported = true
+
+// setXattr sets the extended attribute name of path, not following
+// symbolic links, unless it already has value.
+func setXattr(path, name, value string) error {
+	buf := make([]byte, len(value)+1)
+	if n, err := unix.Lgetxattr(path, name, buf); err == nil && bytes.Equal(bytes.TrimSuffix(buf[:n], []byte{0}), []byte(value)) {
+		return nil
+	}
+	if err := unix.Lsetxattr(path, name, []byte(value), 0); err != nil {
+		return &os.PathError{Op: "setxattr", Path: path, Err: err}
+	}
+	return nil
+}
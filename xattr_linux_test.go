@@ -0,0 +1,38 @@
+package git
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLabelCheckout(t *testing.T) {
+	dir := t.TempDir()
+	repo := &Repo{Path: filepath.Join(dir, "zones"), Maps: []Mapping{{From: "dns", To: filepath.Join(dir, "dns")}}, Xattrs: []Xattr{{Name: "user.coredns", Value: "zones"}}}
+	writeFiles(t, repo.Path, map[string]string{"dns/db.example.org": "v1", ".git/HEAD": "ref"})
+	writeFiles(t, filepath.Join(dir, "dns"), map[string]string{"db.example.org": "v1"})
+	if err := repo.labelCheckout(); err != nil {
+		if errors.Is(err, unix.ENOTSUP) {
+			t.Skipf("extended attributes are not supported: %v", err)
+		}
+		t.Fatal(err)
+	}
+	// labeling again leaves the attributes as they are
+	if err := repo.labelCheckout(); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"zones": "zones", "zones/dns/db.example.org": "zones", "dns/db.example.org": "zones", "zones/.git/HEAD": ""} {
+		buf := make([]byte, 64)
+		n, err := unix.Lgetxattr(filepath.Join(dir, name), "user.coredns", buf)
+		if errors.Is(err, unix.ENODATA) {
+			n = 0
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != value {
+			t.Errorf("Expected %v to be labeled %q, found %q", name, value, buf[:n])
+		}
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package git
+
+import "errors"
+
+// xattrSupported reports whether extended attributes can be set.
+const xattrSupported = false
+
+// setXattr fails: extended attributes are only set on Linux.
+func setXattr(path, name, value string) error {
+	return errors.New("extended attributes are not supported")
+}